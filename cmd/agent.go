@@ -0,0 +1,345 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"llmx/pkg/agent"
+	"llmx/pkg/llmx"
+	"llmx/pkg/parser"
+	"llmx/pkg/provider"
+	"llmx/pkg/tools"
+	"llmx/pkg/validator"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	agentConfigPath string
+	agentMaxSteps   int
+	agentAllow      []string
+	agentYes        bool
+)
+
+// defaultAgentMaxSteps limits model requests when neither --max-steps nor the
+// config sets a limit.
+const defaultAgentMaxSteps = 10
+
+// errMaxSteps is returned when the model has not produced a valid final
+// answer within the step limit.
+var errMaxSteps = errors.New("no final answer within the step limit")
+
+// agentRun drives the agent loop: it sends the conversation, runs the tools
+// the model calls and feeds their results back until the model answers with
+// structured output matching the schema.
+type agentRun struct {
+	client   *llmx.Client
+	cfg      *agent.Config
+	opts     provider.Options
+	schema   map[string]interface{}
+	maxSteps int
+	// approveAll skips confirmation for every tool (--yes).
+	approveAll bool
+	// confirm asks whether a tool outside the allowlist may run.
+	confirm func(call provider.ToolCall) bool
+	// log receives progress messages; nil discards them.
+	log io.Writer
+}
+
+func (a *agentRun) logf(format string, args ...interface{}) {
+	if a.log != nil {
+		fmt.Fprintf(a.log, "[llmx] "+format+"\n", args...)
+	}
+}
+
+// run executes the loop for message and returns the final answer. The last
+// rejection (a decode error or llmx.SchemaError) is wrapped in errMaxSteps
+// when the limit is reached.
+func (a *agentRun) run(ctx context.Context, message string) (map[string]interface{}, error) {
+	opts := a.opts
+	opts.Message = message
+	var problem error
+	for step := 1; step <= a.maxSteps; step++ {
+		a.logf("Step %d/%d", step, a.maxSteps)
+		resp, calls, err := sendRequest(ctx, a.client, opts, nil)
+		if err != nil {
+			return nil, err
+		}
+		rawOut := resp.Text
+		if opts.Message != "" {
+			opts.History = append(opts.History, provider.Turn{Role: provider.RoleUser, Content: opts.Message})
+			opts.Message = ""
+		}
+
+		if len(calls) > 0 {
+			opts.History = append(opts.History, provider.Turn{Role: provider.RoleAssistant, Content: rawOut, ToolCalls: calls})
+			for _, call := range calls {
+				content, err := a.runTool(ctx, call)
+				if err != nil {
+					return nil, explainError(err)
+				}
+				opts.History = append(opts.History, provider.Turn{
+					Role:       provider.RoleTool,
+					ToolCallID: call.ID,
+					ToolName:   call.Name,
+					Content:    content,
+				})
+			}
+			continue
+		}
+
+		obj, err := llmx.DecodeOutput(rawOut, a.schema)
+		if err == nil {
+			return obj, nil
+		}
+		problem = err
+		a.logf("Step %d/%d rejected: %v", step, a.maxSteps, problem)
+		opts.History = append(opts.History, provider.Turn{Role: provider.RoleAssistant, Content: rawOut})
+		opts.Message = llmx.RepairMessage(problem)
+	}
+	if problem != nil {
+		return nil, fmt.Errorf("%w (%d steps): %w", errMaxSteps, a.maxSteps, problem)
+	}
+	return nil, fmt.Errorf("%w (%d steps)", errMaxSteps, a.maxSteps)
+}
+
+// runTool executes one tool call and returns the content of its result turn.
+// Failures are reported to the model as "error: ..." so it can recover; only
+// the cancellation of ctx is returned as an error, which ends the loop.
+func (a *agentRun) runTool(ctx context.Context, call provider.ToolCall) (string, error) {
+	args, _ := json.Marshal(call.Arguments)
+	if err := tools.ValidateCall(a.opts.Tools, call); err != nil {
+		a.logf("Tool %s rejected: %v", call.Name, err)
+		return "error: invalid arguments: " + err.Error(), nil
+	}
+	tc := a.cfg.Find(call.Name)
+	if !a.approveAll && !a.cfg.Allowed(call.Name) {
+		approved := false
+		if a.confirm != nil {
+			// The prompt blocks on the terminal, so wait for it in the
+			// background and give up on cancellation.
+			answer := make(chan bool, 1)
+			go func() { answer <- a.confirm(call) }()
+			select {
+			case approved = <-answer:
+			case <-ctx.Done():
+				return "", fmt.Errorf("tool %s: %w", call.Name, ctx.Err())
+			}
+		}
+		if !approved {
+			a.logf("Tool %s %s denied", call.Name, args)
+			return "error: the user denied this tool call", nil
+		}
+	}
+	a.logf("Running tool %s %s", call.Name, args)
+	out, err := tc.Run(ctx, call.Arguments)
+	if ctx.Err() != nil {
+		return "", fmt.Errorf("tool %s: %w", call.Name, ctx.Err())
+	}
+	if err != nil {
+		a.logf("%v", err)
+		return "error: " + err.Error(), nil
+	}
+	return out, nil
+}
+
+// confirmOnTTY asks on the controlling terminal whether a tool call may run.
+// Without a terminal the call is denied.
+func confirmOnTTY(call provider.ToolCall) bool {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return false
+	}
+	defer func() {
+		_ = tty.Close()
+	}()
+	args, _ := json.Marshal(call.Arguments)
+	fmt.Fprintf(tty, "Run tool %s %s? [y/N] ", call.Name, args)
+	line, _ := bufio.NewReader(tty).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+var agentCmd = &cobra.Command{
+	Use:   "agent --config agent.json [flags] [\"your message\"|-]",
+	Short: "Let the model run local command tools until it produces a structured answer",
+	Example: strings.TrimSpace(`
+  # Tools from agent.json; the final answer must match --format
+  llmx agent --config agent.json --format "answer,sources:string[]" "Which Go files mention TODO?"
+
+  # Skip confirmation for one tool, or for all of them
+  llmx agent --config agent.json --allow grep_files "Summarize the open TODOs"
+  llmx agent --config agent.json --yes --max-steps 20 - < task.txt
+    `),
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var message string
+		if len(args) == 1 && args[0] != "-" {
+			message = args[0]
+		} else if fi, _ := os.Stdin.Stat(); len(args) == 1 || fi.Mode()&os.ModeCharDevice == 0 {
+			stdinBytes, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				fmt.Println("failed to read from stdin:", err)
+				os.Exit(1)
+			}
+			message = string(stdinBytes)
+		}
+		if strings.TrimSpace(message) == "" {
+			_ = cmd.Help()
+			return
+		}
+
+		cfg, err := agent.LoadConfig(agentConfigPath)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		for _, name := range agentAllow {
+			if cfg.Find(name) == nil {
+				fmt.Printf("--allow %q is not a tool in %s\n", name, agentConfigPath)
+				os.Exit(1)
+			}
+		}
+		cfg.Allow = append(cfg.Allow, agentAllow...)
+		declared, err := cfg.Declarations()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		steps := ifZero(cfg.MaxSteps, defaultAgentMaxSteps)
+		if cmd.Flags().Changed("max-steps") {
+			steps = agentMaxSteps
+		}
+		if steps < 1 {
+			fmt.Println("--max-steps must be >= 1")
+			os.Exit(1)
+		}
+
+		if err := applyProfile(cmd); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if err := loadCostFlags(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		client, err := newClient(providerName)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if err := checkCapabilities(providerName, client.Provider.Capabilities(), provider.Options{Tools: declared, Instructions: instructions}, false); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		warnIgnoredFlags(cmd, providerName, client.Provider.Capabilities())
+		if modelOptions, err = parseModelOptions(modelOptionSpecs); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		schema, err := parser.ParseFormatSchema(format)
+		if err != nil {
+			fmt.Printf("failed to parse format: %v\n", err)
+			os.Exit(1)
+		}
+		properties := schema["properties"].(map[string]interface{})
+		required := schema["required"].([]string)
+		if len(properties) == 0 {
+			fmt.Println("agent requires a non-empty --format for the final answer")
+			os.Exit(1)
+		}
+		if onlyKey != "" {
+			if _, hasOnly := properties[onlyKey]; !hasOnly {
+				fmt.Printf("--only %q not found in schema. Include it in --format.\n", onlyKey)
+				os.Exit(1)
+			}
+		}
+		if strings.TrimSpace(baseURL) != "" {
+			if u, err := url.Parse(baseURL); err != nil || u.Scheme == "" || u.Host == "" {
+				fmt.Printf("invalid --base-url: %q\nUse a full URL like https://api.example.com\n", baseURL)
+				os.Exit(1)
+			}
+		}
+
+		a := &agentRun{
+			client: client,
+			cfg:    cfg,
+			opts: provider.Options{
+				Model:           model,
+				Instructions:    instructions,
+				Verbosity:       verbosity,
+				ReasoningEffort: reasoningEffort,
+				Properties:      properties,
+				Required:        required,
+				Tools:           declared,
+				ToolChoice:      provider.ToolChoiceAuto,
+				MaxTokens:       maxTokens,
+				ModelOptions:    modelOptions,
+				KeepAlive:       keepAlive,
+			},
+			schema:     validator.ObjectSchema(properties, required),
+			maxSteps:   steps,
+			approveAll: agentYes,
+			confirm:    confirmOnTTY,
+		}
+		if verbose {
+			a.log = os.Stderr
+		}
+
+		// SIGINT and SIGTERM cancel the whole loop, including a running
+		// tool, with errInterrupted instead of killing the process.
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		obj, err := a.run(ctx, message)
+		stop()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitCodeFor(err))
+		}
+		if err := llmx.CheckErrorField(obj, errorKey); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		textOut, err := renderOutput(obj, required, onlyKey)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println(strings.TrimRight(textOut, "\n"))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(agentCmd)
+
+	agentCmd.Flags().StringVar(&agentConfigPath, "config", "", "agent config file declaring the local tools (required)")
+	_ = agentCmd.MarkFlagRequired("config")
+	agentCmd.Flags().StringVar(&profileName, "profile", "", "config file profile to use (default $LLMX_PROFILE or default_profile)")
+	agentCmd.Flags().StringVar(&providerName, "provider", "openai", "LLM provider name (e.g., openai)")
+	agentCmd.Flags().StringVar(&model, "model", "", "model name (provider default if empty)")
+	agentCmd.Flags().StringVar(&instructions, "instructions", "", "instructions to guide the model")
+	agentCmd.Flags().StringVar(&format, "format", "message,error", "output format specification for the final answer")
+	agentCmd.Flags().StringVar(&onlyKey, "only", "", "print only the specified top-level key from the final answer")
+	agentCmd.Flags().IntVar(&agentMaxSteps, "max-steps", defaultAgentMaxSteps, "maximum number of model requests (overrides max_steps in the config)")
+	agentCmd.Flags().StringArrayVar(&agentAllow, "allow", nil, "run this tool without asking for confirmation (repeatable)")
+	agentCmd.Flags().BoolVar(&agentYes, "yes", false, "run every tool call without asking for confirmation")
+	agentCmd.Flags().StringVar(&baseURL, "base-url", "", "override base URL (provider default if empty)")
+	addRequestFlags(agentCmd)
+	agentCmd.Flags().IntVar(&maxTokens, "max-tokens", 0, "max output tokens (override; provider default if 0)")
+	addModelFlags(agentCmd)
+	addCostFlags(agentCmd, "refuse to send a step whose estimated input cost, including the history, exceeds this many USD (0 = no limit)")
+	agentCmd.Flags().BoolVar(&verbose, "verbose", false, "log each step and tool run (and request details) to stderr")
+}
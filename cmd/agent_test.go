@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"llmx/pkg/agent"
+	"llmx/pkg/provider"
+	"llmx/pkg/validator"
+)
+
+// agentServer answers each openai-compat request with the next reply, given
+// as a chat message object, and records the request payloads.
+func agentServer(t *testing.T, replies ...map[string]interface{}) *[]map[string]interface{} {
+	t.Helper()
+	var requests []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &payload)
+		requests = append(requests, payload)
+		reply := replies[len(replies)-1]
+		if len(requests) <= len(replies) {
+			reply = replies[len(requests)-1]
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []interface{}{map[string]interface{}{"message": reply}},
+		})
+	}))
+	t.Cleanup(srv.Close)
+
+	oldBaseURL := baseURL
+	baseURL = srv.URL
+	t.Cleanup(func() { baseURL = oldBaseURL })
+	t.Setenv("OPENAI_API_KEY", "test")
+	return &requests
+}
+
+func toolCallReply(id, name, args string) map[string]interface{} {
+	return map[string]interface{}{
+		"content": nil,
+		"tool_calls": []interface{}{map[string]interface{}{
+			"id": id, "type": "function",
+			"function": map[string]interface{}{"name": name, "arguments": args},
+		}},
+	}
+}
+
+func newTestAgentRun(t *testing.T, maxSteps int) *agentRun {
+	t.Helper()
+	shout := agent.ToolConfig{Command: `tr a-z A-Z`}
+	shout.Name, shout.Format = "shout", "text"
+	danger := agent.ToolConfig{Command: `echo ran`}
+	danger.Name = "danger"
+	cfg := &agent.Config{Tools: []agent.ToolConfig{shout, danger}, Allow: []string{"shout"}}
+	declared, err := cfg.Declarations()
+	if err != nil {
+		t.Fatalf("Declarations: %v", err)
+	}
+	client, err := newClient("openai-compat")
+	if err != nil {
+		t.Fatalf("newClient: %v", err)
+	}
+	props := map[string]interface{}{"answer": map[string]interface{}{"type": "string"}}
+	return &agentRun{
+		client:   client,
+		cfg:      cfg,
+		opts:     provider.Options{Model: "m", Properties: props, Required: []string{"answer"}, Tools: declared},
+		schema:   validator.ObjectSchema(props, []string{"answer"}),
+		maxSteps: maxSteps,
+	}
+}
+
+func TestAgentRun_ToolLoop(t *testing.T) {
+	requests := agentServer(t,
+		toolCallReply("c1", "shout", `{"text":"hi"}`),
+		toolCallReply("c2", "danger", `{}`),
+		map[string]interface{}{"content": `{"answer":"HI"}`},
+	)
+	a := newTestAgentRun(t, 5)
+	var asked []string
+	a.confirm = func(call provider.ToolCall) bool {
+		asked = append(asked, call.Name)
+		return false
+	}
+
+	obj, err := a.run(context.Background(), "shout hi")
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if obj["answer"] != "HI" {
+		t.Fatalf("answer = %v", obj)
+	}
+	if len(asked) != 1 || asked[0] != "danger" {
+		t.Fatalf("only the tool outside the allowlist should be confirmed: %v", asked)
+	}
+	if len(*requests) != 3 {
+		t.Fatalf("expected 3 requests, got %d", len(*requests))
+	}
+	// user, assistant call, tool result, assistant call, tool result
+	msgs := (*requests)[2]["messages"].([]interface{})
+	if len(msgs) != 6 {
+		t.Fatalf("expected system + 5 messages, got %d: %v", len(msgs), msgs)
+	}
+	result := msgs[3].(map[string]interface{})
+	if result["role"] != "tool" || result["tool_call_id"] != "c1" || result["content"] != `{"TEXT":"HI"}` {
+		t.Fatalf("tool result mismatch: %v", result)
+	}
+	denied := msgs[5].(map[string]interface{})
+	if !strings.HasPrefix(denied["content"].(string), "error: ") {
+		t.Fatalf("denied call should report an error: %v", denied)
+	}
+}
+
+func TestAgentRun_MaxSteps(t *testing.T) {
+	agentServer(t, map[string]interface{}{"content": `{"answer":1}`})
+	a := newTestAgentRun(t, 2)
+	_, err := a.run(context.Background(), "go")
+	var verrs validator.Errors
+	if !errors.Is(err, errMaxSteps) || !errors.As(err, &verrs) {
+		t.Fatalf("expected errMaxSteps wrapping a schema violation, got %v", err)
+	}
+}
+
+func TestAgentRun_InterruptedTool(t *testing.T) {
+	requests := agentServer(t, toolCallReply("c1", "shout", `{"text":"hi"}`))
+	a := newTestAgentRun(t, 5)
+	a.cfg.Tools[0].Command = "sleep 5"
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := a.run(ctx, "shout hi")
+	if !errors.Is(err, errInterrupted) {
+		t.Fatalf("expected errInterrupted, got %v", err)
+	}
+	if time.Since(start) > 3*time.Second || len(*requests) != 1 {
+		t.Fatalf("the loop should stop with the tool (after %s, %d requests)", time.Since(start), len(*requests))
+	}
+}
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"llmx/pkg/llmx"
+	"llmx/pkg/provider"
+	"llmx/pkg/retry"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	// headerSpecs are the --header values ("Name: value").
+	headerSpecs []string
+	// extraHeaders are added to every request: the profile's headers
+	// overridden by --header.
+	extraHeaders   map[string]string
+	apiKeyEnv      string
+	apiKeyFile     string
+	apiKeyCmd      string
+	httpRetries    int
+	maxRetryWait   time.Duration
+	requestTimeout time.Duration
+	connectTimeout time.Duration
+	// client is the shared HTTP client, built on first use.
+	client *http.Client
+	// resolvedAPIKey caches the key from --api-key-file/--api-key-cmd so
+	// loops (retries, chat, agent) read it once.
+	resolvedAPIKey string
+	// retryInterrupted resends requests whose connection broke after they
+	// were sent (--http-retry-interrupted).
+	retryInterrupted bool
+)
+
+// addRequestFlags registers the header, API key, HTTP retry and timeout
+// flags on cmd.
+func addRequestFlags(cmd *cobra.Command) {
+	cmd.Flags().DurationVar(&requestTimeout, "timeout", 0, "give up on a request after this long, including HTTP retries and streaming (0 = no limit)")
+	cmd.Flags().DurationVar(&connectTimeout, "connect-timeout", 30*time.Second, "give up connecting to the provider (TCP and TLS) after this long")
+	def := retry.Default()
+	cmd.Flags().IntVar(&httpRetries, "http-retries", def.MaxRetries, "retry rate-limited, overloaded and failed requests up to N times with backoff")
+	cmd.Flags().DurationVar(&maxRetryWait, "max-retry-wait", def.MaxDelay, "longest wait before an HTTP retry; give up if the server asks for longer")
+	cmd.Flags().BoolVar(&retryInterrupted, "http-retry-interrupted", false, "also retry requests whose connection broke after they were sent (reset, EOF, read timeout); the provider may bill both attempts")
+	cmd.Flags().StringArrayVar(&headerSpecs, "header", nil, "add an HTTP header to each request, as \"Name: value\" (repeatable)")
+	cmd.Flags().StringVar(&apiKeyEnv, "api-key-env", "", "read the API key from this environment variable instead of the provider default")
+	cmd.Flags().StringVar(&apiKeyFile, "api-key-file", "", "read the API key from this file")
+	cmd.Flags().StringVar(&apiKeyCmd, "api-key-cmd", "", "run this shell command and use the first line it prints as the API key (e.g., \"pass show openai\")")
+}
+
+// httpClient returns the client for provider requests, with --connect-timeout
+// applied to dialing and the TLS handshake.
+func httpClient() *http.Client {
+	if client == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.DialContext = (&net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}).DialContext
+		transport.TLSHandshakeTimeout = connectTimeout
+		client = &http.Client{Transport: transport}
+	}
+	return client
+}
+
+// retryPolicy returns the HTTP retry policy from --http-retries,
+// --max-retry-wait and --http-retry-interrupted.
+func retryPolicy() (retry.Policy, error) {
+	if httpRetries < 0 {
+		return retry.Policy{}, errors.New("--http-retries must be >= 0")
+	}
+	p := retry.Default()
+	p.MaxRetries = httpRetries
+	p.MaxDelay = maxRetryWait
+	p.RetryInterrupted = retryInterrupted
+	return p, nil
+}
+
+// newClient returns a client for the named provider configured from the
+// request flags: base URL, API key source, headers, retries, timeouts and
+// the --max-cost budget. Under --verbose it logs to stderr.
+func newClient(name string) (*llmx.Client, error) {
+	c, err := llmx.New(name)
+	if err != nil {
+		return nil, err
+	}
+	if c.Retry, err = retryPolicy(); err != nil {
+		return nil, err
+	}
+	c.BaseURL = baseURL
+	c.APIKeyFunc = resolveAPIKey
+	c.Headers = extraHeaders
+	c.HTTPClient = httpClient()
+	c.Timeout = requestTimeout
+	c.Prices = prices
+	c.MaxCost = maxCost
+	if verbose {
+		c.Logf = func(format string, args ...interface{}) {
+			fmt.Fprintf(os.Stderr, "[llmx] "+format+"\n", args...)
+		}
+	}
+	return c, nil
+}
+
+// parseHeader splits a --header value "Name: value".
+func parseHeader(spec string) (string, string, error) {
+	name, value, ok := strings.Cut(spec, ":")
+	name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+	if !ok || name == "" || strings.ContainsAny(name, " \t") {
+		return "", "", fmt.Errorf("invalid --header %q: use \"Name: value\"", spec)
+	}
+	return http.CanonicalHeaderKey(name), value, nil
+}
+
+// resolveAPIKey reads the API key from the configured source.
+func resolveAPIKey() (string, error) {
+	sources := 0
+	for _, s := range []string{apiKeyEnv, apiKeyFile, apiKeyCmd} {
+		if strings.TrimSpace(s) != "" {
+			sources++
+		}
+	}
+	switch {
+	case sources > 1:
+		return "", errors.New("use only one of --api-key-env, --api-key-file and --api-key-cmd")
+	case resolvedAPIKey != "":
+		return resolvedAPIKey, nil
+	case apiKeyEnv != "":
+		key := strings.TrimSpace(os.Getenv(apiKeyEnv))
+		if key == "" {
+			return "", provider.MissingAPIKeyError{Provider: providerName, EnvVar: apiKeyEnv}
+		}
+		return key, nil
+	case apiKeyFile != "":
+		data, err := os.ReadFile(apiKeyFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read API key file: %w", err)
+		}
+		key := strings.TrimSpace(string(data))
+		if key == "" {
+			return "", fmt.Errorf("API key file %s is empty", apiKeyFile)
+		}
+		resolvedAPIKey = key
+		return key, nil
+	case apiKeyCmd != "":
+		c := exec.Command("sh", "-c", apiKeyCmd)
+		var stderr bytes.Buffer
+		c.Stderr = &stderr
+		out, err := c.Output()
+		if err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return "", fmt.Errorf("--api-key-cmd failed (%v): %s", err, msg)
+			}
+			return "", fmt.Errorf("--api-key-cmd failed: %w", err)
+		}
+		line, _, _ := strings.Cut(string(out), "\n")
+		key := strings.TrimSpace(line)
+		if key == "" {
+			return "", errors.New("--api-key-cmd printed no API key")
+		}
+		resolvedAPIKey = key
+		return key, nil
+	}
+	return "", nil
+}
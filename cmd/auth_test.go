@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// resetRequestGlobals clears the header and API key settings for a test.
+func resetRequestGlobals(t *testing.T) {
+	t.Helper()
+	old := []string{apiKeyEnv, apiKeyFile, apiKeyCmd, resolvedAPIKey}
+	oldSpecs, oldHeaders := headerSpecs, extraHeaders
+	t.Cleanup(func() {
+		apiKeyEnv, apiKeyFile, apiKeyCmd, resolvedAPIKey = old[0], old[1], old[2], old[3]
+		headerSpecs, extraHeaders = oldSpecs, oldHeaders
+	})
+	apiKeyEnv, apiKeyFile, apiKeyCmd, resolvedAPIKey = "", "", "", ""
+	headerSpecs, extraHeaders = nil, nil
+}
+
+func TestParseHeader(t *testing.T) {
+	tests := []struct {
+		spec, name, value string
+		ok                bool
+	}{
+		{"X-Team: search", "X-Team", "search", true},
+		{"authorization:Bearer abc:def", "Authorization", "Bearer abc:def", true},
+		{"X-Empty:", "X-Empty", "", true},
+		{"X-Team search", "", "", false},
+		{": value", "", "", false},
+		{"X Team: v", "", "", false},
+	}
+	for _, tt := range tests {
+		name, value, err := parseHeader(tt.spec)
+		if (err == nil) != tt.ok || name != tt.name || value != tt.value {
+			t.Errorf("parseHeader(%q) = %q, %q, %v", tt.spec, name, value, err)
+		}
+	}
+}
+
+func TestResolveAPIKey(t *testing.T) {
+	resetRequestGlobals(t)
+	if key, err := resolveAPIKey(); err != nil || key != "" {
+		t.Fatalf("no source: %q, %v", key, err)
+	}
+
+	apiKeyFile = filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(apiKeyFile, []byte("sk-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if key, err := resolveAPIKey(); err != nil || key != "sk-file" {
+		t.Fatalf("file: %q, %v", key, err)
+	}
+
+	resetRequestGlobals(t)
+	apiKeyCmd = `printf 'sk-cmd\nlogin: me\n'`
+	if key, err := resolveAPIKey(); err != nil || key != "sk-cmd" {
+		t.Fatalf("cmd: %q, %v", key, err)
+	}
+	apiKeyCmd = "exit 1"
+	if key, err := resolveAPIKey(); err != nil || key != "sk-cmd" {
+		t.Fatalf("the command's key should be cached: %q, %v", key, err)
+	}
+
+	resetRequestGlobals(t)
+	apiKeyCmd = "echo denied >&2; exit 1"
+	if _, err := resolveAPIKey(); err == nil {
+		t.Fatalf("expected failing command error")
+	}
+
+	resetRequestGlobals(t)
+	apiKeyEnv, apiKeyCmd = "MY_KEY", "echo k"
+	if _, err := resolveAPIKey(); err == nil {
+		t.Fatalf("expected error for two key sources")
+	}
+
+	resetRequestGlobals(t)
+	apiKeyEnv = "MY_KEY"
+	t.Setenv("MY_KEY", "sk-env")
+	if key, err := resolveAPIKey(); err != nil || key != "sk-env" {
+		t.Fatalf("env: %q, %v", key, err)
+	}
+}
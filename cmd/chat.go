@@ -0,0 +1,430 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"llmx/pkg/llmx"
+	"llmx/pkg/parser"
+	"llmx/pkg/provider"
+	"llmx/pkg/transcript"
+	"llmx/pkg/validator"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	chatFormat string
+	sessionRef string
+)
+
+const chatHelp = `Commands:
+  /model [name]       show or set the model ("default" for the provider default)
+  /provider [name]    show or switch the provider (resets the model)
+  /format [spec]      show or set the --format shorthand ("off" for plain text)
+  /save [name|path]   save the session (default: the current session file)
+  /load <name|path>   load a saved session or transcript
+  /reset              clear the conversation history
+  /help               show this help
+  /exit, /quit        leave the chat (Ctrl-D also works)`
+
+// chatState is the mutable state of an interactive chat session.
+type chatState struct {
+	providerName string
+	client       *llmx.Client
+	model        string
+	instructions string
+	format       string
+	properties   map[string]interface{}
+	required     []string
+	history      []provider.Turn
+	// sessionPath is where the session is saved after each exchange; empty
+	// means the session is not persisted.
+	sessionPath string
+}
+
+func newChatState(providerName, model, instructions, format string) (*chatState, error) {
+	s := &chatState{model: model, instructions: instructions}
+	if err := s.setProvider(providerName); err != nil {
+		return nil, err
+	}
+	if err := s.setFormat(format); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *chatState) setProvider(name string) error {
+	client, err := newClient(name)
+	if err != nil {
+		return err
+	}
+	if err := checkCapabilities(ifEmpty(name, "openai"), client.Provider.Capabilities(), provider.Options{Instructions: s.instructions}, stream); err != nil {
+		return err
+	}
+	s.providerName = ifEmpty(name, "openai")
+	s.client = client
+	return nil
+}
+
+func (s *chatState) setFormat(format string) error {
+	schema, err := parser.ParseFormatSchema(format)
+	if err != nil {
+		return fmt.Errorf("failed to parse format: %w", err)
+	}
+	s.format = format
+	s.properties = schema["properties"].(map[string]interface{})
+	s.required = schema["required"].([]string)
+	return nil
+}
+
+// effectiveModel returns the selected model or the provider default.
+func (s *chatState) effectiveModel() string {
+	return ifEmpty(s.model, s.client.Provider.DefaultOptions().Model)
+}
+
+func (s *chatState) session() *transcript.Session {
+	return &transcript.Session{
+		Provider:     s.providerName,
+		Model:        s.model,
+		Instructions: s.instructions,
+		Format:       s.format,
+		Messages:     s.history,
+	}
+}
+
+// applySession restores the settings and history of a loaded session. Empty
+// settings (e.g., from a plain transcript) keep the current values.
+func (s *chatState) applySession(sess *transcript.Session) error {
+	if sess.Provider != "" && sess.Provider != s.providerName {
+		if err := s.setProvider(sess.Provider); err != nil {
+			return err
+		}
+		s.model = ""
+	}
+	if sess.Model != "" {
+		s.model = sess.Model
+	}
+	if sess.Instructions != "" {
+		s.instructions = sess.Instructions
+	}
+	if sess.Format != "" {
+		if err := s.setFormat(sess.Format); err != nil {
+			return err
+		}
+	}
+	s.history = append([]provider.Turn(nil), sess.Messages...)
+	return nil
+}
+
+// save writes the session to its file, if any.
+func (s *chatState) save() error {
+	if s.sessionPath == "" {
+		return nil
+	}
+	return transcript.SaveSession(s.sessionPath, s.session())
+}
+
+// resolveSessionPath maps a bare session name (no directory, no extension) to
+// <user config dir>/llmx/sessions/<name>.json; anything else is a file path.
+func resolveSessionPath(ref string) (string, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return "", fmt.Errorf("empty session name")
+	}
+	if strings.ContainsAny(ref, `/\`) || filepath.Ext(ref) != "" {
+		return ref, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot locate session directory: %w", err)
+	}
+	return filepath.Join(dir, "llmx", "sessions", ref+".json"), nil
+}
+
+// handleCommand runs a slash command. It reports whether the chat should end.
+func (s *chatState) handleCommand(line string, out io.Writer) (bool, error) {
+	name, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+	arg = strings.TrimSpace(arg)
+	switch name {
+	case "/exit", "/quit":
+		return true, nil
+	case "/help":
+		fmt.Fprintln(out, chatHelp)
+	case "/model":
+		if arg != "" {
+			if arg == "default" {
+				arg = ""
+			}
+			s.model = arg
+		}
+		fmt.Fprintf(out, "model: %s\n", s.effectiveModel())
+	case "/provider":
+		if arg != "" {
+			if err := s.setProvider(arg); err != nil {
+				return false, err
+			}
+			s.model = ""
+		}
+		fmt.Fprintf(out, "provider: %s (model %s)\n", s.providerName, s.effectiveModel())
+	case "/format":
+		if arg != "" {
+			if arg == "off" {
+				arg = ""
+			}
+			if err := s.setFormat(arg); err != nil {
+				return false, err
+			}
+		}
+		if s.format == "" {
+			fmt.Fprintln(out, "format: off (plain text)")
+		} else {
+			fmt.Fprintf(out, "format: %s\n", s.format)
+		}
+	case "/save":
+		if arg != "" {
+			path, err := resolveSessionPath(arg)
+			if err != nil {
+				return false, err
+			}
+			s.sessionPath = path
+		}
+		if s.sessionPath == "" {
+			return false, fmt.Errorf("usage: /save <name|path>")
+		}
+		if err := s.save(); err != nil {
+			return false, err
+		}
+		fmt.Fprintf(out, "saved %d messages to %s\n", len(s.history), s.sessionPath)
+	case "/load":
+		if arg == "" {
+			return false, fmt.Errorf("usage: /load <name|path>")
+		}
+		path, err := resolveSessionPath(arg)
+		if err != nil {
+			return false, err
+		}
+		sess, err := transcript.LoadSession(path)
+		if err != nil {
+			return false, err
+		}
+		if err := s.applySession(sess); err != nil {
+			return false, err
+		}
+		s.sessionPath = path
+		fmt.Fprintf(out, "loaded %d messages from %s (provider %s, model %s)\n", len(s.history), path, s.providerName, s.effectiveModel())
+	case "/reset":
+		s.history = nil
+		if err := s.save(); err != nil {
+			return false, err
+		}
+		fmt.Fprintln(out, "history cleared")
+	default:
+		return false, fmt.Errorf("unknown command %s (type /help)", name)
+	}
+	return false, nil
+}
+
+// send asks the provider for the next reply and records the exchange. In
+// structured mode the reply must decode and validate against the format;
+// rejected replies are not added to the history.
+func (s *chatState) send(ctx context.Context, message string, out, errOut io.Writer) error {
+	opts := provider.Options{
+		Model:           s.effectiveModel(),
+		Instructions:    s.instructions,
+		Message:         message,
+		History:         s.history,
+		Verbosity:       verbosity,
+		ReasoningEffort: reasoningEffort,
+		Properties:      s.properties,
+		Required:        s.required,
+		MaxTokens:       maxTokens,
+		ModelOptions:    modelOptions,
+		KeepAlive:       keepAlive,
+	}
+	structured := len(s.properties) > 0
+
+	var deltaOut io.Writer
+	if _, ok := s.client.Provider.(provider.StreamingProvider); ok && stream {
+		deltaOut = out
+		if structured {
+			deltaOut = errOut
+		}
+	}
+
+	resp, _, err := sendRequest(ctx, s.client, opts, deltaOut)
+	if err != nil {
+		return err
+	}
+
+	reply := resp.Text
+	if structured {
+		obj, err := llmx.DecodeOutput(resp.Text, validator.ObjectSchema(s.properties, s.required))
+		if err != nil {
+			return err
+		}
+		b, err := json.Marshal(obj)
+		if err != nil {
+			return fmt.Errorf("failed to encode output: %w", err)
+		}
+		reply = string(b)
+		fmt.Fprintln(out, reply)
+	} else if deltaOut == nil {
+		fmt.Fprintln(out, strings.TrimRight(reply, "\n"))
+	}
+
+	s.history = append(s.history,
+		provider.Turn{Role: provider.RoleUser, Content: message},
+		provider.Turn{Role: provider.RoleAssistant, Content: reply},
+	)
+	return s.save()
+}
+
+// runChat reads lines from in until EOF or /exit, dispatching slash commands
+// and sending everything else as the next user turn. Errors are reported to
+// errOut and do not end the chat.
+func runChat(ctx context.Context, s *chatState, in io.Reader, out, errOut io.Writer) error {
+	sc := bufio.NewScanner(in)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for {
+		fmt.Fprint(out, "> ")
+		if !sc.Scan() {
+			fmt.Fprintln(out)
+			return sc.Err()
+		}
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "/") {
+			quit, err := s.handleCommand(line, out)
+			if err != nil {
+				fmt.Fprintln(errOut, err)
+			}
+			if quit {
+				return nil
+			}
+			continue
+		}
+		if err := s.send(ctx, line, out, errOut); err != nil {
+			fmt.Fprintln(errOut, err)
+		}
+	}
+}
+
+var chatCmd = &cobra.Command{
+	Use:   "chat",
+	Short: "Chat interactively, keeping the conversation history",
+	Example: strings.TrimSpace(`
+  # Plain text chat with the default provider
+  llmx chat
+
+  # Resume (or start) a named session stored under the user config dir
+  llmx chat --session work
+
+  # Structured replies from Anthropic
+  llmx chat --provider anthropic --format "answer,confidence:number"
+    `),
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := applyProfile(cmd); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if strings.TrimSpace(baseURL) != "" {
+			if u, err := url.Parse(baseURL); err != nil || u.Scheme == "" || u.Host == "" {
+				fmt.Printf("invalid --base-url: %q\nUse a full URL like https://api.example.com\n", baseURL)
+				os.Exit(1)
+			}
+		}
+
+		var err error
+		if modelOptions, err = parseModelOptions(modelOptionSpecs); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if err := loadCostFlags(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		s, err := newChatState(providerName, model, instructions, chatFormat)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		if sessionRef != "" {
+			path, err := resolveSessionPath(sessionRef)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			s.sessionPath = path
+			if _, statErr := os.Stat(path); statErr == nil {
+				sess, err := transcript.LoadSession(path)
+				if err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+				if err := s.applySession(sess); err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+				// Explicit flags override the saved settings.
+				if cmd.Flags().Changed("provider") {
+					if err := s.setProvider(providerName); err != nil {
+						fmt.Println(err)
+						os.Exit(1)
+					}
+					s.model = ""
+				}
+				if cmd.Flags().Changed("model") {
+					s.model = model
+				}
+				if cmd.Flags().Changed("instructions") {
+					s.instructions = instructions
+				}
+				if cmd.Flags().Changed("format") {
+					if err := s.setFormat(chatFormat); err != nil {
+						fmt.Println(err)
+						os.Exit(1)
+					}
+				}
+				fmt.Fprintf(os.Stderr, "resumed %d messages from %s\n", len(s.history), path)
+			}
+		}
+
+		warnIgnoredFlags(cmd, s.providerName, s.client.Provider.Capabilities())
+		fmt.Fprintf(os.Stderr, "llmx chat (%s, %s). Type /help for commands, /exit or Ctrl-D to quit.\n", s.providerName, s.effectiveModel())
+		if err := runChat(cmd.Context(), s, os.Stdin, os.Stdout, os.Stderr); err != nil {
+			fmt.Println("failed to read input:", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(chatCmd)
+
+	chatCmd.Flags().StringVar(&profileName, "profile", "", "config file profile to use (default $LLMX_PROFILE or default_profile)")
+	chatCmd.Flags().StringVar(&providerName, "provider", "openai", "LLM provider name (e.g., openai)")
+	chatCmd.Flags().StringVar(&model, "model", "", "model name (provider default if empty)")
+	chatCmd.Flags().StringVar(&instructions, "instructions", "", "instructions to guide the model")
+	chatCmd.Flags().StringVar(&chatFormat, "format", "", "output format shorthand for structured replies (plain text if empty)")
+	chatCmd.Flags().StringVar(&sessionRef, "session", "", "session name or file to resume and save after each reply")
+	chatCmd.Flags().StringVar(&baseURL, "base-url", "", "override base URL (provider default if empty)")
+	addRequestFlags(chatCmd)
+	chatCmd.Flags().IntVar(&maxTokens, "max-tokens", 0, "max output tokens (override; provider default if 0)")
+	addModelFlags(chatCmd)
+	addCostFlags(chatCmd, "refuse to send a message whose estimated input cost, including the history, exceeds this many USD (0 = no limit)")
+	chatCmd.Flags().BoolVar(&stream, "stream", false, "stream replies over SSE as they arrive")
+	chatCmd.Flags().BoolVar(&verbose, "verbose", false, "enable verbose debug logging to stderr")
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"llmx/pkg/provider"
+)
+
+func TestChatState_HandleCommand(t *testing.T) {
+	s, err := newChatState("openai", "", "", "")
+	if err != nil {
+		t.Fatalf("newChatState: %v", err)
+	}
+	var out strings.Builder
+
+	if _, err := s.handleCommand("/model gpt-test", &out); err != nil || s.effectiveModel() != "gpt-test" {
+		t.Fatalf("/model: model=%q err=%v", s.effectiveModel(), err)
+	}
+	if _, err := s.handleCommand("/provider anthropic", &out); err != nil {
+		t.Fatalf("/provider: %v", err)
+	}
+	if s.providerName != "anthropic" || s.model != "" {
+		t.Fatalf("/provider should switch provider and reset model: %q %q", s.providerName, s.model)
+	}
+	if _, err := s.handleCommand("/provider nope", &out); err == nil {
+		t.Fatalf("expected error for unknown provider")
+	}
+	if _, err := s.handleCommand("/format answer,score:number", &out); err != nil || len(s.properties) != 2 {
+		t.Fatalf("/format: props=%v err=%v", s.properties, err)
+	}
+	if _, err := s.handleCommand("/format a:{", &out); err == nil || s.format != "answer,score:number" {
+		t.Fatalf("invalid /format should be rejected and keep the old format: %q err=%v", s.format, err)
+	}
+	if _, err := s.handleCommand("/format off", &out); err != nil || len(s.properties) != 0 {
+		t.Fatalf("/format off: props=%v err=%v", s.properties, err)
+	}
+	if _, err := s.handleCommand("/save", &out); err == nil {
+		t.Fatalf("expected usage error for /save without a session file")
+	}
+	if _, err := s.handleCommand("/bogus", &out); err == nil {
+		t.Fatalf("expected error for unknown command")
+	}
+	if quit, _ := s.handleCommand("/exit", &out); !quit {
+		t.Fatalf("/exit should end the chat")
+	}
+}
+
+func TestRunChat_HistoryAndSessions(t *testing.T) {
+	var requests []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &payload)
+		requests = append(requests, payload)
+		reply := "reply " + string(rune('0'+len(requests)))
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []interface{}{map[string]interface{}{"message": map[string]interface{}{"content": reply}}},
+		})
+	}))
+	defer srv.Close()
+
+	oldBaseURL := baseURL
+	baseURL = srv.URL
+	defer func() { baseURL = oldBaseURL }()
+	t.Setenv("OPENAI_API_KEY", "test")
+
+	s, err := newChatState("openai-compat", "", "", "")
+	if err != nil {
+		t.Fatalf("newChatState: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "session.json")
+	in := strings.NewReader("hello\nagain\n/save " + path + "\n")
+	var out, errOut strings.Builder
+	if err := runChat(context.Background(), s, in, &out, &errOut); err != nil {
+		t.Fatalf("runChat: %v", err)
+	}
+	if errOut.Len() != 0 {
+		t.Fatalf("unexpected errors: %s", errOut.String())
+	}
+	if !strings.Contains(out.String(), "reply 1") || !strings.Contains(out.String(), "reply 2") {
+		t.Fatalf("replies missing from output: %q", out.String())
+	}
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(requests))
+	}
+	if msgs := requests[1]["messages"].([]interface{}); len(msgs) != 3 {
+		t.Fatalf("second request should carry the first exchange: %v", msgs)
+	}
+
+	// Resume the saved session in a fresh chat.
+	s, err = newChatState("gemini", "", "", "")
+	if err != nil {
+		t.Fatalf("newChatState: %v", err)
+	}
+	if _, err := s.handleCommand("/load "+path, &out); err != nil {
+		t.Fatalf("/load: %v", err)
+	}
+	want := []provider.Turn{
+		{Role: provider.RoleUser, Content: "hello"},
+		{Role: provider.RoleAssistant, Content: "reply 1"},
+		{Role: provider.RoleUser, Content: "again"},
+		{Role: provider.RoleAssistant, Content: "reply 2"},
+	}
+	if !reflect.DeepEqual(s.history, want) {
+		t.Fatalf("history after /load = %v, want %v", s.history, want)
+	}
+	if s.providerName != "openai-compat" {
+		t.Fatalf("provider not restored: %q", s.providerName)
+	}
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+
+	"llmx/pkg/config"
+
+	"github.com/spf13/cobra"
+)
+
+var profileName string
+
+// profileSetting is a flag that an environment variable or a profile can
+// default.
+type profileSetting struct {
+	flag  string
+	env   string
+	value func(config.Profile) string
+	// apiKey marks the API key sources; a profile's source is skipped when
+	// any of them is given on the command line.
+	apiKey bool
+}
+
+var profileSettings = []profileSetting{
+	{"provider", "LLMX_PROVIDER", func(p config.Profile) string { return p.Provider }, false},
+	{"model", "LLMX_MODEL", func(p config.Profile) string { return p.Model }, false},
+	{"base-url", "LLMX_BASE_URL", func(p config.Profile) string { return p.BaseURL }, false},
+	{"instructions", "", func(p config.Profile) string { return p.Instructions }, false},
+	{"format", "", func(p config.Profile) string { return p.Format }, false},
+	{"max-tokens", "", func(p config.Profile) string {
+		if p.MaxTokens == 0 {
+			return ""
+		}
+		return strconv.Itoa(p.MaxTokens)
+	}, false},
+	{"api-key-env", "", func(p config.Profile) string { return p.APIKeyEnv }, true},
+	{"api-key-file", "", func(p config.Profile) string { return p.APIKeyFile }, true},
+	{"api-key-cmd", "", func(p config.Profile) string { return p.APIKeyCmd }, true},
+}
+
+// loadProfile returns the selected profile: --profile, then $LLMX_PROFILE,
+// then the config file's default_profile. The config file is
+// $LLMX_CONFIG or config.DefaultPath(); a missing default file is not an
+// error unless a profile was asked for.
+func loadProfile() (config.Profile, bool, error) {
+	name := ifEmpty(profileName, os.Getenv("LLMX_PROFILE"))
+	path := os.Getenv("LLMX_CONFIG")
+	if path == "" {
+		var err error
+		if path, err = config.DefaultPath(); err != nil {
+			return config.Profile{}, false, nil
+		}
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			if name != "" {
+				return config.Profile{}, false, fmt.Errorf("profile %q requested but %s does not exist", name, path)
+			}
+			return config.Profile{}, false, nil
+		}
+	}
+	f, err := config.Load(path)
+	if err != nil {
+		return config.Profile{}, false, err
+	}
+	return f.Profile(name)
+}
+
+// applyProfile fills the command's flags that were not given on the command
+// line, with precedence flags > environment > profile > provider defaults.
+// Values are set without marking the flags as changed. The profile's headers
+// are merged under --header.
+func applyProfile(cmd *cobra.Command) error {
+	p, ok, err := loadProfile()
+	if err != nil {
+		return err
+	}
+	keyFlagGiven := false
+	for _, s := range profileSettings {
+		keyFlagGiven = keyFlagGiven || s.apiKey && cmd.Flags().Changed(s.flag)
+	}
+	for _, s := range profileSettings {
+		f := cmd.Flags().Lookup(s.flag)
+		if f == nil || f.Changed || s.apiKey && keyFlagGiven {
+			continue
+		}
+		val := ""
+		if s.env != "" {
+			val = os.Getenv(s.env)
+		}
+		if val == "" && ok {
+			val = s.value(p)
+		}
+		if val == "" {
+			continue
+		}
+		if err := f.Value.Set(val); err != nil {
+			return fmt.Errorf("invalid %s %q: %w", s.flag, val, err)
+		}
+	}
+
+	headers := map[string]string{}
+	if ok {
+		for k, v := range p.Headers {
+			headers[k] = v
+		}
+	}
+	for _, h := range headerSpecs {
+		k, v, err := parseHeader(h)
+		if err != nil {
+			return err
+		}
+		headers[k] = v
+	}
+	extraHeaders = headers
+	return nil
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"llmx/pkg/provider"
+
+	"github.com/spf13/cobra"
+)
+
+func TestApplyProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(`
+default_profile = "work"
+
+[profiles.work]
+provider = "anthropic"
+model = "claude-sonnet-4-0"
+base_url = "https://gateway.example.com"
+api_key_env = "WORK_KEY"
+max_tokens = 2048
+headers = { X-Team = "search" }
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("LLMX_CONFIG", path)
+	t.Setenv("LLMX_PROFILE", "")
+	t.Setenv("LLMX_BASE_URL", "https://env.example.com")
+	t.Setenv("LLMX_PROVIDER", "")
+	t.Setenv("LLMX_MODEL", "")
+	resetRequestGlobals(t)
+	oldProfile := profileName
+	t.Cleanup(func() { profileName = oldProfile })
+
+	var prov, mod, base string
+	var tokens int
+	cmd := &cobra.Command{Use: "test"}
+	cmd.Flags().StringVar(&prov, "provider", "openai", "")
+	cmd.Flags().StringVar(&mod, "model", "", "")
+	cmd.Flags().StringVar(&base, "base-url", "", "")
+	cmd.Flags().IntVar(&tokens, "max-tokens", 0, "")
+	addRequestFlags(cmd)
+	if err := cmd.Flags().Parse([]string{"--model", "claude-opus-4-1", "--header", "x-trace: on"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := applyProfile(cmd); err != nil {
+		t.Fatalf("applyProfile: %v", err)
+	}
+	// flag > env > profile > default
+	if prov != "anthropic" || mod != "claude-opus-4-1" || base != "https://env.example.com" || tokens != 2048 {
+		t.Fatalf("settings = %q %q %q %d", prov, mod, base, tokens)
+	}
+	if cmd.Flags().Changed("provider") {
+		t.Errorf("profile values must not mark flags as changed")
+	}
+	if apiKeyEnv != "WORK_KEY" || extraHeaders["X-Team"] != "search" || extraHeaders["X-Trace"] != "on" {
+		t.Fatalf("apiKeyEnv = %q, headers = %v", apiKeyEnv, extraHeaders)
+	}
+
+	t.Setenv("WORK_KEY", "")
+	if _, err := resolveAPIKey(); !errors.Is(err, provider.ErrMissingAPIKey) {
+		t.Fatalf("expected missing key error for WORK_KEY, got %v", err)
+	}
+	t.Setenv("WORK_KEY", "secret")
+	if key, err := resolveAPIKey(); err != nil || key != "secret" {
+		t.Fatalf("resolveAPIKey() = %q, %v", key, err)
+	}
+
+	// A key source on the command line replaces the profile's.
+	resetRequestGlobals(t)
+	if err := cmd.Flags().Set("api-key-file", "key.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyProfile(cmd); err != nil || apiKeyEnv != "" {
+		t.Fatalf("profile api_key_env should be skipped: %q, %v", apiKeyEnv, err)
+	}
+
+	profileName = "home"
+	if err := applyProfile(cmd); err == nil {
+		t.Fatalf("expected error for unknown profile")
+	}
+}
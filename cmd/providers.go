@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"llmx/pkg/provider"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	providersJSON bool
+	// modelOptionSpecs are the --option values ("key=value").
+	modelOptionSpecs []string
+	// modelOptions are the parsed --option values.
+	modelOptions map[string]interface{}
+	keepAlive    string
+)
+
+// addModelFlags registers the --option and --keep-alive flags on cmd.
+func addModelFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&modelOptionSpecs, "option", nil, "set a model runtime option as key=value, e.g. num_ctx=8192 or temperature=0.2 (Ollama only; repeatable)")
+	cmd.Flags().StringVar(&keepAlive, "keep-alive", "", "keep the model loaded this long after the request, e.g. 10m, 0 or -1 (Ollama only)")
+}
+
+// parseModelOptions parses --option values. A value that decodes as JSON
+// (a number, boolean, array or object) keeps its type; any other value is a
+// string.
+func parseModelOptions(specs []string) (map[string]interface{}, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+	opts := make(map[string]interface{}, len(specs))
+	for _, spec := range specs {
+		key, raw, ok := strings.Cut(spec, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid --option %q: use key=value", spec)
+		}
+		var val interface{}
+		if err := json.Unmarshal([]byte(raw), &val); err != nil || val == nil {
+			val = raw
+		}
+		opts[key] = val
+	}
+	return opts, nil
+}
+
+// providerInfo is a row of `llmx providers`.
+type providerInfo struct {
+	Name         string `json:"name"`
+	DefaultModel string `json:"default_model"`
+	provider.Capabilities
+}
+
+var providersCmd = &cobra.Command{
+	Use:   "providers",
+	Short: "List the supported providers and their capabilities",
+	Long: strings.TrimSpace(`
+List the supported providers with their default model and what each one
+supports: native JSON schema enforcement ("local" when output is only
+validated by llmx), streaming, tool calling, images, reasoning controls
+(--verbosity, --reasoning-effort), runtime controls (--option,
+--keep-alive), system prompts and the default model's context window.
+    `),
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		infos := make([]providerInfo, 0, len(provider.Names()))
+		for _, name := range provider.Names() {
+			p, err := provider.New(name)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			infos = append(infos, providerInfo{Name: name, DefaultModel: p.DefaultOptions().Model, Capabilities: p.Capabilities()})
+		}
+		if providersJSON {
+			b, _ := json.MarshalIndent(infos, "", "  ")
+			fmt.Println(string(b))
+			return
+		}
+		printProviders(os.Stdout, infos)
+	},
+}
+
+// printProviders writes infos as a table.
+func printProviders(w io.Writer, infos []providerInfo) {
+	yesNo := func(b bool) string {
+		if b {
+			return "yes"
+		}
+		return "no"
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROVIDER\tSCHEMA\tSTREAMING\tTOOLS\tVISION\tREASONING\tRUNTIME\tSYSTEM\tCONTEXT\tDEFAULT MODEL")
+	for _, info := range infos {
+		schema := "local"
+		if info.NativeSchema {
+			schema = "native"
+		}
+		var reasoning []string
+		if info.Verbosity {
+			reasoning = append(reasoning, "verbosity")
+		}
+		if info.ReasoningEffort {
+			reasoning = append(reasoning, "effort")
+		}
+		var runtime []string
+		if info.ModelOptions {
+			runtime = append(runtime, "options")
+		}
+		if info.KeepAlive {
+			runtime = append(runtime, "keep-alive")
+		}
+		window := "-"
+		if info.MaxContextTokens > 0 {
+			window = strconv.Itoa(info.MaxContextTokens)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", info.Name, schema, yesNo(info.Streaming), yesNo(info.Tools),
+			yesNo(info.Vision), ifEmpty(strings.Join(reasoning, ","), "-"), ifEmpty(strings.Join(runtime, ","), "-"),
+			yesNo(info.SystemPrompt), window, info.DefaultModel)
+	}
+	_ = tw.Flush()
+}
+
+// checkCapabilities rejects options that the provider called name cannot
+// honor instead of letting it silently drop them.
+func checkCapabilities(name string, caps provider.Capabilities, opts provider.Options, streaming bool) error {
+	switch {
+	case streaming && !caps.Streaming:
+		return fmt.Errorf("provider %s does not support streaming", name)
+	case len(opts.Tools) > 0 && !caps.Tools:
+		return fmt.Errorf("provider %s does not support tool calling", name)
+	case len(opts.Images) > 0 && !caps.Vision:
+		return fmt.Errorf("provider %s does not support images", name)
+	case !caps.SystemPrompt && (strings.TrimSpace(opts.Instructions) != "" || hasSystemTurn(opts.History)):
+		return fmt.Errorf("provider %s does not support system prompts", name)
+	}
+	return nil
+}
+
+func hasSystemTurn(history []provider.Turn) bool {
+	for _, t := range history {
+		if t.Role == provider.RoleSystem {
+			return true
+		}
+	}
+	return false
+}
+
+// warnIgnoredFlags warns on stderr about flags given on the command line
+// that the provider called name ignores, naming the providers that honor
+// them.
+func warnIgnoredFlags(cmd *cobra.Command, name string, caps provider.Capabilities) {
+	for _, f := range []struct {
+		flag      string
+		supported func(provider.Capabilities) bool
+	}{
+		{"verbosity", func(c provider.Capabilities) bool { return c.Verbosity }},
+		{"reasoning-effort", func(c provider.Capabilities) bool { return c.ReasoningEffort }},
+		{"option", func(c provider.Capabilities) bool { return c.ModelOptions }},
+		{"keep-alive", func(c provider.Capabilities) bool { return c.KeepAlive }},
+	} {
+		if !cmd.Flags().Changed(f.flag) || f.supported(caps) {
+			continue
+		}
+		var by []string
+		for _, n := range provider.Names() {
+			if p, err := provider.New(n); err == nil && f.supported(p.Capabilities()) {
+				by = append(by, n)
+			}
+		}
+		fmt.Fprintf(os.Stderr, "warning: %s ignores --%s (supported by: %s)\n", name, f.flag, strings.Join(by, ", "))
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(providersCmd)
+	providersCmd.Flags().BoolVar(&providersJSON, "json", false, "print the capabilities as JSON")
+}
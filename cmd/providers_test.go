@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"llmx/pkg/provider"
+)
+
+func TestCheckCapabilities(t *testing.T) {
+	none := provider.Capabilities{}
+	all := provider.Capabilities{Streaming: true, Tools: true, Vision: true, SystemPrompt: true}
+	tests := []struct {
+		name      string
+		caps      provider.Capabilities
+		opts      provider.Options
+		streaming bool
+		want      string
+	}{
+		{name: "plain request", caps: none, opts: provider.Options{Message: "hi"}},
+		{name: "everything supported", caps: all, opts: provider.Options{Instructions: "be brief", Tools: []provider.Tool{{Name: "f"}}, Images: []provider.Image{{URL: "https://x/y.png"}}}, streaming: true},
+		{name: "streaming", caps: none, streaming: true, want: "does not support streaming"},
+		{name: "tools", caps: none, opts: provider.Options{Tools: []provider.Tool{{Name: "f"}}}, want: "does not support tool calling"},
+		{name: "images", caps: none, opts: provider.Options{Images: []provider.Image{{URL: "https://x/y.png"}}}, want: "does not support images"},
+		{name: "instructions", caps: none, opts: provider.Options{Instructions: "be brief"}, want: "does not support system prompts"},
+		{name: "system turn", caps: none, opts: provider.Options{History: []provider.Turn{{Role: provider.RoleSystem, Content: "be brief"}}}, want: "does not support system prompts"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkCapabilities("p", tt.caps, tt.opts, tt.streaming)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrintProviders(t *testing.T) {
+	var b bytes.Buffer
+	printProviders(&b, []providerInfo{
+		{Name: "openai", DefaultModel: "gpt-5-nano", Capabilities: provider.Capabilities{NativeSchema: true, Streaming: true, Verbosity: true, ReasoningEffort: true, MaxContextTokens: 400000}},
+		{Name: "openai-compat", DefaultModel: "gpt-4o-mini", Capabilities: provider.Capabilities{Tools: true}},
+		{Name: "ollama", DefaultModel: "llama3.2", Capabilities: provider.Capabilities{NativeSchema: true, ModelOptions: true, KeepAlive: true}},
+	})
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "PROVIDER") {
+		t.Fatalf("unexpected table:\n%s", b.String())
+	}
+	if got := strings.Fields(lines[1]); strings.Join(got, " ") != "openai native yes no no verbosity,effort - no 400000 gpt-5-nano" {
+		t.Fatalf("openai row = %q", lines[1])
+	}
+	if got := strings.Fields(lines[2]); strings.Join(got, " ") != "openai-compat local no yes no - - no - gpt-4o-mini" {
+		t.Fatalf("openai-compat row = %q", lines[2])
+	}
+	if got := strings.Fields(lines[3]); strings.Join(got, " ") != "ollama native no no no - options,keep-alive no - llama3.2" {
+		t.Fatalf("ollama row = %q", lines[3])
+	}
+}
+
+func TestParseModelOptions(t *testing.T) {
+	got, err := parseModelOptions([]string{"num_ctx=8192", "temperature=0.2", "stop=[\"\\n\"]", "mirostat=true", "name=llama", " seed =", "x=null"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]interface{}{
+		"num_ctx":     float64(8192),
+		"temperature": 0.2,
+		"stop":        []interface{}{"\n"},
+		"mirostat":    true,
+		"name":        "llama",
+		"seed":        "",
+		"x":           "null",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v, want %#v", got, want)
+	}
+	for _, bad := range []string{"num_ctx", "=1"} {
+		if _, err := parseModelOptions([]string{bad}); err == nil {
+			t.Fatalf("expected an error for %q", bad)
+		}
+	}
+	if got, err := parseModelOptions(nil); got != nil || err != nil {
+		t.Fatalf("no options: %v, %v", got, err)
+	}
+}
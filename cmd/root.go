@@ -1,18 +1,25 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	netpkg "net"
-	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
+	"slices"
 	"strings"
+	"syscall"
 
+	"llmx/pkg/attachment"
+	"llmx/pkg/llmx"
 	"llmx/pkg/parser"
+	"llmx/pkg/pricing"
 	"llmx/pkg/provider"
+	"llmx/pkg/tools"
+	"llmx/pkg/transcript"
 	"llmx/pkg/version"
 
 	"github.com/spf13/cobra"
@@ -32,22 +39,45 @@ func ifZero(val, fallback int) int {
 	return val
 }
 
-// stripForJsonMarshal removes surrounding Markdown code fences and leading/trailing whitespace
-// to prepare a string for JSON marshalling.
-func stripForJsonMarshal(s string) string {
-	t := strings.TrimSpace(s)
-	if !strings.HasPrefix(t, "```") || !strings.HasSuffix(t, "```") {
-		return t
-	}
-	head := strings.IndexByte(t, '\n')
-	if head < 0 {
-		return t
-	}
-	tail := strings.LastIndex(t, "```")
-	if tail < 0 || tail <= head {
-		return t
+// Exit codes for failures callers may want to tell apart. Other failures exit 1.
+const (
+	// exitSchemaViolation means the model returned JSON that does not match
+	// the requested --format/--schema.
+	exitSchemaViolation = 3
+	// exitTruncated means the output was cut off at the max tokens limit.
+	exitTruncated = 4
+	// exitRefused means the model refused or the provider blocked the response.
+	exitRefused = 5
+	// exitOverBudget means a request was not sent because its estimated cost
+	// exceeds --max-cost.
+	exitOverBudget = 6
+	// exitTimeout means a request ran past --timeout.
+	exitTimeout = 7
+	// exitInterrupted means a request was canceled by SIGINT or SIGTERM
+	// (128 + SIGINT, as shells report it).
+	exitInterrupted = 130
+)
+
+// errInterrupted is returned when SIGINT or SIGTERM cancels a request.
+var errInterrupted = errors.New("interrupted")
+
+// exitCodeFor returns the exit code for a failed request.
+func exitCodeFor(err error) int {
+	switch {
+	case errors.As(err, new(llmx.SchemaError)):
+		return exitSchemaViolation
+	case errors.Is(err, provider.ErrTruncated):
+		return exitTruncated
+	case errors.Is(err, provider.ErrRefused):
+		return exitRefused
+	case errors.Is(err, llmx.ErrOverBudget):
+		return exitOverBudget
+	case errors.Is(err, llmx.ErrTimeout):
+		return exitTimeout
+	case errors.Is(err, errInterrupted):
+		return exitInterrupted
 	}
-	return strings.TrimSpace(t[head+1 : tail])
+	return 1
 }
 
 var (
@@ -55,15 +85,232 @@ var (
 	reasoningEffort string
 	verbosity       string
 	verbose         bool
+	noValidate      bool
 	instructions    string
 	format          string
+	schemaRef       string
 	errorKey        string
 	baseURL         string
 	onlyKey         string
 	providerName    string
 	maxTokens       int
+	retries         int
+	stream          bool
+	messagesFile    string
+	imageRefs       []string
+	filePaths       []string
+	toolSpecs       []string
+	toolsFile       string
+	toolChoice      string
+	showUsage       bool
+	withMeta        bool
+	maxCost         float64
+	pricingFile     string
+	// prices is the pricing table for cost estimates (built-in plus
+	// --pricing/LLMX_PRICING).
+	prices pricing.Table
 )
 
+// sendRequest sends a single request with c. SIGINT and SIGTERM cancel it
+// with errInterrupted instead of killing the process.
+func sendRequest(ctx context.Context, c *llmx.Client, opts provider.Options, deltaOut io.Writer) (provider.Response, []provider.ToolCall, error) {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	resp, calls, err := c.Send(ctx, opts, deltaOut)
+	return resp, calls, explainError(err)
+}
+
+// generate runs req with c, canceled by SIGINT and SIGTERM like sendRequest.
+func generate(ctx context.Context, c *llmx.Client, req llmx.Request) (llmx.Response, error) {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	resp, err := c.Generate(ctx, req)
+	return resp, explainError(err)
+}
+
+// explainError adds what to do about a failed request, and reports a
+// request canceled by a signal as errInterrupted.
+func explainError(err error) error {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return errInterrupted
+	case errors.Is(err, llmx.ErrTimeout):
+		return fmt.Errorf("%w (--timeout)", err)
+	case errors.Is(err, provider.ErrTruncated):
+		return fmt.Errorf("%w\nRaise --max-tokens to allow a complete answer.", err)
+	}
+	return err
+}
+
+// addCostFlags registers --max-cost, described by usage, and --pricing on
+// cmd.
+func addCostFlags(cmd *cobra.Command, usage string) {
+	cmd.Flags().Float64Var(&maxCost, "max-cost", 0, usage)
+	cmd.Flags().StringVar(&pricingFile, "pricing", "", "JSON file of model prices overriding the built-in table (default $LLMX_PRICING)")
+}
+
+// loadCostFlags checks --max-cost and loads the pricing table into prices.
+func loadCostFlags() error {
+	if maxCost < 0 {
+		return errors.New("--max-cost must be >= 0")
+	}
+	var err error
+	prices, err = loadPricing(pricingFile)
+	return err
+}
+
+// loadPricing returns the built-in pricing table with the entries of path
+// (or $LLMX_PRICING) applied on top.
+func loadPricing(path string) (pricing.Table, error) {
+	t := pricing.Default()
+	path = ifEmpty(path, os.Getenv("LLMX_PRICING"))
+	if strings.TrimSpace(path) == "" {
+		return t, nil
+	}
+	overrides, err := pricing.LoadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	t.Merge(overrides)
+	return t, nil
+}
+
+// splitTranscript separates the turns to send as history from the final user
+// message. A non-empty message is appended after all turns; otherwise the
+// transcript's last turn must be a user turn and becomes the message.
+func splitTranscript(turns []provider.Turn, message string) ([]provider.Turn, string, error) {
+	if strings.TrimSpace(message) != "" {
+		return turns, message, nil
+	}
+	if len(turns) == 0 || turns[len(turns)-1].Role != provider.RoleUser {
+		return nil, "", fmt.Errorf("transcript must end with a user turn (or pass a message)")
+	}
+	last := len(turns) - 1
+	return turns[:last], turns[last].Content, nil
+}
+
+// loadTools builds the tools declared with --tool specs and a --tools file.
+func loadTools(specs []string, file string) ([]provider.Tool, error) {
+	var defs []tools.Definition
+	if file != "" {
+		fromFile, err := tools.LoadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		defs = append(defs, fromFile...)
+	}
+	for _, spec := range specs {
+		d, err := tools.ParseSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+		defs = append(defs, d)
+	}
+	return tools.Build(defs)
+}
+
+// checkToolChoice validates --tool-choice against the declared tools.
+func checkToolChoice(choice string, declared []provider.Tool) error {
+	switch choice {
+	case "", provider.ToolChoiceAuto, provider.ToolChoiceNone, provider.ToolChoiceRequired:
+		return nil
+	}
+	for _, t := range declared {
+		if t.Name == choice {
+			return nil
+		}
+	}
+	return fmt.Errorf("--tool-choice %q is not auto, required, none or a declared tool", choice)
+}
+
+// renderOutput formats a structured output for printing: the value of the
+// only key when set (strings raw, other values as JSON), otherwise the whole
+// object as compact JSON.
+func renderOutput(obj map[string]interface{}, required []string, only string) (string, error) {
+	val, err := selectOutput(obj, required, only)
+	if err != nil {
+		return "", err
+	}
+	return formatValue(val)
+}
+
+// selectOutput returns the value to print: obj itself, or its only key.
+func selectOutput(obj map[string]interface{}, required []string, only string) (interface{}, error) {
+	if only == "" {
+		return obj, nil
+	}
+	val, hasOnly := obj[only]
+	if !hasOnly && !slices.Contains(required, only) {
+		// Omitted optional keys print as null.
+		val, hasOnly = nil, true
+	}
+	if !hasOnly {
+		return nil, fmt.Errorf("key not found: %s", only)
+	}
+	return val, nil
+}
+
+// formatValue renders strings raw and everything else as compact JSON.
+func formatValue(val interface{}) (string, error) {
+	if v, ok := val.(string); ok {
+		return v, nil
+	}
+	b, err := json.Marshal(val)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode output: %w", err)
+	}
+	return string(b), nil
+}
+
+// printUsage writes token usage, response metadata and the estimated cost to
+// w (--usage).
+func printUsage(w io.Writer, meta llmx.Response) {
+	u := meta.Usage
+	fmt.Fprintf(w, "usage: %d input tokens (%d cached), %d output tokens (%d reasoning)", u.InputTokens, u.CachedTokens, u.OutputTokens, u.ReasoningTokens)
+	if meta.FinishReason != "" {
+		fmt.Fprintf(w, "; finish: %s", meta.FinishReason)
+	}
+	if meta.Model != "" {
+		fmt.Fprintf(w, "; model: %s", meta.Model)
+	}
+	if meta.ID != "" {
+		fmt.Fprintf(w, "; id: %s", meta.ID)
+	}
+	if meta.CostUSD != nil {
+		fmt.Fprintf(w, "; cost: $%.6f", *meta.CostUSD)
+	}
+	fmt.Fprintln(w)
+}
+
+// printOutput writes the final output to stdout, as text or, with --meta,
+// wrapped as {"output": val, "meta": {...}}. --usage also reports meta on
+// stderr.
+func printOutput(val interface{}, meta llmx.Response) {
+	if showUsage {
+		printUsage(os.Stderr, meta)
+	}
+	var (
+		textOut string
+		err     error
+	)
+	if withMeta {
+		var b []byte
+		b, err = json.Marshal(map[string]interface{}{"output": val, "meta": meta})
+		textOut = string(b)
+	} else {
+		textOut, err = formatValue(val)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	// Ensure output ends with a single newline
+	if !strings.HasSuffix(textOut, "\n") {
+		textOut += "\n"
+	}
+	fmt.Print(textOut)
+}
+
 var rootCmd = &cobra.Command{
 	Use:   "llmx [flags] [\"your message\"|-]",
 	Short: "Send a message to the LLM API",
@@ -82,6 +329,22 @@ var rootCmd = &cobra.Command{
   # Structured JSON (OpenAI). Only print one key
   llmx --format "name:string,age:integer" "Alice is 14."
   llmx --format "command:string,explanation:string" --only command "Turn this into a shell command: list go files"
+
+  # Continue a conversation from a transcript (JSON array or JSONL)
+  llmx --messages chat.jsonl "And in French?"
+
+  # Extract fields from a screenshot or receipt (repeat --image for more)
+  llmx --image receipt.jpg --format "merchant,total:number,date" "Extract the receipt fields"
+
+  # Extract data from a PDF contract or invoice
+  llmx --file invoice.pdf --format "vendor,total:number,due_date" "Extract the invoice fields"
+
+  # Offer tools and print the calls the model makes
+  llmx --tool "get_weather=city:string,unit:enum(c|f)" "What's the weather in Paris?"
+  llmx --tools tools.json --tool-choice required "Book a table for two at 7pm"
+
+  # Full JSON Schema from a file
+  llmx --schema @invoice.schema.json - < invoice.txt
     `),
 	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
@@ -100,7 +363,7 @@ var rootCmd = &cobra.Command{
 			// If no arg, check whether stdin has piped input
 			if fi, _ := os.Stdin.Stat(); fi.Mode()&os.ModeCharDevice == 0 {
 				shouldReadStdin = true
-			} else {
+			} else if messagesFile == "" {
 				// No piped input; show help like `llmx -h`
 				_ = cmd.Help()
 				return
@@ -116,29 +379,99 @@ var rootCmd = &cobra.Command{
 			message = string(stdinBytes)
 		}
 
+		// Load prior turns from a transcript (--messages).
+		var history []provider.Turn
+		if messagesFile != "" {
+			turns, err := transcript.Load(messagesFile)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			history, message, err = splitTranscript(turns, message)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		}
+
+		// Load images attached to the message (--image).
+		var images []provider.Image
+		for _, ref := range imageRefs {
+			img, err := attachment.LoadImage(ref)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			images = append(images, img)
+		}
+		// Load documents attached to the message (--file).
+		var files []provider.File
+		for _, path := range filePaths {
+			f, err := attachment.LoadFile(path)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			files = append(files, f)
+		}
+
 		// Select provider
-		prov, err := provider.New(providerName)
+		if err := applyProfile(cmd); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if err := loadCostFlags(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		client, err := newClient(providerName)
 		if err != nil {
 			// Unknown provider: print supported list for clarity
 			var up provider.ErrUnknownProvider
 			if errors.As(err, &up) {
-				fmt.Printf("unknown provider: %s\nSupported providers: openai, openai-compat, anthropic, gemini\n", providerName)
+				fmt.Printf("unknown provider: %s\nSupported providers: %s\n", providerName, strings.Join(provider.Names(), ", "))
 			} else {
 				fmt.Println(err)
 			}
 			os.Exit(1)
 		}
 
-		// Always build properties (format).
-		properties, err := parser.ParseFormat(format)
-		if err != nil {
-			fmt.Printf("failed to parse format: %v\n", err)
-			os.Exit(1)
+		// Always build properties (format), or load a full JSON Schema (--schema).
+		var (
+			properties map[string]interface{}
+			required   []string
+			fullSchema map[string]interface{}
+		)
+		if strings.TrimSpace(schemaRef) != "" {
+			if cmd.Flags().Changed("format") {
+				fmt.Println("use either --format or --schema, not both")
+				os.Exit(1)
+			}
+			fullSchema, err = parser.LoadSchema(schemaRef)
+			if err != nil {
+				fmt.Printf("failed to load schema: %v\n", err)
+				os.Exit(1)
+			}
+			properties = fullSchema["properties"].(map[string]interface{})
+			required = parser.RequiredKeys(fullSchema)
+			if r, ok := client.Provider.(provider.SchemaKeywordReporter); ok {
+				if unsupported := r.UnsupportedSchemaKeywords(fullSchema); len(unsupported) > 0 {
+					fmt.Fprintf(os.Stderr, "warning: %s cannot honor JSON Schema keywords: %s\n", providerName, strings.Join(unsupported, ", "))
+				}
+			}
+		} else {
+			schema, err := parser.ParseFormatSchema(format)
+			if err != nil {
+				fmt.Printf("failed to parse format: %v\n", err)
+				os.Exit(1)
+			}
+			properties = schema["properties"].(map[string]interface{})
+			required = schema["required"].([]string)
 		}
 		// If a custom --error-key is provided, require that the schema includes it.
 		if strings.TrimSpace(errorKey) != "" && errorKey != "error" {
 			if _, hasCustom := properties[errorKey]; !hasCustom {
-				fmt.Printf("--error-key %q not found in --format schema. Include it in --format.\n", errorKey)
+				fmt.Printf("--error-key %q not found in schema. Include it in --format or --schema.\n", errorKey)
 				os.Exit(1)
 			}
 		}
@@ -146,40 +479,11 @@ var rootCmd = &cobra.Command{
 		// If --only is specified, validate that the key exists in the schema.
 		if onlyKey != "" {
 			if _, hasOnly := properties[onlyKey]; !hasOnly {
-				fmt.Printf("--only %q not found in --format schema. Include it in --format.\n", onlyKey)
+				fmt.Printf("--only %q not found in schema. Include it in --format or --schema.\n", onlyKey)
 				os.Exit(1)
 			}
 		}
 
-		// Merge defaults from provider with CLI options
-		def := prov.DefaultOptions()
-
-		// Build provider payload
-		payload, err := prov.BuildAPIPayload(
-			provider.Options{
-				Model:           ifEmpty(model, def.Model),
-				Instructions:    instructions,
-				Message:         message,
-				Verbosity:       verbosity,
-				ReasoningEffort: reasoningEffort,
-				Properties:      properties,
-				MaxTokens:       ifZero(maxTokens, def.MaxTokens),
-			},
-		)
-
-		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
-		}
-
-		if verbose {
-			// Print payload intended for the provider
-			if b, err := json.MarshalIndent(payload, "", "  "); err == nil {
-				fmt.Fprintln(os.Stderr, "[llmx] Request payload:")
-				fmt.Fprintln(os.Stderr, string(b))
-			}
-		}
-
 		// Validate custom base URL early for friendlier errors
 		if strings.TrimSpace(baseURL) != "" {
 			if u, err := url.Parse(baseURL); err != nil || u.Scheme == "" || u.Host == "" {
@@ -188,151 +492,106 @@ var rootCmd = &cobra.Command{
 			}
 		}
 
-		// Build request (API key resolved in provider if omitted here)
-		req, err := prov.BuildAPIRequest(payload, baseURL, provider.RequestOptions{})
+		// Declare tools the model may call (--tool/--tools).
+		declaredTools, err := loadTools(toolSpecs, toolsFile)
 		if err != nil {
-			// Friendly guidance for missing API keys using typed errors
-			var mk provider.MissingAPIKeyError
-			if errors.Is(err, provider.ErrMissingAPIKey) && errors.As(err, &mk) {
-				env := strings.TrimSpace(mk.EnvVar)
-				if env == "" {
-					env = "API_KEY"
-				}
-				fmt.Printf("%s not found. Set one of:\n  bash/zsh: export %s=sk-...\n  fish:    set -x %s sk-...\n", env, env, env)
-				os.Exit(1)
-			}
 			fmt.Println(err)
 			os.Exit(1)
 		}
-
-		if verbose {
-			// Redact secrets in URL and headers
-			safeURL := req.URL.String()
-			if u, err := url.Parse(safeURL); err == nil {
-				q := u.Query()
-				if q.Has("key") {
-					q.Set("key", "***")
-					u.RawQuery = q.Encode()
-				}
-				safeURL = u.String()
-			}
-			fmt.Fprintf(os.Stderr, "[llmx] Request: %s %s\n", req.Method, safeURL)
-			fmt.Fprintln(os.Stderr, "[llmx] Headers:")
-			for k, v := range req.Header {
-				if strings.EqualFold(k, "Authorization") || strings.EqualFold(k, "x-api-key") || strings.EqualFold(k, "X-API-Key") {
-					fmt.Fprintf(os.Stderr, "  %s: ***\n", k)
-					continue
-				}
-				if len(v) > 0 {
-					fmt.Fprintf(os.Stderr, "  %s: %s\n", k, v[0])
-				}
-			}
-		}
-
-		resp, err := http.DefaultClient.Do(req)
-		if err != nil {
-			// Add a bit more context for common network failures
-			if ue, ok := err.(*url.Error); ok {
-				if _, ok := ue.Err.(*netpkg.OpError); ok || strings.Contains(strings.ToLower(ue.Error()), "no such host") {
-					fmt.Printf("network error: %v\nCheck connectivity and --base-url (if set).\n", err)
-					os.Exit(1)
-				}
-			}
-			fmt.Println("request failed:", err)
+		if len(declaredTools) == 0 && cmd.Flags().Changed("tool-choice") {
+			fmt.Println("--tool-choice requires --tool or --tools")
 			os.Exit(1)
 		}
-		defer func() {
-			// Explicitly ignore close error to satisfy errcheck
-			_ = resp.Body.Close()
-		}()
-
-		respBody, err := io.ReadAll(resp.Body)
-		if err != nil {
-			fmt.Println("failed to read response:", err)
+		if err := checkToolChoice(toolChoice, declaredTools); err != nil {
+			fmt.Println(err)
 			os.Exit(1)
 		}
-
-		if verbose {
-			fmt.Fprintf(os.Stderr, "[llmx] Response status: %d\n", resp.StatusCode)
-			// Print raw body (truncated if very large)
-			const maxDump = 64 * 1024
-			dump := respBody
-			if len(dump) > maxDump {
-				dump = dump[:maxDump]
-			}
-			fmt.Fprintln(os.Stderr, "[llmx] Raw response:")
-			fmt.Fprintln(os.Stderr, string(dump))
-			if len(respBody) > maxDump {
-				fmt.Fprintln(os.Stderr, "[llmx] (truncated)")
-			}
+		if len(declaredTools) > 0 && stream {
+			fmt.Println("--stream cannot be combined with --tool/--tools")
+			os.Exit(1)
 		}
 
-		// Non-2xx handling
-		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-			fmt.Printf("request failed with status %d:\n%s\n", resp.StatusCode, string(respBody))
+		if retries < 0 {
+			fmt.Println("--retries must be >= 0")
 			os.Exit(1)
 		}
-		var obj map[string]interface{}
-		// Parse API response to extract text output (provider-specific)
-		textOut, err := prov.ParseAPIResponse(respBody)
-		if err != nil {
+		if modelOptions, err = parseModelOptions(modelOptionSpecs); err != nil {
 			fmt.Println(err)
 			os.Exit(1)
 		}
-		textOut = stripForJsonMarshal(textOut)
-		if err := json.Unmarshal([]byte(textOut), &obj); err != nil {
-			fmt.Fprintln(os.Stderr, "failed to decode structured JSON output:", err)
+		req := llmx.Request{
+			Options: provider.Options{
+				Model:           model,
+				Instructions:    instructions,
+				Message:         message,
+				History:         history,
+				Images:          images,
+				Files:           files,
+				Verbosity:       verbosity,
+				ReasoningEffort: reasoningEffort,
+				Properties:      properties,
+				Required:        required,
+				Schema:          fullSchema,
+				Tools:           declaredTools,
+				ToolChoice:      toolChoice,
+				MaxTokens:       maxTokens,
+				ModelOptions:    modelOptions,
+				KeepAlive:       keepAlive,
+			},
+			ErrorKey:   errorKey,
+			Repairs:    retries,
+			NoValidate: noValidate,
+		}
+		caps := client.Provider.Capabilities()
+		warnIgnoredFlags(cmd, providerName, caps)
+		if err := checkCapabilities(providerName, caps, req.Options, stream); err != nil {
+			fmt.Println(err)
 			os.Exit(1)
 		}
 
-		// If the structured JSON contains a non-empty error field, exit non-zero.
-		if ev, ok := obj[errorKey]; ok {
-			if es, ok := ev.(string); ok {
-				es = strings.TrimSpace(es)
-				if es != "" && es != "null" {
-					fmt.Fprintln(os.Stderr, es)
+		// Plain text (an empty --format) streams to stdout as it arrives; in
+		// structured mode progress goes to stderr so stdout only carries the
+		// final validated JSON.
+		plain := len(properties) == 0 && fullSchema == nil
+		if stream {
+			req.Stream = os.Stderr
+			if plain {
+				if withMeta {
+					fmt.Println("--meta cannot be combined with plain text --stream (empty --format)")
 					os.Exit(1)
 				}
+				req.Stream = os.Stdout
 			}
 		}
 
-		// If --only is specified, attempt to parse structured JSON and print only that key
-		if onlyKey != "" {
-			val, hasOnly := obj[onlyKey]
-			if !hasOnly {
-				fmt.Printf("key not found: %s\n", onlyKey)
-				os.Exit(1)
+		resp, err := generate(cmd.Context(), client, req)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			if showUsage {
+				printUsage(os.Stderr, resp)
 			}
-			switch v := val.(type) {
-			case string:
-				textOut = v
-			case float64, bool, nil:
-				b, _ := json.Marshal(v)
-				textOut = string(b)
-			default:
-				// objects/arrays: print compact JSON
-				b, err := json.Marshal(v)
-				if err != nil {
-					fmt.Println("failed to encode value:", err)
-					os.Exit(1)
-				}
-				textOut = string(b)
+			os.Exit(exitCodeFor(err))
+		}
+		switch {
+		case len(resp.ToolCalls) > 0:
+			printOutput(map[string]interface{}{"tool_calls": resp.ToolCalls}, resp)
+		case plain && stream:
+			if showUsage {
+				printUsage(os.Stderr, resp)
 			}
-		} else {
-			// Structured JSON: print compact canonical JSON
-			outJSON, err := json.Marshal(obj)
+		case plain:
+			printOutput(resp.Text, resp)
+		default:
+			val, err := selectOutput(resp.Output, required, onlyKey)
 			if err != nil {
-				fmt.Fprintln(os.Stderr, "failed to encode output:", err)
+				fmt.Println(err)
+				if showUsage {
+					printUsage(os.Stderr, resp)
+				}
 				os.Exit(1)
 			}
-			textOut = string(outJSON)
+			printOutput(val, resp)
 		}
-
-		// Ensure output ends with a single newline
-		if !strings.HasSuffix(textOut, "\n") {
-			textOut += "\n"
-		}
-		fmt.Print(textOut)
 	},
 }
 
@@ -341,11 +600,14 @@ func init() {
 	rootCmd.Version = version.String()
 	rootCmd.SetVersionTemplate("{{.Version}}\n")
 
+	rootCmd.Flags().StringVar(&profileName, "profile", "", "config file profile to use (default $LLMX_PROFILE or default_profile)")
 	rootCmd.Flags().StringVar(&model, "model", "", "model name (provider default if empty)")
 	rootCmd.Flags().StringVar(&reasoningEffort, "reasoning-effort", "minimal", "reasoning effort (minimal/low/medium/high)")
 	rootCmd.Flags().StringVar(&verbosity, "verbosity", "low", "verbosity (low/medium/high)")
+	addModelFlags(rootCmd)
 	rootCmd.Flags().BoolVar(&verbose, "verbose", false, "enable verbose debug logging to stderr")
 	rootCmd.Flags().StringVar(&baseURL, "base-url", "", "override base URL (provider default if empty)")
+	addRequestFlags(rootCmd)
 	rootCmd.Flags().StringVar(&providerName, "provider", "openai", "LLM provider name (e.g., openai)")
 	rootCmd.Flags().IntVar(&maxTokens, "max-tokens", 0, "max output tokens (override; provider default if 0)")
 	rootCmd.Flags().StringVar(
@@ -360,7 +622,25 @@ func init() {
 		"message,error",
 		"output format specification (default: \"message,error\"; e.g., \"name:string,age:integer,active:boolean\"). The error field name can be changed via --error-key",
 	)
+	rootCmd.Flags().StringVar(
+		&schemaRef,
+		"schema",
+		"",
+		"JSON Schema for the output object: a file path, @file, or inline JSON (replaces --format)",
+	)
+	rootCmd.Flags().StringVar(&messagesFile, "messages", "", "JSON/JSONL transcript of prior system/user/assistant turns to send before the message")
+	rootCmd.Flags().StringArrayVar(&imageRefs, "image", nil, "attach an image file or http(s) URL to the message (repeatable)")
+	rootCmd.Flags().StringArrayVar(&filePaths, "file", nil, "attach a PDF or text file to the message (repeatable)")
+	rootCmd.Flags().StringArrayVar(&toolSpecs, "tool", nil, "declare a tool as name=params (params: --format shorthand, @schema.json or inline JSON Schema; repeatable)")
+	rootCmd.Flags().StringVar(&toolsFile, "tools", "", "JSON file declaring tools (name, description, format or parameters)")
+	rootCmd.Flags().StringVar(&toolChoice, "tool-choice", "auto", "tool choice: auto, required, none, or a tool name")
 	rootCmd.Flags().StringVar(&errorKey, "error-key", "error", "name of the error field in structured JSON (non-empty triggers non-zero exit)")
+	rootCmd.Flags().BoolVar(&stream, "stream", false, "stream output over SSE; text goes to stdout with --format \"\", otherwise progress goes to stderr and the final JSON to stdout")
+	rootCmd.Flags().IntVar(&retries, "retries", 0, "re-ask the model up to N times when its output is not valid JSON or fails schema validation")
+	rootCmd.Flags().BoolVar(&noValidate, "no-validate", false, "skip local validation of structured output against --format/--schema")
+	rootCmd.Flags().BoolVar(&showUsage, "usage", false, "print token usage, finish reason, model, response id and estimated cost to stderr")
+	rootCmd.Flags().BoolVar(&withMeta, "meta", false, "wrap stdout JSON as {\"output\": ..., \"meta\": {id, model, finish_reason, usage, cost_usd}}")
+	addCostFlags(rootCmd, "refuse to send a request whose estimated input cost (plus what the run spent) exceeds this many USD (0 = no limit)")
 	rootCmd.Flags().StringVar(
 		&onlyKey,
 		"only",
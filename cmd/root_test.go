@@ -1,50 +1,136 @@
 package cmd
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
 
-func TestStripMarkdownCodeFences(t *testing.T) {
+	"llmx/pkg/llmx"
+	"llmx/pkg/provider"
+)
+
+func TestSplitTranscript(t *testing.T) {
+	turns := []provider.Turn{
+		{Role: provider.RoleSystem, Content: "sys"},
+		{Role: provider.RoleUser, Content: "Hi"},
+	}
+
+	history, msg, err := splitTranscript(turns, "")
+	if err != nil || msg != "Hi" || len(history) != 1 {
+		t.Fatalf("last user turn should become the message: %v %q %v", history, msg, err)
+	}
+
+	history, msg, err = splitTranscript(turns, "next")
+	if err != nil || msg != "next" || len(history) != 2 {
+		t.Fatalf("explicit message should follow all turns: %v %q %v", history, msg, err)
+	}
+
+	_, _, err = splitTranscript([]provider.Turn{{Role: provider.RoleAssistant, Content: "x"}}, " \n")
+	if err == nil {
+		t.Fatalf("expected error when transcript ends with assistant turn and no message")
+	}
+}
+
+func TestCheckToolChoice(t *testing.T) {
+	declared := []provider.Tool{{Name: "get_weather"}}
+	for _, choice := range []string{"", "auto", "none", "required", "get_weather"} {
+		if err := checkToolChoice(choice, declared); err != nil {
+			t.Errorf("checkToolChoice(%q) error: %v", choice, err)
+		}
+	}
+	if err := checkToolChoice("book_table", declared); err == nil {
+		t.Errorf("expected error for undeclared tool")
+	}
+}
+
+func TestPrintUsage(t *testing.T) {
+	cost := 0.25
+	meta := llmx.Response{
+		Response: provider.Response{ID: "r2", Model: "m", FinishReason: provider.FinishStop, Usage: provider.Usage{InputTokens: 30, OutputTokens: 9, CachedTokens: 8}},
+		CostUSD:  &cost,
+	}
+	var b strings.Builder
+	printUsage(&b, meta)
+	if got := b.String(); got != "usage: 30 input tokens (8 cached), 9 output tokens (0 reasoning); finish: stop; model: m; id: r2; cost: $0.250000\n" {
+		t.Fatalf("printUsage = %q", got)
+	}
+}
+
+func TestSelectOutput(t *testing.T) {
+	obj := map[string]interface{}{"name": "Ann", "age": float64(3)}
+	if v, err := selectOutput(obj, nil, ""); err != nil || !reflect.DeepEqual(v, obj) {
+		t.Fatalf("selectOutput(whole) = %v, %v", v, err)
+	}
+	if v, err := selectOutput(obj, []string{"name"}, "nick"); err != nil || v != nil {
+		t.Fatalf("omitted optional key should be null: %v, %v", v, err)
+	}
+	if _, err := selectOutput(obj, []string{"nick"}, "nick"); err == nil {
+		t.Fatalf("expected error for missing required key")
+	}
+	if s, err := formatValue(obj["age"]); err != nil || s != "3" {
+		t.Fatalf("formatValue = %q, %v", s, err)
+	}
+}
+
+func TestExitCodeFor(t *testing.T) {
+	truncated := explainError(provider.TruncatedError{Provider: "openai"})
+	if !strings.Contains(truncated.Error(), "--max-tokens") {
+		t.Errorf("truncation message should suggest --max-tokens: %q", truncated)
+	}
 	tests := []struct {
-		name string
-		in   string
-		want string
+		err  error
+		want int
 	}{
-		{
-			name: "json with language tag",
-			in:   "```json\n{\n  \"a\": 1\n}\n```\n",
-			want: "{\n  \"a\": 1\n}",
-		},
-		{
-			name: "plain fence no language",
-			in:   "```\n{\n  \"a\": 1\n}\n```\n",
-			want: "{\n  \"a\": 1\n}",
-		},
-		{
-			name: "no closing fence",
-			in:   "```json\n{\n  \"a\": 1\n}",
-			want: "```json\n{\n  \"a\": 1\n}",
-		},
-		{
-			name: "incidental fence inside body only",
-			in:   "prefix\n```something\nbody\n",
-			want: "prefix\n```something\nbody",
-		},
-		{
-			name: "closing fence not last non-empty line",
-			in:   "```json\n{\n  \"a\": 1\n}\n```\ntrailer\n",
-			want: "```json\n{\n  \"a\": 1\n}\n```\ntrailer",
-		},
-		{
-			name: "trailing blanks after closing fence",
-			in:   "```json\n{\n  \"a\": 1\n}\n```\n\n\n",
-			want: "{\n  \"a\": 1\n}",
-		},
-	}
-	for _, tc := range tests {
-		t.Run(tc.name, func(t *testing.T) {
-			got := stripForJsonMarshal(tc.in)
-			if got != tc.want {
-				t.Fatalf("got:\n%q\nwant:\n%q", got, tc.want)
-			}
-		})
+		{truncated, exitTruncated},
+		{fmt.Errorf("request: %w", provider.RefusalError{Provider: "gemini", Reason: "SAFETY"}), exitRefused},
+		{fmt.Errorf("%w (3 steps): %w", errMaxSteps, llmx.SchemaError{}), exitSchemaViolation},
+		{fmt.Errorf("%w: $1 > $0.5", llmx.ErrOverBudget), exitOverBudget},
+		{explainError(fmt.Errorf("%w after 1s", llmx.ErrTimeout)), exitTimeout},
+		{explainError(fmt.Errorf("read: %w", context.Canceled)), exitInterrupted},
+		{errors.New("API error: 500"), 1},
+	}
+	for _, tt := range tests {
+		if got := exitCodeFor(tt.err); got != tt.want {
+			t.Errorf("exitCodeFor(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestSendRequest_Timeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer srv.Close()
+	oldBaseURL, oldTimeout := baseURL, requestTimeout
+	t.Cleanup(func() { baseURL, requestTimeout = oldBaseURL, oldTimeout })
+	baseURL, requestTimeout = srv.URL, 50*time.Millisecond
+	t.Setenv("OPENAI_API_KEY", "test")
+
+	c, err := newClient("openai-compat")
+	if err != nil {
+		t.Fatalf("newClient: %v", err)
+	}
+	_, _, err = sendRequest(context.Background(), c, provider.Options{Model: "m", Message: "hi"}, nil)
+	if !strings.HasSuffix(err.Error(), "(--timeout)") || exitCodeFor(err) != exitTimeout {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.Timeout = 0
+	_, _, err = sendRequest(ctx, c, provider.Options{Model: "m", Message: "hi"}, nil)
+	if !errors.Is(err, errInterrupted) || exitCodeFor(err) != exitInterrupted {
+		t.Fatalf("expected interruption, got %v", err)
 	}
 }
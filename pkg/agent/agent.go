@@ -0,0 +1,172 @@
+// Package agent loads the local tools `llmx agent` may run on the model's
+// behalf and executes them. A tool is a shell command or an executable that
+// receives the call arguments as a JSON object on stdin and writes its result
+// (preferably JSON) to stdout.
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"llmx/pkg/provider"
+	"llmx/pkg/tools"
+)
+
+// DefaultTimeout bounds a single tool run when the config sets none.
+const DefaultTimeout = 60 * time.Second
+
+// MaxOutputBytes caps the tool output fed back to the model; longer output
+// is truncated.
+const MaxOutputBytes = 64 << 10
+
+// ToolConfig is a tool entry in an agent config file: a tools.Definition plus
+// how to run it. Exactly one of Command and Exec must be set.
+type ToolConfig struct {
+	tools.Definition
+	// Command is run with `sh -c`.
+	Command string `json:"command,omitempty"`
+	// Exec is an executable and its arguments, run without a shell.
+	Exec []string `json:"exec,omitempty"`
+	// Timeout is a Go duration such as "30s" (DefaultTimeout if empty).
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// Config is an agent config file.
+type Config struct {
+	Tools []ToolConfig `json:"tools"`
+	// Allow lists tools that run without a confirmation prompt.
+	Allow []string `json:"allow,omitempty"`
+	// MaxSteps limits model requests per run (0 means the caller's default).
+	MaxSteps int `json:"max_steps,omitempty"`
+}
+
+// LoadConfig reads and checks an agent config file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read agent config: %w", err)
+	}
+	var cfg Config
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("invalid agent config: %w", err)
+	}
+	if len(cfg.Tools) == 0 {
+		return nil, fmt.Errorf("agent config %s declares no tools", path)
+	}
+	if cfg.MaxSteps < 0 {
+		return nil, fmt.Errorf("agent config: max_steps must be >= 0")
+	}
+	for _, t := range cfg.Tools {
+		if (strings.TrimSpace(t.Command) == "") == (len(t.Exec) == 0) {
+			return nil, fmt.Errorf("agent config: tool %s must set exactly one of command or exec", t.Name)
+		}
+		if _, err := t.timeout(); err != nil {
+			return nil, fmt.Errorf("agent config: tool %s: %w", t.Name, err)
+		}
+	}
+	for _, name := range cfg.Allow {
+		if cfg.Find(name) == nil {
+			return nil, fmt.Errorf("agent config: allow lists unknown tool %q", name)
+		}
+	}
+	return &cfg, nil
+}
+
+// Find returns the tool named name, or nil.
+func (c *Config) Find(name string) *ToolConfig {
+	for i := range c.Tools {
+		if c.Tools[i].Name == name {
+			return &c.Tools[i]
+		}
+	}
+	return nil
+}
+
+// Allowed reports whether name may run without confirmation.
+func (c *Config) Allowed(name string) bool {
+	return slices.Contains(c.Allow, name)
+}
+
+// Declarations converts the configured tools into provider tools.
+func (c *Config) Declarations() ([]provider.Tool, error) {
+	defs := make([]tools.Definition, 0, len(c.Tools))
+	for _, t := range c.Tools {
+		defs = append(defs, t.Definition)
+	}
+	return tools.Build(defs)
+}
+
+func (t ToolConfig) timeout() (time.Duration, error) {
+	if strings.TrimSpace(t.Timeout) == "" {
+		return DefaultTimeout, nil
+	}
+	d, err := time.ParseDuration(t.Timeout)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid timeout %q", t.Timeout)
+	}
+	return d, nil
+}
+
+// Run executes the tool with args as JSON on stdin and returns its trimmed
+// stdout. A non-zero exit status, or running past the timeout, is an error
+// that includes the tool's stderr. Canceling ctx kills the tool.
+func (t ToolConfig) Run(ctx context.Context, args map[string]interface{}) (string, error) {
+	timeout, err := t.timeout()
+	if err != nil {
+		return "", err
+	}
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	input, err := json.Marshal(args)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode arguments: %w", err)
+	}
+
+	parent := ctx
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var cmd *exec.Cmd
+	if len(t.Exec) > 0 {
+		cmd = exec.CommandContext(ctx, t.Exec[0], t.Exec[1:]...)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", t.Command)
+	}
+	cmd.Env = append(os.Environ(), "LLMX_TOOL_NAME="+t.Name)
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Do not wait on background children that keep the pipes open.
+	cmd.WaitDelay = time.Second
+
+	err = cmd.Run()
+	if parent.Err() != nil {
+		return "", fmt.Errorf("tool %s: %w", t.Name, parent.Err())
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("tool %s timed out after %s", t.Name, timeout)
+	}
+	if err != nil {
+		var ee *exec.ExitError
+		if msg := strings.TrimSpace(stderr.String()); msg != "" && errors.As(err, &ee) {
+			return "", fmt.Errorf("tool %s failed (%v): %s", t.Name, err, msg)
+		}
+		return "", fmt.Errorf("tool %s failed: %w", t.Name, err)
+	}
+	out := strings.TrimSpace(stdout.String())
+	if len(out) > MaxOutputBytes {
+		out = out[:MaxOutputBytes] + "\n[output truncated]"
+	}
+	return out, nil
+}
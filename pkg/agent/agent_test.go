@@ -0,0 +1,86 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "agent.json")
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	cfg, err := LoadConfig(writeConfig(t, `{
+		"max_steps": 5,
+		"allow": ["echo"],
+		"tools": [
+			{"name": "echo", "format": "text", "command": "cat"},
+			{"name": "list", "description": "List files", "exec": ["ls"], "timeout": "5s"}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("LoadConfig error: %v", err)
+	}
+	if cfg.MaxSteps != 5 || !cfg.Allowed("echo") || cfg.Allowed("list") || cfg.Find("list") == nil {
+		t.Fatalf("config mismatch: %+v", cfg)
+	}
+	decls, err := cfg.Declarations()
+	if err != nil || len(decls) != 2 || decls[1].Description != "List files" {
+		t.Fatalf("Declarations() = %+v, %v", decls, err)
+	}
+
+	for name, body := range map[string]string{
+		"no tools":      `{"tools": []}`,
+		"no runner":     `{"tools": [{"name": "a"}]}`,
+		"both runners":  `{"tools": [{"name": "a", "command": "x", "exec": ["x"]}]}`,
+		"bad timeout":   `{"tools": [{"name": "a", "command": "x", "timeout": "soon"}]}`,
+		"unknown allow": `{"allow": ["b"], "tools": [{"name": "a", "command": "x"}]}`,
+		"unknown field": `{"tools": [{"name": "a", "command": "x", "cmd": "y"}]}`,
+	} {
+		if _, err := LoadConfig(writeConfig(t, body)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestToolConfigRun(t *testing.T) {
+	echo := ToolConfig{Command: "cat"}
+	echo.Name = "echo"
+	out, err := echo.Run(context.Background(), map[string]interface{}{"text": "hi"})
+	if err != nil || out != `{"text":"hi"}` {
+		t.Fatalf("Run(command) = %q, %v", out, err)
+	}
+
+	name := ToolConfig{Exec: []string{"sh", "-c", `printf '%s' "$LLMX_TOOL_NAME"`}}
+	name.Name = "whoami"
+	if out, err := name.Run(context.Background(), nil); err != nil || out != "whoami" {
+		t.Fatalf("Run(exec) = %q, %v", out, err)
+	}
+
+	fail := ToolConfig{Command: "echo broken >&2; exit 2"}
+	fail.Name = "fail"
+	if _, err := fail.Run(context.Background(), nil); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Fatalf("expected failure with stderr, got %v", err)
+	}
+
+	slow := ToolConfig{Command: "sleep 5", Timeout: "50ms"}
+	slow.Name = "slow"
+	if _, err := slow.Run(context.Background(), nil); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := slow.Run(ctx, nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+}
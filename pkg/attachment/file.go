@@ -0,0 +1,55 @@
+package attachment
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"llmx/pkg/pdftext"
+	"llmx/pkg/provider"
+)
+
+// MaxFileBytes is the largest document LoadFile accepts. Inline documents
+// are base64-encoded, and providers reject requests much beyond this size.
+const MaxFileBytes = 20 << 20
+
+// LoadFile reads a document attachment. PDFs keep their type and have their
+// text extracted once into Text, when it can be; any UTF-8 text file (plain
+// text, Markdown, CSV, JSON, ...) is sent as text/plain. Other types are
+// rejected.
+func LoadFile(path string) (provider.File, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return provider.File{}, fmt.Errorf("failed to read file: %w", err)
+	}
+	if fi.IsDir() {
+		return provider.File{}, fmt.Errorf("file is a directory: %s", path)
+	}
+	if fi.Size() > MaxFileBytes {
+		return provider.File{}, fmt.Errorf("file %s is %d bytes; the limit is %d", path, fi.Size(), MaxFileBytes)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return provider.File{}, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	f := provider.File{Name: filepath.Base(path), Data: data}
+	sniffed, _, _ := strings.Cut(http.DetectContentType(data), ";")
+	switch {
+	case sniffed == "application/pdf":
+		f.MIMEType = sniffed
+		// Providers with native PDF input do not need the text, so a
+		// failure is left for those that do to report.
+		f.Text, _ = pdftext.Extract(data)
+	case strings.HasPrefix(sniffed, "text/") && utf8.Valid(data):
+		f.MIMEType = "text/plain"
+	case imageTypes[sniffed]:
+		return provider.File{}, fmt.Errorf("%s is an image (%s); attach it with --image", path, sniffed)
+	default:
+		return provider.File{}, fmt.Errorf("unsupported file type %s: %s (supported: PDF and UTF-8 text)", sniffed, path)
+	}
+	return f, nil
+}
@@ -0,0 +1,48 @@
+package attachment
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		return path
+	}
+	pdf := write("contract.pdf", []byte("%PDF-1.7\n1 0 obj\n<<>>\nendobj\n"))
+	content := "BT /F1 12 Tf (Total: 42) Tj ET"
+	textPDF := write("invoice.pdf", []byte("%PDF-1.7\n"+
+		"1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n"+
+		"2 0 obj\n<< /Type /Pages /Kids [3 0 R] /Count 1 /Resources << /Font << /F1 4 0 R >> >> >>\nendobj\n"+
+		"3 0 obj\n<< /Type /Page /Parent 2 0 R /Contents 5 0 R >>\nendobj\n"+
+		"4 0 obj\n<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>\nendobj\n"+
+		"5 0 obj\n<< /Length "+strconv.Itoa(len(content))+" >>\nstream\n"+content+"\nendstream\nendobj\n"))
+	csv := write("rows.csv", []byte("sku,qty\nA-1,3\n"))
+	png := write("shot.png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"))
+	bin := write("blob.bin", []byte{0x00, 0x01, 0x02, 0xff})
+
+	f, err := LoadFile(pdf)
+	if err != nil || f.MIMEType != "application/pdf" || f.Name != "contract.pdf" || f.Text != "" {
+		t.Fatalf("LoadFile(pdf) = %+v, %v", f, err)
+	}
+	f, err = LoadFile(textPDF)
+	if err != nil || f.MIMEType != "application/pdf" || f.Text != "Total: 42" {
+		t.Fatalf("LoadFile(text pdf) = %+v, %v", f, err)
+	}
+	f, err = LoadFile(csv)
+	if err != nil || f.MIMEType != "text/plain" || string(f.Data) != "sku,qty\nA-1,3\n" {
+		t.Fatalf("LoadFile(csv) = %+v, %v", f, err)
+	}
+	for _, path := range []string{png, bin, dir, filepath.Join(dir, "missing.pdf")} {
+		if _, err := LoadFile(path); err == nil {
+			t.Errorf("LoadFile(%q): expected error", path)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Package attachment loads local files and remote references passed on the
+// command line (e.g., --image) into provider inputs.
+package attachment
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"llmx/pkg/provider"
+)
+
+// MaxImageBytes is the largest local image LoadImage accepts; providers
+// reject larger inline requests anyway.
+const MaxImageBytes = 20 << 20
+
+// imageTypes lists the image media types accepted by every provider.
+var imageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// LoadImage resolves ref into a provider.Image. An http(s) URL is passed
+// through (its type guessed from the extension); anything else is read as a
+// local file whose type is sniffed from its content; the extension is
+// ignored.
+func LoadImage(ref string) (provider.Image, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return provider.Image{}, fmt.Errorf("empty image reference")
+	}
+
+	if u, err := url.Parse(ref); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		if u.Host == "" {
+			return provider.Image{}, fmt.Errorf("invalid image URL: %s", ref)
+		}
+		mimeType := mime.TypeByExtension(strings.ToLower(path.Ext(u.Path)))
+		if mimeType != "" && !imageTypes[mimeType] {
+			return provider.Image{}, fmt.Errorf("unsupported image type %s: %s (supported: png, jpeg, gif, webp)", mimeType, ref)
+		}
+		return provider.Image{MIMEType: mimeType, URL: ref}, nil
+	}
+
+	fi, err := os.Stat(ref)
+	if err != nil {
+		return provider.Image{}, fmt.Errorf("failed to read image: %w", err)
+	}
+	if fi.IsDir() {
+		return provider.Image{}, fmt.Errorf("image is a directory: %s", ref)
+	}
+	if fi.Size() > MaxImageBytes {
+		return provider.Image{}, fmt.Errorf("image %s is %d bytes; the limit is %d", ref, fi.Size(), MaxImageBytes)
+	}
+	data, err := os.ReadFile(ref)
+	if err != nil {
+		return provider.Image{}, fmt.Errorf("failed to read image: %w", err)
+	}
+
+	mimeType, _, _ := strings.Cut(http.DetectContentType(data), ";")
+	if !imageTypes[mimeType] {
+		return provider.Image{}, fmt.Errorf("unsupported image type %s: %s (supported: png, jpeg, gif, webp)", mimeType, ref)
+	}
+	return provider.Image{MIMEType: mimeType, Data: data}, nil
+}
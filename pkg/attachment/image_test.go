@@ -0,0 +1,39 @@
+package attachment
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadImage(t *testing.T) {
+	dir := t.TempDir()
+	pngPath := filepath.Join(dir, "shot.dat")
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	if err := os.WriteFile(pngPath, png, 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	txtPath := filepath.Join(dir, "fake.png")
+	if err := os.WriteFile(txtPath, []byte("not an image"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	img, err := LoadImage(pngPath)
+	if err != nil {
+		t.Fatalf("LoadImage(png) error: %v", err)
+	}
+	if img.MIMEType != "image/png" || string(img.Data) != string(png) || img.URL != "" {
+		t.Fatalf("LoadImage(png) = %+v", img)
+	}
+
+	img, err = LoadImage("https://example.com/r.JPG?x=1")
+	if err != nil || img.URL != "https://example.com/r.JPG?x=1" || img.MIMEType != "image/jpeg" || img.Data != nil {
+		t.Fatalf("LoadImage(url) = %+v, %v", img, err)
+	}
+
+	for _, ref := range []string{"", txtPath, dir, filepath.Join(dir, "missing.png"), "https://example.com/doc.pdf"} {
+		if _, err := LoadImage(ref); err == nil {
+			t.Errorf("LoadImage(%q): expected error", ref)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+// Package config loads the llmx config file: named profiles that bundle a
+// provider, model, base URL, API key source, instructions, format, max
+// tokens and extra headers, selected with --profile. The file is TOML:
+//
+//	default_profile = "work"
+//
+//	[profiles.work]
+//	provider = "anthropic"
+//	model = "claude-sonnet-4-0"
+//	api_key_env = "WORK_ANTHROPIC_KEY"
+//	max_tokens = 2048
+//
+//	[profiles.work.headers]
+//	X-Team = "search"
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Profile is a named set of defaults.
+type Profile struct {
+	Provider string
+	Model    string
+	BaseURL  string
+	// APIKeyEnv names the environment variable holding the API key, in place
+	// of the provider's default (e.g., OPENAI_API_KEY).
+	APIKeyEnv string
+	// APIKeyFile is a file containing the API key.
+	APIKeyFile string
+	// APIKeyCmd is a shell command that prints the API key.
+	APIKeyCmd    string
+	Instructions string
+	Format       string
+	MaxTokens    int
+	Headers      map[string]string
+}
+
+// File is a parsed config file.
+type File struct {
+	// Path is where the file was read from.
+	Path string
+	// DefaultProfile is used when no profile is selected.
+	DefaultProfile string
+	Profiles       map[string]Profile
+}
+
+// DefaultPath returns $XDG_CONFIG_HOME/llmx/config.toml, falling back to the
+// platform's user config directory.
+func DefaultPath() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		var err error
+		if dir, err = os.UserConfigDir(); err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(dir, "llmx", "config.toml"), nil
+}
+
+// Load reads and checks a config file. Unknown keys are errors so typos do
+// not go unnoticed.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	doc, err := parseTOML(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	f, err := decode(doc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	f.Path = path
+	return f, nil
+}
+
+// Profile returns the named profile, or the default profile when name is
+// empty. ok is false when name is empty and there is no default.
+func (f *File) Profile(name string) (p Profile, ok bool, err error) {
+	if name == "" {
+		name = f.DefaultProfile
+		if name == "" {
+			return Profile{}, false, nil
+		}
+	}
+	p, found := f.Profiles[name]
+	if !found {
+		return Profile{}, false, fmt.Errorf("profile %q not found in %s (have: %s)", name, f.Path, strings.Join(f.Names(), ", "))
+	}
+	return p, true, nil
+}
+
+// Names returns the profile names in order.
+func (f *File) Names() []string {
+	names := make([]string, 0, len(f.Profiles))
+	for name := range f.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func decode(doc map[string]interface{}) (*File, error) {
+	f := &File{Profiles: map[string]Profile{}}
+	for key, val := range doc {
+		switch key {
+		case "default_profile":
+			s, ok := val.(string)
+			if !ok {
+				return nil, errors.New("default_profile must be a string")
+			}
+			f.DefaultProfile = s
+		case "profiles":
+			profiles, ok := val.(map[string]interface{})
+			if !ok {
+				return nil, errors.New("profiles must be a table")
+			}
+			for name, pv := range profiles {
+				table, ok := pv.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("profiles.%s must be a table", name)
+				}
+				p, err := decodeProfile(table)
+				if err != nil {
+					return nil, fmt.Errorf("profile %q: %w", name, err)
+				}
+				f.Profiles[name] = p
+			}
+		default:
+			return nil, fmt.Errorf("unknown key %q", key)
+		}
+	}
+	if f.DefaultProfile != "" {
+		if _, ok := f.Profiles[f.DefaultProfile]; !ok {
+			return nil, fmt.Errorf("default_profile %q is not a profile", f.DefaultProfile)
+		}
+	}
+	return f, nil
+}
+
+func decodeProfile(table map[string]interface{}) (Profile, error) {
+	var p Profile
+	strs := map[string]*string{
+		"provider":     &p.Provider,
+		"model":        &p.Model,
+		"base_url":     &p.BaseURL,
+		"api_key_env":  &p.APIKeyEnv,
+		"api_key_file": &p.APIKeyFile,
+		"api_key_cmd":  &p.APIKeyCmd,
+		"instructions": &p.Instructions,
+		"format":       &p.Format,
+	}
+	for key, val := range table {
+		if dst, ok := strs[key]; ok {
+			s, ok := val.(string)
+			if !ok {
+				return Profile{}, fmt.Errorf("%s must be a string", key)
+			}
+			*dst = s
+			continue
+		}
+		switch key {
+		case "max_tokens":
+			n, ok := val.(int64)
+			if !ok || n <= 0 {
+				return Profile{}, errors.New("max_tokens must be a positive integer")
+			}
+			p.MaxTokens = int(n)
+		case "headers":
+			headers, ok := val.(map[string]interface{})
+			if !ok {
+				return Profile{}, errors.New("headers must be a table")
+			}
+			p.Headers = map[string]string{}
+			for name, hv := range headers {
+				s, ok := hv.(string)
+				if !ok {
+					return Profile{}, fmt.Errorf("header %s must be a string", name)
+				}
+				p.Headers[name] = s
+			}
+		default:
+			return Profile{}, fmt.Errorf("unknown key %q", key)
+		}
+	}
+	sources := 0
+	for _, s := range []string{p.APIKeyEnv, p.APIKeyFile, p.APIKeyCmd} {
+		if s != "" {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return Profile{}, errors.New("set at most one of api_key_env, api_key_file and api_key_cmd")
+	}
+	return p, nil
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	f, err := Load(writeConfig(t, `
+# llmx profiles
+default_profile = "work"
+
+[profiles.work]
+provider = "anthropic"   # Claude via the gateway
+model = 'claude-sonnet-4-0'
+base_url = "https://gateway.example.com"
+api_key_env = "WORK_KEY"
+instructions = "Be terse.\nCite sources."
+format = "answer,sources:array<string>"
+max_tokens = 2_048
+
+[profiles.work.headers]
+X-Team = "search"
+"X-Trace" = "on"
+
+[profiles.local]
+provider = "openai-compat"
+headers = { X-Env = "dev" }
+`))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := Profile{
+		Provider:     "anthropic",
+		Model:        "claude-sonnet-4-0",
+		BaseURL:      "https://gateway.example.com",
+		APIKeyEnv:    "WORK_KEY",
+		Instructions: "Be terse.\nCite sources.",
+		Format:       "answer,sources:array<string>",
+		MaxTokens:    2048,
+		Headers:      map[string]string{"X-Team": "search", "X-Trace": "on"},
+	}
+	p, ok, err := f.Profile("")
+	if err != nil || !ok || !reflect.DeepEqual(p, want) {
+		t.Fatalf("default profile = %+v, %v, %v", p, ok, err)
+	}
+	local, _, err := f.Profile("local")
+	if err != nil || local.Headers["X-Env"] != "dev" {
+		t.Fatalf("local profile = %+v, %v", local, err)
+	}
+	if _, _, err := f.Profile("home"); err == nil || !strings.Contains(err.Error(), "local, work") {
+		t.Fatalf("expected unknown profile error listing profiles, got %v", err)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	for name, body := range map[string]string{
+		"unknown key":        "[profiles.a]\nmodle = \"x\"",
+		"unknown top key":    "profile = \"a\"",
+		"bad default":        "default_profile = \"b\"\n[profiles.a]\nmodel = \"x\"",
+		"wrong type":         "[profiles.a]\nmax_tokens = \"many\"",
+		"array":              "[profiles.a]\nmodel = [\"x\"]",
+		"unterminated":       "[profiles.a]\nmodel = \"x",
+		"duplicate table":    "[profiles.a]\n[profiles.a]",
+		"duplicate key":      "[profiles.a]\nmodel = \"x\"\nmodel = \"y\"",
+		"trailing garbage":   "[profiles.a]\nmodel = \"x\" y",
+		"table over a value": "[profiles.a]\nheaders = \"x\"\n[profiles.a.headers]",
+		"two key sources":    "[profiles.a]\napi_key_env = \"K\"\napi_key_cmd = \"pass show k\"",
+	} {
+		if _, err := Load(writeConfig(t, body)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestDefaultPath(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/tmp/xdg")
+	if got, err := DefaultPath(); err != nil || got != "/tmp/xdg/llmx/config.toml" {
+		t.Fatalf("DefaultPath() = %q, %v", got, err)
+	}
+}
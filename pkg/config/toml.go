@@ -0,0 +1,296 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// parseTOML decodes the subset of TOML that config files need: tables
+// ([a.b]), bare, quoted and dotted keys, basic and literal strings,
+// integers, booleans and inline tables. Arrays, floats, dates and
+// multi-line strings are rejected. Tables are returned as nested
+// map[string]interface{} values.
+func parseTOML(data string) (map[string]interface{}, error) {
+	root := map[string]interface{}{}
+	current := root
+	defined := map[string]bool{}
+	for i, line := range strings.Split(data, "\n") {
+		s := &scanner{src: strings.TrimSuffix(line, "\r")}
+		s.skipSpace()
+		if s.done() || s.peek() == '#' {
+			continue
+		}
+		var err error
+		if s.peek() == '[' {
+			current, err = s.tableHeader(root, defined)
+		} else {
+			err = s.keyValue(current)
+		}
+		if err == nil {
+			s.skipSpace()
+			if !s.done() && s.peek() != '#' {
+				err = fmt.Errorf("unexpected %q after value", s.rest())
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+	}
+	return root, nil
+}
+
+// scanner walks a single line.
+type scanner struct {
+	src string
+	pos int
+}
+
+func (s *scanner) done() bool   { return s.pos >= len(s.src) }
+func (s *scanner) peek() byte   { return s.src[s.pos] }
+func (s *scanner) rest() string { return s.src[s.pos:] }
+
+func (s *scanner) skipSpace() {
+	for !s.done() && (s.peek() == ' ' || s.peek() == '\t') {
+		s.pos++
+	}
+}
+
+func (s *scanner) expect(c byte) error {
+	s.skipSpace()
+	if s.done() || s.peek() != c {
+		return fmt.Errorf("expected %q", c)
+	}
+	s.pos++
+	return nil
+}
+
+// tableHeader parses [a.b] and returns the table it names, creating it.
+func (s *scanner) tableHeader(root map[string]interface{}, defined map[string]bool) (map[string]interface{}, error) {
+	s.pos++
+	if !s.done() && s.peek() == '[' {
+		return nil, fmt.Errorf("arrays of tables are not supported")
+	}
+	path, err := s.keyPath()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.expect(']'); err != nil {
+		return nil, err
+	}
+	name := strings.Join(path, ".")
+	if defined[name] {
+		return nil, fmt.Errorf("table [%s] is defined twice", name)
+	}
+	defined[name] = true
+	return subTable(root, path)
+}
+
+// keyValue parses key = value into table.
+func (s *scanner) keyValue(table map[string]interface{}) error {
+	path, err := s.keyPath()
+	if err != nil {
+		return err
+	}
+	if err := s.expect('='); err != nil {
+		return err
+	}
+	val, err := s.value()
+	if err != nil {
+		return err
+	}
+	parent, err := subTable(table, path[:len(path)-1])
+	if err != nil {
+		return err
+	}
+	key := path[len(path)-1]
+	if _, exists := parent[key]; exists {
+		return fmt.Errorf("key %q is defined twice", strings.Join(path, "."))
+	}
+	parent[key] = val
+	return nil
+}
+
+// subTable walks path from table, creating missing tables.
+func subTable(table map[string]interface{}, path []string) (map[string]interface{}, error) {
+	for _, key := range path {
+		next, exists := table[key]
+		if !exists {
+			next = map[string]interface{}{}
+			table[key] = next
+		}
+		t, ok := next.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("key %q is not a table", key)
+		}
+		table = t
+	}
+	return table, nil
+}
+
+// keyPath parses a possibly dotted key.
+func (s *scanner) keyPath() ([]string, error) {
+	var path []string
+	for {
+		s.skipSpace()
+		key, err := s.key()
+		if err != nil {
+			return nil, err
+		}
+		path = append(path, key)
+		s.skipSpace()
+		if s.done() || s.peek() != '.' {
+			return path, nil
+		}
+		s.pos++
+	}
+}
+
+func (s *scanner) key() (string, error) {
+	if s.done() {
+		return "", fmt.Errorf("expected a key")
+	}
+	switch s.peek() {
+	case '"':
+		return s.basicString()
+	case '\'':
+		return s.literalString()
+	}
+	start := s.pos
+	for !s.done() && isBareKeyChar(s.peek()) {
+		s.pos++
+	}
+	if s.pos == start {
+		return "", fmt.Errorf("expected a key, got %q", s.rest())
+	}
+	return s.src[start:s.pos], nil
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+func (s *scanner) value() (interface{}, error) {
+	s.skipSpace()
+	if s.done() {
+		return nil, fmt.Errorf("expected a value")
+	}
+	switch c := s.peek(); {
+	case strings.HasPrefix(s.rest(), `"""`) || strings.HasPrefix(s.rest(), `'''`):
+		return nil, fmt.Errorf("multi-line strings are not supported")
+	case c == '"':
+		return s.basicString()
+	case c == '\'':
+		return s.literalString()
+	case c == '{':
+		return s.inlineTable()
+	case c == '[':
+		return nil, fmt.Errorf("arrays are not supported")
+	}
+	start := s.pos
+	for !s.done() && s.peek() != ',' && s.peek() != '}' && s.peek() != '#' && s.peek() != ' ' && s.peek() != '\t' {
+		s.pos++
+	}
+	word := s.src[start:s.pos]
+	switch word {
+	case "":
+		return nil, fmt.Errorf("expected a value")
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	n, err := strconv.ParseInt(strings.ReplaceAll(word, "_", ""), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported value %q (use a quoted string, an integer or a boolean)", word)
+	}
+	return n, nil
+}
+
+func (s *scanner) inlineTable() (map[string]interface{}, error) {
+	s.pos++
+	table := map[string]interface{}{}
+	s.skipSpace()
+	if !s.done() && s.peek() == '}' {
+		s.pos++
+		return table, nil
+	}
+	for {
+		if err := s.keyValue(table); err != nil {
+			return nil, err
+		}
+		s.skipSpace()
+		if s.done() {
+			return nil, fmt.Errorf("unterminated inline table")
+		}
+		switch s.peek() {
+		case ',':
+			s.pos++
+		case '}':
+			s.pos++
+			return table, nil
+		default:
+			return nil, fmt.Errorf("expected ',' or '}' in inline table")
+		}
+	}
+}
+
+func (s *scanner) literalString() (string, error) {
+	s.pos++
+	end := strings.IndexByte(s.rest(), '\'')
+	if end < 0 {
+		return "", fmt.Errorf("unterminated string")
+	}
+	str := s.src[s.pos : s.pos+end]
+	s.pos += end + 1
+	return str, nil
+}
+
+func (s *scanner) basicString() (string, error) {
+	s.pos++
+	var b strings.Builder
+	for !s.done() {
+		c := s.peek()
+		s.pos++
+		switch c {
+		case '"':
+			return b.String(), nil
+		case '\\':
+			if s.done() {
+				return "", fmt.Errorf("unterminated string")
+			}
+			esc := s.peek()
+			s.pos++
+			switch esc {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case '"', '\\':
+				b.WriteByte(esc)
+			case 'u', 'U':
+				size := 4
+				if esc == 'U' {
+					size = 8
+				}
+				if len(s.rest()) < size {
+					return "", fmt.Errorf("invalid unicode escape")
+				}
+				r, err := strconv.ParseUint(s.src[s.pos:s.pos+size], 16, 32)
+				if err != nil || !utf8.ValidRune(rune(r)) {
+					return "", fmt.Errorf("invalid unicode escape")
+				}
+				b.WriteRune(rune(r))
+				s.pos += size
+			default:
+				return "", fmt.Errorf("invalid escape \\%c", esc)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("unterminated string")
+}
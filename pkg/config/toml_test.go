@@ -0,0 +1,96 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type table = map[string]interface{}
+
+func TestParseTOML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want table
+	}{
+		{name: "empty", in: "", want: table{}},
+		{name: "comments and blank lines", in: "# top\n\n  # indented\r\n", want: table{}},
+		{name: "bare keys", in: "a = 1\nb-c_d = true\nE = false", want: table{"a": int64(1), "b-c_d": true, "E": false}},
+		{name: "integers", in: "a = -5\nb = +7\nc = 1_000", want: table{"a": int64(-5), "b": int64(7), "c": int64(1000)}},
+		{name: "basic string", in: `a = "x y"`, want: table{"a": "x y"}},
+		{name: "literal string keeps backslashes", in: `a = 'C:\path\n'`, want: table{"a": `C:\path\n`}},
+		{name: "escapes", in: `a = "q\"b\\n\nt\tr\r"`, want: table{"a": "q\"b\\n\nt\tr\r"}},
+		{name: "unicode escapes", in: `a = "\u00e9\U0001F600"`, want: table{"a": "é😀"}},
+		{name: "quoted keys", in: "\"a b\" = 1\n'c.d' = 2", want: table{"a b": int64(1), "c.d": int64(2)}},
+		{name: "dotted keys", in: "a.b = 1\na . c = 2\na.\"d.e\" = 3", want: table{"a": table{"b": int64(1), "c": int64(2), "d.e": int64(3)}}},
+		{name: "comment after value", in: "a = \"x\" # note\nb = 1# tight\nc = true\t# tab", want: table{"a": "x", "b": int64(1), "c": true}},
+		{name: "hash inside string", in: `a = "#not a comment" # comment`, want: table{"a": "#not a comment"}},
+		{name: "tables", in: "top = 1\n[a]\nx = 1\n[a.b]\ny = 2\n[c] # comment\n", want: table{"top": int64(1), "a": table{"x": int64(1), "b": table{"y": int64(2)}}, "c": table{}}},
+		{name: "quoted table name", in: "[a.\"b.c\"]\nx = 1", want: table{"a": table{"b.c": table{"x": int64(1)}}}},
+		{name: "parent table after child", in: "[a.b]\nx = 1\n[a]\ny = 2", want: table{"a": table{"b": table{"x": int64(1)}, "y": int64(2)}}},
+		{name: "inline table", in: `a = { x = 1, "y z" = "s", n.m = true }`, want: table{"a": table{"x": int64(1), "y z": "s", "n": table{"m": true}}}},
+		{name: "empty inline table", in: "a = {}\nb = { }", want: table{"a": table{}, "b": table{}}},
+		{name: "nested inline table", in: "a = {b = {c = 1}} # c", want: table{"a": table{"b": table{"c": int64(1)}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTOML(tt.in)
+			if err != nil {
+				t.Fatalf("parseTOML: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTOML_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "duplicate key", in: "a = 1\na = 2", want: `line 2: key "a" is defined twice`},
+		{name: "duplicate dotted key", in: "a.b = 1\na.b = 2", want: `key "a.b" is defined twice`},
+		{name: "duplicate quoted key", in: "a = 1\n\"a\" = 2", want: `key "a" is defined twice`},
+		{name: "duplicate inline key", in: "a = { x = 1, x = 2 }", want: `key "x" is defined twice`},
+		{name: "duplicate table", in: "[a]\n[b]\n[a]", want: "line 3: table [a] is defined twice"},
+		{name: "key over a table", in: "[a.b]\n[a]\nb = 1", want: `key "b" is defined twice`},
+		{name: "table over a value", in: "a = 1\n[a]", want: `key "a" is not a table`},
+		{name: "dotted key over a value", in: "a = 1\na.b = 2", want: `key "a" is not a table`},
+		{name: "array", in: "a = [1, 2]", want: "arrays are not supported"},
+		{name: "array of tables", in: "[[a]]", want: "arrays of tables are not supported"},
+		{name: "multi-line basic string", in: `a = """x"""`, want: "multi-line strings are not supported"},
+		{name: "multi-line literal string", in: "a = '''x'''", want: "multi-line strings are not supported"},
+		{name: "float", in: "a = 1.5", want: `unsupported value "1.5"`},
+		{name: "bare word", in: "a = yes", want: `unsupported value "yes"`},
+		{name: "missing value", in: "a =", want: "expected a value"},
+		{name: "missing value before comment", in: "a = # x", want: "expected a value"},
+		{name: "missing equals", in: "a 1", want: `expected '='`},
+		{name: "missing key", in: "= 1", want: "expected a key"},
+		{name: "empty dotted segment", in: "a. = 1", want: "expected a key"},
+		{name: "unterminated basic string", in: `a = "x`, want: "unterminated string"},
+		{name: "unterminated literal string", in: "a = 'x", want: "unterminated string"},
+		{name: "dangling escape", in: `a = "x\`, want: "unterminated string"},
+		{name: "invalid escape", in: `a = "\q"`, want: `invalid escape \q`},
+		{name: "short unicode escape", in: `a = "\u12"`, want: "invalid unicode escape"},
+		{name: "surrogate unicode escape", in: `a = "\uD800"`, want: "invalid unicode escape"},
+		{name: "unterminated table header", in: "[a", want: `expected ']'`},
+		{name: "unterminated inline table", in: "a = { x = 1", want: "unterminated inline table"},
+		{name: "inline table without comma", in: "a = { x = 1 y = 2 }", want: "expected ',' or '}' in inline table"},
+		{name: "trailing comma in inline table", in: "a = { x = 1, }", want: "expected a key"},
+		{name: "garbage after value", in: "a = \"x\" y", want: `unexpected "y" after value`},
+		{name: "garbage after table header", in: "[a] b", want: `unexpected "b" after value`},
+		{name: "error line number", in: "a = 1\n\n# c\nb = ?", want: "line 4:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseTOML(tt.in)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
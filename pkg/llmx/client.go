@@ -0,0 +1,405 @@
+// Package llmx is the Go API behind the llmx CLI. A Client sends requests
+// to a provider, retrying transient HTTP failures, and Generate turns a
+// request with a --format shorthand or a JSON Schema into validated
+// structured output, asking the model to repair output that does not
+// decode or match the schema:
+//
+//	c, err := llmx.New("anthropic")
+//	if err != nil {
+//		return err
+//	}
+//	var person struct {
+//		Name string `json:"name"`
+//		Age  int    `json:"age"`
+//	}
+//	req := llmx.Request{Format: "name,age:integer", Repairs: 1}
+//	req.Message = "Alice is 14."
+//	_, err = c.GenerateInto(ctx, req, &person)
+//
+// Without a Format, GenerateInto derives the schema from the struct's json
+// and jsonschema tags instead.
+package llmx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"llmx/pkg/pricing"
+	"llmx/pkg/provider"
+	"llmx/pkg/retry"
+)
+
+// ErrTimeout is returned when a request runs past Client.Timeout.
+var ErrTimeout = errors.New("request timed out")
+
+// ErrOverBudget is returned when a request is not sent because its
+// estimated cost exceeds Client.MaxCost.
+var ErrOverBudget = errors.New("over budget")
+
+// Client sends requests to one provider. The zero value of each field is
+// usable; New fills in the provider and the default retry policy.
+type Client struct {
+	// Provider builds the provider's requests and parses its responses.
+	Provider provider.Provider
+	// ProviderName names the provider in messages and price lookups.
+	ProviderName string
+	// BaseURL overrides the provider's default endpoint.
+	BaseURL string
+	// APIKey is sent instead of the key the provider reads from its default
+	// environment variable (e.g., OPENAI_API_KEY).
+	APIKey string
+	// APIKeyFunc, when set, supplies the key for each request in place of
+	// APIKey, e.g., from a secret store. An empty key falls back to the
+	// provider's environment variable.
+	APIKeyFunc func() (string, error)
+	// Headers are added to every request.
+	Headers map[string]string
+	// HTTPClient sends the requests; nil means http.DefaultClient.
+	HTTPClient *http.Client
+	// Retry resends rate-limited, overloaded and failed requests. Its Logf
+	// defaults to the client's.
+	Retry retry.Policy
+	// Timeout bounds each request, including HTTP retries and reading a
+	// streamed response. 0 means no limit.
+	Timeout time.Duration
+	// Prices, when set, is used to estimate costs (Response.CostUSD).
+	Prices pricing.Table
+	// MaxCost refuses a request when its estimated input cost exceeds it,
+	// in USD; under Generate what earlier attempts spent counts too. The
+	// model must have a price in Prices. 0 means no limit.
+	MaxCost float64
+	// Logf, when set, receives debug logs: payloads, requests with secrets
+	// redacted, raw responses, retries and rejected attempts.
+	Logf func(format string, args ...interface{})
+}
+
+// New returns a client for the named provider (see provider.New) with the
+// default retry policy. ProviderName is set to the canonical name, so an
+// alias such as "claude" is priced as "anthropic".
+func New(providerName string) (*Client, error) {
+	prov, err := provider.New(providerName)
+	if err != nil {
+		return nil, err
+	}
+	name, _ := provider.Canonical(providerName)
+	return &Client{Provider: prov, ProviderName: name, Retry: retry.Default()}, nil
+}
+
+func (c *Client) logf(format string, args ...interface{}) {
+	if c.Logf != nil {
+		c.Logf(format, args...)
+	}
+}
+
+// withDefaults fills the model and max tokens of opts from the provider's
+// defaults when unset.
+func (c *Client) withDefaults(opts provider.Options) provider.Options {
+	def := c.Provider.DefaultOptions()
+	if strings.TrimSpace(opts.Model) == "" {
+		opts.Model = def.Model
+	}
+	if opts.MaxTokens == 0 {
+		opts.MaxTokens = def.MaxTokens
+	}
+	return opts
+}
+
+// requestOptions returns the API key and headers to send with each request.
+func (c *Client) requestOptions() (provider.RequestOptions, error) {
+	key := c.APIKey
+	if c.APIKeyFunc != nil {
+		var err error
+		if key, err = c.APIKeyFunc(); err != nil {
+			return provider.RequestOptions{}, err
+		}
+	}
+	return provider.RequestOptions{APIKey: key, ExtraHeaders: c.Headers}, nil
+}
+
+// Send builds, sends and parses a single request, returning the response
+// and, when opts.Tools is set, any tool calls the model made. Unset model
+// and max tokens take the provider's defaults. When stream is non-nil the
+// response is streamed and each text delta is written to it as it arrives,
+// followed by a newline if the text does not end with one.
+//
+// A request canceled by ctx returns ctx's error; one that runs past
+// Timeout returns an error wrapping ErrTimeout. A request whose estimated
+// input cost exceeds MaxCost is not sent and returns an error wrapping
+// ErrOverBudget.
+func (c *Client) Send(ctx context.Context, opts provider.Options, stream io.Writer) (provider.Response, []provider.ToolCall, error) {
+	if err := c.checkBudget(c.withDefaults(opts), provider.Response{}); err != nil {
+		return provider.Response{}, nil, err
+	}
+	return c.send(ctx, opts, stream)
+}
+
+// send is Send without the budget check.
+func (c *Client) send(ctx context.Context, opts provider.Options, stream io.Writer) (provider.Response, []provider.ToolCall, error) {
+	parent := ctx
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	opts = c.withDefaults(opts)
+
+	payload, err := c.Provider.BuildAPIPayload(opts)
+	if err != nil {
+		return provider.Response{}, nil, err
+	}
+
+	var streamer provider.StreamingProvider
+	if stream != nil {
+		sp, ok := c.Provider.(provider.StreamingProvider)
+		if !ok {
+			return provider.Response{}, nil, fmt.Errorf("provider %s does not support streaming", c.ProviderName)
+		}
+		sp.EnableStreaming(payload)
+		streamer = sp
+	}
+
+	if c.Logf != nil {
+		// Log the payload (truncated if very large, e.g., with inline images)
+		if b, err := json.MarshalIndent(payload, "", "  "); err == nil {
+			const maxDump = 64 * 1024
+			c.logf("Request payload:\n%s", b[:min(len(b), maxDump)])
+			if len(b) > maxDump {
+				c.logf("(truncated)")
+			}
+		}
+	}
+
+	// Build request (API key resolved in provider if omitted here)
+	reqOpts, err := c.requestOptions()
+	var req *http.Request
+	if err == nil {
+		req, err = c.Provider.BuildAPIRequest(ctx, payload, c.BaseURL, reqOpts)
+	}
+	if err != nil {
+		// Friendly guidance for missing API keys using typed errors
+		var mk provider.MissingAPIKeyError
+		if errors.Is(err, provider.ErrMissingAPIKey) && errors.As(err, &mk) {
+			env := strings.TrimSpace(mk.EnvVar)
+			if env == "" {
+				env = "API_KEY"
+			}
+			return provider.Response{}, nil, fmt.Errorf("%s not found. Set one of:\n  bash/zsh: export %s=sk-...\n  fish:    set -x %s sk-...", env, env, env)
+		}
+		return provider.Response{}, nil, err
+	}
+
+	if c.Logf != nil {
+		c.logRequest(req)
+	}
+
+	policy := c.Retry
+	if policy.Logf == nil {
+		policy.Logf = c.Logf
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	first := req
+	resp, err := policy.Do(httpClient, func() (*http.Request, error) {
+		if first != nil {
+			r := first
+			first = nil
+			return r, nil
+		}
+		return c.Provider.BuildAPIRequest(ctx, payload, c.BaseURL, reqOpts)
+	})
+	// Some servers reject the native schema with a 400; send the request
+	// once more with the prompt hint alone, as local validation still
+	// applies.
+	if fp, ok := c.Provider.(provider.SchemaFallbackProvider); ok && err == nil && resp.StatusCode == http.StatusBadRequest {
+		if fallback, dropped := fp.WithoutNativeSchema(payload); dropped {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+			_ = resp.Body.Close()
+			c.logf("Server rejected the request with status 400 (%s); retrying without the native schema", strings.TrimSpace(string(body)))
+			payload = fallback
+			resp, err = policy.Do(httpClient, func() (*http.Request, error) {
+				return c.Provider.BuildAPIRequest(ctx, payload, c.BaseURL, reqOpts)
+			})
+		}
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return provider.Response{}, nil, c.canceledError(parent, ctx)
+		}
+		// Add a bit more context for common network failures
+		if ue, ok := err.(*url.Error); ok {
+			if _, ok := ue.Err.(*net.OpError); ok || strings.Contains(strings.ToLower(ue.Error()), "no such host") {
+				return provider.Response{}, nil, fmt.Errorf("network error: %v\nCheck connectivity and the base URL (if set).", err)
+			}
+		}
+		return provider.Response{}, nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer func() {
+		// Explicitly ignore close error to satisfy errcheck
+		_ = resp.Body.Close()
+	}()
+
+	// Streamed responses are consumed incrementally on success.
+	if streamer != nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		c.logf("Response status: %d (streaming)", resp.StatusCode)
+		out, err := streamer.ParseAPIStream(resp.Body, func(delta string) {
+			_, _ = io.WriteString(stream, delta)
+		})
+		if err != nil {
+			fmt.Fprintln(stream)
+			if ctx.Err() != nil {
+				return out, nil, c.canceledError(parent, ctx)
+			}
+			return out, nil, err
+		}
+		if !strings.HasSuffix(out.Text, "\n") {
+			fmt.Fprintln(stream)
+		}
+		c.logCost(out, opts.Model)
+		return out, nil, nil
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		if ctx.Err() != nil {
+			return provider.Response{}, nil, c.canceledError(parent, ctx)
+		}
+		return provider.Response{}, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if c.Logf != nil {
+		// Log the raw body (truncated if very large)
+		const maxDump = 64 * 1024
+		c.logf("Response status: %d", resp.StatusCode)
+		c.logf("Raw response:\n%s", respBody[:min(len(respBody), maxDump)])
+		if len(respBody) > maxDump {
+			c.logf("(truncated)")
+		}
+	}
+
+	// Non-2xx handling
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return provider.Response{}, nil, fmt.Errorf("request failed with status %d:\n%s", resp.StatusCode, string(respBody))
+	}
+	// Tool calls come alongside (or instead of) text when tools were offered.
+	var calls []provider.ToolCall
+	if len(opts.Tools) > 0 {
+		tp, ok := c.Provider.(provider.ToolCallingProvider)
+		if !ok {
+			return provider.Response{}, nil, fmt.Errorf("provider %s does not support tool calling", c.ProviderName)
+		}
+		if calls, err = tp.ParseToolCalls(respBody); err != nil {
+			return provider.Response{}, nil, err
+		}
+	}
+	// Parse API response to extract text output (provider-specific)
+	out, err := c.Provider.ParseAPIResponse(respBody)
+	if err == nil {
+		c.logCost(out, opts.Model)
+	}
+	return out, calls, err
+}
+
+// logRequest logs the method, URL and headers of req with secrets redacted.
+func (c *Client) logRequest(req *http.Request) {
+	safeURL := req.URL.String()
+	if u, err := url.Parse(safeURL); err == nil {
+		q := u.Query()
+		if q.Has("key") {
+			q.Set("key", "***")
+			u.RawQuery = q.Encode()
+		}
+		safeURL = u.String()
+	}
+	c.logf("Request: %s %s", req.Method, safeURL)
+	names := make([]string, 0, len(req.Header))
+	for k := range req.Header {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteString("Headers:")
+	for _, k := range names {
+		v := req.Header[k]
+		switch {
+		case isSecretHeader(k):
+			fmt.Fprintf(&b, "\n  %s: ***", k)
+		case len(v) > 0:
+			fmt.Fprintf(&b, "\n  %s: %s", k, v[0])
+		}
+	}
+	c.logf("%s", b.String())
+}
+
+// isSecretHeader reports whether a header likely carries a credential and
+// must be redacted in logs.
+func isSecretHeader(name string) bool {
+	n := strings.ToLower(name)
+	for _, s := range []string{"authorization", "key", "token", "secret", "cookie"} {
+		if strings.Contains(n, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// canceledError reports why ctx, derived from parent, ended a request.
+func (c *Client) canceledError(parent, ctx context.Context) error {
+	if parent.Err() == nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w after %s", ErrTimeout, c.Timeout)
+	}
+	return ctx.Err()
+}
+
+// cost estimates the cost of resp from its usage. The reported model is
+// priced, falling back to the requested one.
+func (c *Client) cost(resp provider.Response, requested string) (float64, bool) {
+	model := resp.Model
+	if model == "" {
+		model = requested
+	}
+	price, ok := c.Prices.Lookup(c.ProviderName, model)
+	if !ok {
+		return 0, false
+	}
+	return price.Cost(resp.Usage), true
+}
+
+func (c *Client) logCost(resp provider.Response, requested string) {
+	if cost, ok := c.cost(resp, requested); ok {
+		c.logf("Estimated cost: $%.6f", cost)
+	}
+}
+
+// checkBudget refuses to send opts when spent plus the estimated input cost
+// of the request exceeds MaxCost. The output cost is unknown before sending
+// and is not included.
+func (c *Client) checkBudget(opts provider.Options, spent provider.Response) error {
+	if c.MaxCost <= 0 {
+		return nil
+	}
+	price, ok := c.Prices.Lookup(c.ProviderName, opts.Model)
+	if !ok {
+		return fmt.Errorf("no price known for %s/%s to check the cost limit; add it to the pricing table", c.ProviderName, opts.Model)
+	}
+	tokens := pricing.EstimateInputTokens(opts)
+	estimate := price.Cost(provider.Usage{InputTokens: tokens})
+	spentCost, _ := c.cost(spent, opts.Model)
+	if spentCost+estimate <= c.MaxCost {
+		return nil
+	}
+	if spentCost > 0 {
+		return fmt.Errorf("%w: spent $%.6f so far and the next request's input is estimated at $%.6f (~%d tokens), exceeding the $%.6f limit; nothing more was sent", ErrOverBudget, spentCost, estimate, tokens, c.MaxCost)
+	}
+	return fmt.Errorf("%w: estimated input cost $%.6f (~%d tokens) exceeds the $%.6f limit; nothing was sent", ErrOverBudget, estimate, tokens, c.MaxCost)
+}
@@ -0,0 +1,251 @@
+package llmx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"llmx/pkg/pricing"
+	"llmx/pkg/provider"
+	"llmx/pkg/retry"
+)
+
+// compatServer returns a client for an openai-compat server that answers
+// each request with the next reply (then the last one again) and records
+// the request payloads.
+func compatServer(t *testing.T, replies ...string) (*Client, *[]map[string]interface{}) {
+	t.Helper()
+	var requests []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &payload)
+		requests = append(requests, payload)
+		reply := replies[min(len(requests), len(replies))-1]
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"model":   "m-1",
+			"choices": []interface{}{map[string]interface{}{"message": map[string]interface{}{"content": reply}, "finish_reason": "stop"}},
+			"usage":   map[string]interface{}{"prompt_tokens": 10, "completion_tokens": 5},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	c, err := New("openai-compat")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	c.BaseURL = srv.URL
+	c.APIKey = "test"
+	return c, &requests
+}
+
+func TestSend_Timeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer srv.Close()
+	c := &Client{Provider: &provider.OpenAICompatProvider{}, BaseURL: srv.URL, APIKey: "test", Timeout: 50 * time.Millisecond}
+
+	_, _, err := c.Send(context.Background(), provider.Options{Model: "m", Message: "hi"}, nil)
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.Timeout = 0
+	_, _, err = c.Send(ctx, provider.Options{Model: "m", Message: "hi"}, nil)
+	if !errors.Is(err, context.Canceled) || errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+}
+
+func TestSend_RetriesGemini(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		_, _ = io.ReadAll(r.Body)
+		if !strings.Contains(r.URL.Path, "/models/gemini-test:generateContent") {
+			t.Errorf("attempt %d: unexpected path %s", calls, r.URL.Path)
+		}
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = io.WriteString(w, `{"candidates":[{"content":{"parts":[{"text":"hi"}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":3,"candidatesTokenCount":1}}`)
+	}))
+	defer srv.Close()
+	c := &Client{
+		Provider: &provider.GeminiProvider{},
+		BaseURL:  srv.URL,
+		APIKey:   "test",
+		Retry:    retry.Policy{MaxRetries: 1, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+	}
+
+	resp, _, err := c.Send(context.Background(), provider.Options{Model: "gemini-test", Message: "hi"}, nil)
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if calls != 2 || resp.Text != "hi" {
+		t.Fatalf("calls = %d, text = %q", calls, resp.Text)
+	}
+}
+
+func TestSend_SchemaFallback(t *testing.T) {
+	var requests []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &payload)
+		requests = append(requests, payload)
+		if _, ok := payload["response_format"]; ok {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = io.WriteString(w, `{"error":{"message":"unknown field response_format"}}`)
+			return
+		}
+		_, _ = io.WriteString(w, `{"choices":[{"message":{"content":"{\"answer\":\"hi\"}"},"finish_reason":"stop"}]}`)
+	}))
+	defer srv.Close()
+	c := &Client{Provider: &provider.OpenAICompatProvider{}, BaseURL: srv.URL, APIKey: "test"}
+
+	resp, err := c.Generate(context.Background(), Request{Format: "answer"})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if resp.Output["answer"] != "hi" || len(requests) != 2 {
+		t.Fatalf("output = %v after %d requests", resp.Output, len(requests))
+	}
+	if _, ok := requests[1]["response_format"]; ok {
+		t.Fatalf("the retry should drop response_format")
+	}
+
+	// Without a native schema there is nothing to fall back from.
+	requests = nil
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, nil)
+		w.WriteHeader(http.StatusBadRequest)
+	})
+	if _, err := c.Generate(context.Background(), Request{}); err == nil || len(requests) != 1 {
+		t.Fatalf("expected a single failed request, got %v after %d requests", err, len(requests))
+	}
+}
+
+func TestSend_LogsRedacted(t *testing.T) {
+	c, _ := compatServer(t, "hi")
+	c.Headers = map[string]string{"X-Team": "search", "X-Auth-Token": "secret"}
+	var logs []string
+	c.Logf = func(format string, args ...interface{}) {
+		logs = append(logs, format)
+		for _, a := range args {
+			if s, ok := a.(string); ok {
+				logs = append(logs, s)
+			}
+		}
+	}
+	resp, _, err := c.Send(context.Background(), provider.Options{Message: "hi"}, nil)
+	if err != nil || resp.Text != "hi" {
+		t.Fatalf("Send = %q, %v", resp.Text, err)
+	}
+	all := strings.Join(logs, "\n")
+	if strings.Contains(all, "secret") || strings.Contains(all, "Bearer test") {
+		t.Fatalf("secrets leaked into logs:\n%s", all)
+	}
+	if !strings.Contains(all, "X-Team: search") {
+		t.Fatalf("headers not logged:\n%s", all)
+	}
+}
+
+func TestSend_OverBudget(t *testing.T) {
+	c, requests := compatServer(t, "hi")
+	c.Prices = pricing.Table{"openai-compat/m": {Input: 1, Output: 2}}
+	c.MaxCost = 0.0005
+	opts := provider.Options{Model: "m", Message: strings.Repeat("x", 4000)} // ~1000 tokens, $0.001
+
+	if _, _, err := c.Send(context.Background(), opts, nil); !errors.Is(err, ErrOverBudget) {
+		t.Fatalf("expected over budget, got %v", err)
+	}
+	if len(*requests) != 0 {
+		t.Fatalf("nothing should be sent, got %d requests", len(*requests))
+	}
+	c.MaxCost = 0.01
+	if _, _, err := c.Send(context.Background(), opts, nil); err != nil || len(*requests) != 1 {
+		t.Fatalf("within budget: %v (%d requests)", err, len(*requests))
+	}
+}
+
+func TestIsSecretHeader(t *testing.T) {
+	for name, want := range map[string]bool{
+		"Authorization":  true,
+		"X-Api-Key":      true,
+		"X-Goog-Api-Key": true,
+		"X-Auth-Token":   true,
+		"X-Team":         false,
+		"Content-Type":   false,
+	} {
+		if got := isSecretHeader(name); got != want {
+			t.Errorf("isSecretHeader(%q) = %v", name, got)
+		}
+	}
+}
+
+func TestCheckBudget(t *testing.T) {
+	c := &Client{ProviderName: "openai", Prices: pricing.Table{"openai/m": {Input: 1, Output: 2}}}
+	opts := provider.Options{Model: "m", Message: strings.Repeat("x", 4000)} // ~1000 tokens, $0.001
+
+	if err := c.checkBudget(opts, provider.Response{}); err != nil {
+		t.Fatalf("no limit: %v", err)
+	}
+	c.MaxCost = 0.01
+	if err := c.checkBudget(opts, provider.Response{}); err != nil {
+		t.Fatalf("within budget: %v", err)
+	}
+	spent := provider.Response{Usage: provider.Usage{InputTokens: 1000, OutputTokens: 5000}} // $0.011
+	if err := c.checkBudget(opts, spent); !errors.Is(err, ErrOverBudget) {
+		t.Fatalf("expected over budget after spending, got %v", err)
+	}
+	c.MaxCost = 0.0005
+	if err := c.checkBudget(opts, provider.Response{}); !errors.Is(err, ErrOverBudget) {
+		t.Fatalf("expected over budget, got %v", err)
+	}
+	opts.Model = "unpriced"
+	if err := c.checkBudget(opts, provider.Response{}); err == nil || errors.Is(err, ErrOverBudget) {
+		t.Fatalf("expected missing price error, got %v", err)
+	}
+}
+
+func TestNew_AliasPricing(t *testing.T) {
+	srv, _ := compatServer(t, "hi")
+	c, err := New("compat")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if c.ProviderName != "openai-compat" {
+		t.Fatalf("ProviderName = %q, want the canonical name", c.ProviderName)
+	}
+	c.BaseURL, c.APIKey = srv.BaseURL, srv.APIKey
+	c.Prices = pricing.Table{"openai-compat/m-1": {Input: 1, Output: 2}}
+
+	req := Request{}
+	req.Model, req.Message = "m-1", "hi"
+	resp, err := c.Generate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if resp.CostUSD == nil || *resp.CostUSD != 0.00002 {
+		t.Fatalf("cost = %v", resp.CostUSD)
+	}
+
+	c.MaxCost = 0.0000001
+	if _, err := c.Generate(context.Background(), req); !errors.Is(err, ErrOverBudget) {
+		t.Fatalf("expected the budget check to price the alias, got %v", err)
+	}
+}
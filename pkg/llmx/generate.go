@@ -0,0 +1,337 @@
+package llmx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"llmx/pkg/parser"
+	"llmx/pkg/provider"
+	"llmx/pkg/tools"
+	"llmx/pkg/validator"
+)
+
+// Request is a request for Generate. The output object is described by
+// Options.Schema, Options.Properties/Required or Format, in that order of
+// precedence; with none of them the model answers in plain text.
+type Request struct {
+	provider.Options
+	// Format is the --format shorthand for the output object (e.g.,
+	// "name,age:integer").
+	Format string
+	// ErrorKey names the output's error field. A non-empty value in it fails
+	// the request with a ReportedError. Empty means "error"; any other key
+	// must be in the schema.
+	ErrorKey string
+	// Repairs is how many times the model is asked again when its output
+	// does not decode or match the schema, or its tool calls have invalid
+	// arguments.
+	Repairs int
+	// NoValidate skips validating the output and tool call arguments against
+	// their schemas.
+	NoValidate bool
+	// Stream, when set, streams each response and writes its text deltas to
+	// Stream as they arrive.
+	Stream io.Writer
+}
+
+// Response is the result of Generate.
+type Response struct {
+	// Response holds the metadata of the last attempt, with Usage summed
+	// over all attempts and Text set to the last raw output.
+	provider.Response
+	// Output is the decoded structured output. It is nil for plain text and
+	// when the model called tools.
+	Output map[string]interface{} `json:"-"`
+	// ToolCalls are the tool calls the model made instead of answering.
+	ToolCalls []provider.ToolCall `json:"-"`
+	// CostUSD is the estimated cost of all attempts, when the model has a
+	// price in Client.Prices.
+	CostUSD *float64 `json:"cost_usd,omitempty"`
+}
+
+// ErrReported is the sentinel wrapped by ReportedError.
+var ErrReported = errors.New("model reported an error")
+
+// ReportedError is returned when the structured output sets its error
+// field. It unwraps to ErrReported.
+type ReportedError struct {
+	// Key is the error field.
+	Key string
+	// Message is the field's value.
+	Message string
+}
+
+func (e ReportedError) Error() string { return e.Message }
+
+func (e ReportedError) Unwrap() error { return ErrReported }
+
+// SchemaError reports output, or tool call arguments, that do not match
+// their schema. It unwraps to the violations.
+type SchemaError struct {
+	// Tool names the tool whose call arguments are invalid; it is empty for
+	// the output object.
+	Tool   string
+	Errors validator.Errors
+}
+
+func (e SchemaError) Error() string {
+	var b strings.Builder
+	if e.Tool != "" {
+		fmt.Fprintf(&b, "tool call %s arguments do not match schema:", e.Tool)
+	} else {
+		b.WriteString("structured output does not match schema:")
+	}
+	for _, ve := range e.Errors {
+		b.WriteString("\n  " + ve.Error())
+	}
+	return b.String()
+}
+
+func (e SchemaError) Unwrap() error { return e.Errors }
+
+// Generate sends req and returns the decoded output, retrying transient HTTP
+// failures and asking the model to repair rejected output up to
+// req.Repairs times. When the model calls tools, the calls are returned
+// instead. Each attempt is checked against MaxCost before it is sent.
+//
+// The returned Response carries the usage of every attempt even when an
+// error is returned. Output that never validates returns a SchemaError (or
+// the decode error); a set error field returns a ReportedError.
+func (c *Client) Generate(ctx context.Context, req Request) (Response, error) {
+	opts, schema, errorKey, err := c.prepare(req)
+	if err != nil {
+		return Response{}, err
+	}
+	var out Response
+	attempts := req.Repairs + 1
+	for attempt := 1; ; attempt++ {
+		if attempts > 1 {
+			c.logf("Attempt %d/%d", attempt, attempts)
+		}
+		if err := c.checkBudget(opts, out.Response); err != nil {
+			return out, err
+		}
+		resp, calls, err := c.send(ctx, opts, req.Stream)
+		c.add(&out, resp, opts.Model)
+		if err != nil {
+			return out, err
+		}
+
+		var problem error
+		switch {
+		case len(calls) > 0:
+			// The model chose to call tools: return the calls instead of an
+			// answer once their arguments check out.
+			if !req.NoValidate {
+				problem = checkCalls(opts.Tools, calls)
+			}
+			if problem == nil {
+				out.ToolCalls = calls
+				return out, nil
+			}
+		case schema == nil:
+			return out, nil
+		default:
+			obj, err := decodeObject(resp.Text)
+			if err != nil {
+				problem = err
+				break
+			}
+			if err := CheckErrorField(obj, errorKey); err != nil {
+				return out, err
+			}
+			// Validate locally; providers whose enforcement is missing or
+			// best-effort (Anthropic, servers that ignore response_format)
+			// rely on this.
+			if !req.NoValidate {
+				if verrs := validator.Validate(schema, obj); len(verrs) > 0 {
+					problem = SchemaError{Errors: verrs}
+				}
+			}
+			if problem == nil {
+				out.Output = obj
+				return out, nil
+			}
+		}
+
+		if attempt >= attempts {
+			return out, problem
+		}
+		c.logf("Attempt %d/%d rejected: %v", attempt, attempts, problem)
+		if len(calls) > 0 {
+			// Tool calls cannot be quoted back as a repair turn; ask again.
+			continue
+		}
+		// Ask the same provider to repair its output in a follow-up turn. The
+		// original message moves into the history with its attachments; a
+		// request made of attachments or history alone adds no empty turn.
+		if opts.Message != "" || len(opts.Images) > 0 || len(opts.Files) > 0 {
+			opts.History = append(opts.History, provider.Turn{Role: provider.RoleUser, Content: opts.Message, Images: opts.Images, Files: opts.Files})
+		}
+		opts.History = append(opts.History, provider.Turn{Role: provider.RoleAssistant, Content: resp.Text})
+		opts.Message, opts.Images, opts.Files = RepairMessage(problem), nil, nil
+	}
+}
+
+// GenerateInto runs Generate and decodes the structured output into v, a
+// pointer to a struct or map matching the schema. When req sets no Format,
+// Properties or Schema, the schema is derived from v's struct type with
+// parser.StructSchema. When the model calls tools instead, v is left
+// unchanged and the calls are in the Response.
+func (c *Client) GenerateInto(ctx context.Context, req Request, v interface{}) (Response, error) {
+	if req.Schema == nil && len(req.Properties) == 0 && strings.TrimSpace(req.Format) == "" {
+		s, err := parser.StructSchema(v)
+		if err != nil {
+			return Response{}, fmt.Errorf("GenerateInto needs structured output: set Format, Properties or Schema, or pass a pointer to a struct: %w", err)
+		}
+		req.Properties = s["properties"].(map[string]interface{})
+		req.Required = s["required"].([]string)
+	}
+	resp, err := c.Generate(ctx, req)
+	if err != nil || resp.Output == nil {
+		return resp, err
+	}
+	if err := json.Unmarshal([]byte(StripCodeFence(resp.Text)), v); err != nil {
+		return resp, fmt.Errorf("failed to decode output into %T: %w", v, err)
+	}
+	return resp, nil
+}
+
+// prepare resolves the options to send, the schema to validate against (nil
+// for plain text) and the error key of req.
+func (c *Client) prepare(req Request) (provider.Options, map[string]interface{}, string, error) {
+	opts := c.withDefaults(req.Options)
+	if opts.Schema == nil && len(opts.Properties) == 0 && strings.TrimSpace(req.Format) != "" {
+		s, err := parser.ParseFormatSchema(req.Format)
+		if err != nil {
+			return provider.Options{}, nil, "", fmt.Errorf("failed to parse format: %w", err)
+		}
+		opts.Properties = s["properties"].(map[string]interface{})
+		opts.Required = s["required"].([]string)
+	}
+	if req.Repairs < 0 {
+		return provider.Options{}, nil, "", errors.New("repairs must be >= 0")
+	}
+
+	errorKey := req.ErrorKey
+	if strings.TrimSpace(errorKey) == "" {
+		errorKey = "error"
+	}
+	schema := opts.Schema
+	if schema == nil {
+		if len(opts.Properties) == 0 {
+			return opts, nil, errorKey, nil
+		}
+		schema = validator.ObjectSchema(opts.Properties, opts.Required)
+	}
+	if errorKey != "error" {
+		props, _ := schema["properties"].(map[string]interface{})
+		if _, ok := props[errorKey]; !ok {
+			return provider.Options{}, nil, "", fmt.Errorf("error key %q is not in the schema", errorKey)
+		}
+	}
+	return opts, schema, errorKey, nil
+}
+
+// add folds resp into out: usage accumulates across attempts while the id,
+// model, finish reason and text are the latest.
+func (c *Client) add(out *Response, resp provider.Response, requested string) {
+	usage := out.Usage
+	usage.Add(resp.Usage)
+	out.Response = resp
+	out.Usage = usage
+	out.CostUSD = nil
+	if cost, ok := c.cost(out.Response, requested); ok {
+		out.CostUSD = &cost
+	}
+}
+
+// checkCalls validates tool calls against the declared tools.
+func checkCalls(declared []provider.Tool, calls []provider.ToolCall) error {
+	for _, call := range calls {
+		if err := tools.ValidateCall(declared, call); err != nil {
+			var verrs validator.Errors
+			if errors.As(err, &verrs) {
+				return SchemaError{Tool: call.Name, Errors: verrs}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeOutput decodes the JSON object in a model's text output, which may
+// be wrapped in a Markdown code fence, and validates it against schema
+// unless schema is nil. Violations are returned as a SchemaError along with
+// the decoded object.
+func DecodeOutput(text string, schema map[string]interface{}) (map[string]interface{}, error) {
+	obj, err := decodeObject(text)
+	if err != nil {
+		return nil, err
+	}
+	if schema != nil {
+		if verrs := validator.Validate(schema, obj); len(verrs) > 0 {
+			return obj, SchemaError{Errors: verrs}
+		}
+	}
+	return obj, nil
+}
+
+func decodeObject(text string) (map[string]interface{}, error) {
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(StripCodeFence(text)), &obj); err != nil {
+		return nil, fmt.Errorf("failed to decode structured JSON output: %w", err)
+	}
+	return obj, nil
+}
+
+// StripCodeFence removes surrounding Markdown code fences and leading/trailing
+// whitespace to prepare a string for JSON unmarshalling.
+func StripCodeFence(s string) string {
+	t := strings.TrimSpace(s)
+	if !strings.HasPrefix(t, "```") || !strings.HasSuffix(t, "```") {
+		return t
+	}
+	head := strings.IndexByte(t, '\n')
+	if head < 0 {
+		return t
+	}
+	tail := strings.LastIndex(t, "```")
+	if tail < 0 || tail <= head {
+		return t
+	}
+	return strings.TrimSpace(t[head+1 : tail])
+}
+
+// CheckErrorField returns a ReportedError when the error field key of a
+// structured output holds a message. An absent or empty field, or JSON null
+// (e.g., a nullable or omitted optional error key), means no error.
+func CheckErrorField(obj map[string]interface{}, key string) error {
+	es, _ := obj[key].(string)
+	es = strings.TrimSpace(es)
+	if es == "" || es == "null" {
+		return nil
+	}
+	return ReportedError{Key: key, Message: es}
+}
+
+// RepairMessage asks the model to correct a rejected response, quoting the
+// decode error or every schema violation.
+func RepairMessage(problem error) string {
+	var b strings.Builder
+	var verrs validator.Errors
+	if errors.As(problem, &verrs) {
+		b.WriteString("Your previous response does not match the required JSON schema:\n")
+		for _, ve := range verrs {
+			b.WriteString("- " + ve.Error() + "\n")
+		}
+	} else {
+		b.WriteString("Your previous response is not valid JSON: " + problem.Error() + "\n")
+	}
+	b.WriteString("Reply with the corrected JSON object only. NO PROSE, NO EXPLANATIONS, NO MARKDOWN.")
+	return b.String()
+}
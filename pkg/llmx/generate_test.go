@@ -0,0 +1,252 @@
+package llmx
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"llmx/pkg/pricing"
+	"llmx/pkg/provider"
+	"llmx/pkg/validator"
+)
+
+func TestGenerate_Repair(t *testing.T) {
+	c, requests := compatServer(t, `{"name":"Ann","age":"three"}`, "```json\n{\"name\":\"Ann\",\"age\":3}\n```")
+	c.Prices = pricing.Table{"openai-compat/m-1": {Input: 1, Output: 2}}
+	c.ProviderName = "openai-compat"
+
+	req := Request{Format: "name,age:integer", Repairs: 1}
+	req.Message = "Ann is three."
+	resp, err := c.Generate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if resp.Output["age"] != float64(3) || len(*requests) != 2 {
+		t.Fatalf("output = %v after %d requests", resp.Output, len(*requests))
+	}
+	if resp.Usage.InputTokens != 20 || resp.Usage.OutputTokens != 10 {
+		t.Fatalf("usage should add up over attempts: %+v", resp.Usage)
+	}
+	if resp.CostUSD == nil || *resp.CostUSD != 0.00004 {
+		t.Fatalf("cost = %v", resp.CostUSD)
+	}
+	msgs := (*requests)[1]["messages"].([]interface{})
+	repair := msgs[len(msgs)-1].(map[string]interface{})["content"].(string)
+	if !strings.Contains(repair, "/age: expected integer") {
+		t.Fatalf("repair turn should quote the violation: %q", repair)
+	}
+
+	var person struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+	}
+	if _, err := c.GenerateInto(context.Background(), req, &person); err != nil || person.Name != "Ann" || person.Age != 3 {
+		t.Fatalf("GenerateInto = %+v, %v", person, err)
+	}
+}
+
+func TestGenerate_OptionalNull(t *testing.T) {
+	// Strict providers fill optional keys with null rather than omit them.
+	c, _ := compatServer(t, `{"name":"a","nick":null}`)
+	resp, err := c.Generate(context.Background(), Request{Format: "name:string,nick?:string"})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if v, ok := resp.Output["nick"]; !ok || v != nil {
+		t.Fatalf("output = %v", resp.Output)
+	}
+}
+
+func TestGenerate_RepairWithoutMessage(t *testing.T) {
+	c, requests := compatServer(t, `{"answer":1}`, `{"answer":"a"}`)
+	req := Request{Format: "answer", Repairs: 1}
+	req.Images = []provider.Image{{MIMEType: "image/png", Data: []byte("png")}}
+	if _, err := c.Generate(context.Background(), req); err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	// system, user (image only), assistant, repair
+	msgs := (*requests)[1]["messages"].([]interface{})
+	if len(msgs) != 4 {
+		t.Fatalf("expected 4 messages, got %v", msgs)
+	}
+	replayed := msgs[1].(map[string]interface{})
+	parts, ok := replayed["content"].([]interface{})
+	if !ok || len(parts) != 1 || parts[0].(map[string]interface{})["type"] != "image_url" {
+		t.Fatalf("the replayed turn should carry the image alone: %v", replayed)
+	}
+	if repair := msgs[3].(map[string]interface{}); repair["role"] != "user" || !strings.Contains(repair["content"].(string), "/answer") {
+		t.Fatalf("repair turn mismatch: %v", repair)
+	}
+
+	// With history alone no empty user turn is added.
+	c, requests = compatServer(t, `{"answer":1}`, `{"answer":"a"}`)
+	req = Request{Format: "answer", Repairs: 1}
+	req.History = []provider.Turn{{Role: provider.RoleUser, Content: "hi"}}
+	if _, err := c.Generate(context.Background(), req); err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	msgs = (*requests)[1]["messages"].([]interface{})
+	for _, m := range msgs {
+		if m.(map[string]interface{})["content"] == "" {
+			t.Fatalf("empty turn sent: %v", msgs)
+		}
+	}
+	if len(msgs) != 4 {
+		t.Fatalf("expected system, user, assistant and repair, got %v", msgs)
+	}
+}
+
+func TestGenerate_Errors(t *testing.T) {
+	c, requests := compatServer(t, `{"answer":1}`)
+	req := Request{Format: "answer"}
+	_, err := c.Generate(context.Background(), req)
+	var se SchemaError
+	if !errors.As(err, &se) || len(se.Errors) != 1 || !strings.HasPrefix(err.Error(), "structured output does not match schema:\n  /answer") {
+		t.Fatalf("expected a schema error, got %v", err)
+	}
+	req.NoValidate = true
+	if resp, err := c.Generate(context.Background(), req); err != nil || resp.Output["answer"] != float64(1) {
+		t.Fatalf("NoValidate: %v, %v", resp.Output, err)
+	}
+
+	c, requests = compatServer(t, `{"message":"","problem":"no input"}`)
+	_, err = c.Generate(context.Background(), Request{Format: "message,problem", ErrorKey: "problem"})
+	var re ReportedError
+	if !errors.As(err, &re) || re.Message != "no input" || !errors.Is(err, ErrReported) {
+		t.Fatalf("expected the reported error, got %v", err)
+	}
+	n := len(*requests)
+	if _, err := c.Generate(context.Background(), Request{Format: "message", ErrorKey: "problem"}); err == nil {
+		t.Fatalf("expected an error for an error key outside the schema")
+	}
+	if len(*requests) != n {
+		t.Fatalf("nothing should be sent for an invalid request")
+	}
+
+	c, _ = compatServer(t, "plain words")
+	if resp, err := c.Generate(context.Background(), Request{}); err != nil || resp.Text != "plain words" || resp.Output != nil {
+		t.Fatalf("plain text: %q, %v", resp.Text, err)
+	}
+	if _, err := c.GenerateInto(context.Background(), Request{}, &map[string]interface{}{}); err == nil {
+		t.Fatalf("GenerateInto without a schema or struct should fail")
+	}
+}
+
+func TestGenerateInto_StructSchema(t *testing.T) {
+	c, requests := compatServer(t, `{"name":"Ann","mood":"happy","pets":[{"kind":"cat"}],"nickname":null}`)
+	var person struct {
+		Name     string  `json:"name" jsonschema:"description=Full name"`
+		Mood     string  `json:"mood" jsonschema:"enum=happy|sad"`
+		Nickname *string `json:"nickname"`
+		Pets     []struct {
+			Kind string `json:"kind"`
+		} `json:"pets"`
+	}
+	req := Request{}
+	req.Message = "Ann is happy and has a cat."
+	if _, err := c.GenerateInto(context.Background(), req, &person); err != nil {
+		t.Fatalf("GenerateInto: %v", err)
+	}
+	if person.Name != "Ann" || person.Mood != "happy" || person.Nickname != nil || len(person.Pets) != 1 || person.Pets[0].Kind != "cat" {
+		t.Fatalf("decoded %+v", person)
+	}
+	sys := (*requests)[0]["messages"].([]interface{})[0].(map[string]interface{})["content"].(string)
+	for _, want := range []string{`name: string (description: "Full name")`, `mood: string (one of: "happy", "sad")`, "nickname?: string|null", "pets: array<object{kind: string}>"} {
+		if !strings.Contains(sys, want) {
+			t.Fatalf("schema hint missing %s:\n%s", want, sys)
+		}
+	}
+}
+
+func TestDecodeOutput(t *testing.T) {
+	schema := validator.ObjectSchema(map[string]interface{}{"a": map[string]interface{}{"type": "integer"}}, nil)
+	if obj, err := DecodeOutput("```json\n{\"a\": 1}\n```", schema); err != nil || obj["a"] != float64(1) {
+		t.Fatalf("DecodeOutput = %v, %v", obj, err)
+	}
+	if _, err := DecodeOutput(`{"a": "x"}`, schema); !errors.As(err, new(SchemaError)) {
+		t.Fatalf("expected a schema error, got %v", err)
+	}
+	if _, err := DecodeOutput("not json", nil); err == nil || !strings.HasPrefix(err.Error(), "failed to decode") {
+		t.Fatalf("expected a decode error, got %v", err)
+	}
+	if err := CheckErrorField(map[string]interface{}{"error": "null"}, "error"); err != nil {
+		t.Fatalf("null error field should pass: %v", err)
+	}
+}
+
+func TestStripCodeFence(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "json with language tag",
+			in:   "```json\n{\n  \"a\": 1\n}\n```\n",
+			want: "{\n  \"a\": 1\n}",
+		},
+		{
+			name: "plain fence no language",
+			in:   "```\n{\n  \"a\": 1\n}\n```\n",
+			want: "{\n  \"a\": 1\n}",
+		},
+		{
+			name: "no closing fence",
+			in:   "```json\n{\n  \"a\": 1\n}",
+			want: "```json\n{\n  \"a\": 1\n}",
+		},
+		{
+			name: "incidental fence inside body only",
+			in:   "prefix\n```something\nbody\n",
+			want: "prefix\n```something\nbody",
+		},
+		{
+			name: "closing fence not last non-empty line",
+			in:   "```json\n{\n  \"a\": 1\n}\n```\ntrailer\n",
+			want: "```json\n{\n  \"a\": 1\n}\n```\ntrailer",
+		},
+		{
+			name: "trailing blanks after closing fence",
+			in:   "```json\n{\n  \"a\": 1\n}\n```\n\n\n",
+			want: "{\n  \"a\": 1\n}",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := StripCodeFence(tc.in)
+			if got != tc.want {
+				t.Fatalf("got:\n%q\nwant:\n%q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRepairMessage(t *testing.T) {
+	msg := RepairMessage(SchemaError{Errors: validator.Errors{
+		{Pointer: "/age", Message: "expected integer, got string"},
+		{Pointer: "/name", Message: "required property is missing"},
+	}})
+	for _, want := range []string{"does not match the required JSON schema", "- /age: expected integer, got string", "- /name: required property is missing", "corrected JSON object only"} {
+		if !strings.Contains(msg, want) {
+			t.Fatalf("repair message missing %q:\n%s", want, msg)
+		}
+	}
+
+	msg = RepairMessage(errors.New("failed to decode structured JSON output: unexpected end of JSON input"))
+	if !strings.Contains(msg, "not valid JSON: failed to decode") {
+		t.Fatalf("repair message should quote decode error:\n%s", msg)
+	}
+}
+
+func TestCheckCalls(t *testing.T) {
+	tool := provider.Tool{Name: "get_weather", Properties: map[string]interface{}{"city": map[string]interface{}{"type": "string"}}}
+	err := checkCalls([]provider.Tool{tool}, []provider.ToolCall{{Name: "get_weather", Arguments: map[string]interface{}{"city": 1}}})
+	var se SchemaError
+	if !errors.As(err, &se) || se.Tool != "get_weather" || !strings.HasPrefix(se.Error(), "tool call get_weather arguments") {
+		t.Fatalf("expected a tool schema error, got %v", err)
+	}
+	if err := checkCalls([]provider.Tool{tool}, []provider.ToolCall{{Name: "book_table"}}); err == nil || errors.As(err, &se) {
+		t.Fatalf("expected an undeclared tool error, got %v", err)
+	}
+}
@@ -2,39 +2,80 @@ package parser
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 )
 
 // ParseFormat parses a format string like "key1:type,key2:type,..." into properties map.
-// Supports array types: "key:type[]". All fields are considered required by consumers.
+// Supports array types: "key:type[]", nested objects: "key:{k:type,...}" or
+// "key:{k:type,...}[]", string enums: "key:enum(a|b|c)", optional keys: "key?:type"
+// and nullable types: "key:type|null". Use ParseFormatSchema to learn which
+// top-level keys are required.
 func ParseFormat(format string) (map[string]interface{}, error) {
+	schema, err := ParseFormatSchema(format)
+	if err != nil {
+		return nil, err
+	}
+	return schema["properties"].(map[string]interface{}), nil
+}
+
+// ParseFormatSchema parses a format string into a root object schema of the
+// form {"type":"object","properties":{...},"required":[...]}, where required
+// lists every key not marked optional with "?".
+func ParseFormatSchema(format string) (map[string]interface{}, error) {
 	if format == "" {
-		return make(map[string]interface{}), nil
+		return map[string]interface{}{
+			"type":       "object",
+			"properties": make(map[string]interface{}),
+			"required":   []string{},
+		}, nil
 	}
+	return parseObjectFields(format)
+}
 
+// parseObjectFields parses a comma-separated list of "key[:type]" pairs into an
+// object schema. It is used both for the top-level format and for the body of
+// nested object types.
+func parseObjectFields(format string) (map[string]interface{}, error) {
 	properties := make(map[string]interface{})
+	optional := make(map[string]bool)
 
-	pairs := strings.Split(format, ",")
+	pairs, err := splitTopLevel(format, ',')
+	if err != nil {
+		return nil, err
+	}
 	for _, pair := range pairs {
 		trimmed := strings.TrimSpace(pair)
 		if trimmed == "" {
 			return nil, fmt.Errorf("invalid format pair: %s", pair)
 		}
 
-		parts := strings.SplitN(trimmed, ":", 2)
+		parts, err := splitTopLevel(trimmed, ':')
+		if err != nil {
+			return nil, err
+		}
+		// if extra colon remains in type portion, treat as error (e.g., name:string:string)
+		if len(parts) > 2 {
+			return nil, fmt.Errorf("invalid format pair: %s", pair)
+		}
 		key := strings.TrimSpace(parts[0])
+		keyIsOptional := false
+		if strings.HasSuffix(key, "?") {
+			keyIsOptional = true
+			key = strings.TrimSpace(strings.TrimSuffix(key, "?"))
+		}
 		keyIsArray := false
 		if strings.HasSuffix(key, "[]") {
 			keyIsArray = true
 			key = strings.TrimSpace(strings.TrimSuffix(key, "[]"))
 		}
+		if !keyIsOptional && strings.HasSuffix(key, "?") {
+			keyIsOptional = true
+			key = strings.TrimSpace(strings.TrimSuffix(key, "?"))
+		}
 		// default type to string when omitted or empty (e.g., "name" or "name:")
 		typeStr := "string"
 		if len(parts) == 2 {
-			// if extra colon remains in type portion, treat as error (e.g., name:string:string)
-			if strings.Contains(parts[1], ":") {
-				return nil, fmt.Errorf("invalid format pair: %s", pair)
-			}
 			if ts := strings.TrimSpace(parts[1]); ts != "" {
 				typeStr = ts
 			}
@@ -43,48 +84,191 @@ func ParseFormat(format string) (map[string]interface{}, error) {
 		if key == "" {
 			return nil, fmt.Errorf("empty key in format pair: %s", pair)
 		}
+		if strings.ContainsAny(key, "{}[]()?|") {
+			return nil, fmt.Errorf("invalid key in format pair: %s", pair)
+		}
 
+		schema, err := parseType(typeStr)
+		if err != nil {
+			return nil, err
+		}
 		// Support arrays specified either in type (e.g., string[]) or as key[] shorthand.
 		// If both key[] and type[] are used together, treat as nested which is unsupported.
-		if keyIsArray && strings.HasSuffix(typeStr, "[]") {
-			return nil, fmt.Errorf("nested array types are not supported: %s", trimmed)
-		}
-
 		if keyIsArray {
-			// key[] with omitted or empty type defaults to string[]
-			elementType := strings.TrimSpace(typeStr)
-			if elementType == "" {
-				elementType = "string"
-			}
-			if strings.HasSuffix(elementType, "[]") {
+			if t, _ := schema["type"].(string); t == "array" {
 				return nil, fmt.Errorf("nested array types are not supported: %s", trimmed)
 			}
-			properties[key] = map[string]interface{}{
-				"type": "array",
-				"items": map[string]interface{}{
-					"type": elementType,
-				},
+			// key[] with omitted or empty type defaults to string[]
+			schema = map[string]interface{}{
+				"type":  "array",
+				"items": schema,
 			}
-		} else if strings.HasSuffix(typeStr, "[]") {
-			elementType := strings.TrimSpace(strings.TrimSuffix(typeStr, "[]"))
-			if elementType == "" {
-				return nil, fmt.Errorf("empty element type in array specification: %s", typeStr)
+		}
+		properties[key] = schema
+		if keyIsOptional {
+			optional[key] = true
+		} else {
+			delete(optional, key)
+		}
+	}
+
+	required := make([]string, 0, len(properties))
+	for _, k := range sortedKeys(properties) {
+		if !optional[k] {
+			required = append(required, k)
+		}
+	}
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}, nil
+}
+
+// parseType converts a single type expression (e.g., "string", "integer[]",
+// "{name:string}", "{sku:string}[]", "string|null") into its JSON Schema
+// fragment. Nullable types are marked with "nullable": true.
+func parseType(typeStr string) (map[string]interface{}, error) {
+	t := strings.TrimSpace(typeStr)
+
+	union, err := splitTopLevel(t, '|')
+	if err != nil {
+		return nil, err
+	}
+	if len(union) > 1 {
+		if len(union) != 2 || strings.TrimSpace(union[1]) != "null" {
+			return nil, fmt.Errorf("unsupported union type (only type|null is allowed): %s", typeStr)
+		}
+		base := strings.TrimSpace(union[0])
+		if base == "" || base == "null" {
+			return nil, fmt.Errorf("invalid nullable type: %s", typeStr)
+		}
+		schema, err := parseType(base)
+		if err != nil {
+			return nil, err
+		}
+		schema["nullable"] = true
+		return schema, nil
+	}
+
+	if strings.HasSuffix(t, "[]") {
+		elementType := strings.TrimSpace(strings.TrimSuffix(t, "[]"))
+		if elementType == "" {
+			return nil, fmt.Errorf("empty element type in array specification: %s", typeStr)
+		}
+		if strings.HasSuffix(elementType, "[]") {
+			return nil, fmt.Errorf("nested array types are not supported: %s", typeStr)
+		}
+		items, err := parseType(elementType)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"type":  "array",
+			"items": items,
+		}, nil
+	}
+
+	if strings.HasPrefix(t, "{") {
+		if !strings.HasSuffix(t, "}") {
+			return nil, fmt.Errorf("unterminated object type: %s", typeStr)
+		}
+		body := t[1 : len(t)-1]
+		if strings.TrimSpace(body) == "" {
+			return nil, fmt.Errorf("empty object type: %s", typeStr)
+		}
+		return parseObjectFields(body)
+	}
+
+	if strings.HasPrefix(t, "enum(") {
+		return parseEnum(t)
+	}
+
+	switch t {
+	case "string", "number", "integer", "boolean":
+		return map[string]interface{}{"type": t}, nil
+	}
+	return nil, fmt.Errorf("invalid type: %s (use string, number, integer, boolean, {...}, enum(...) or type[])", typeStr)
+}
+
+// parseEnum converts "enum(a|b|c)" into a string schema restricted to the
+// listed values.
+func parseEnum(t string) (map[string]interface{}, error) {
+	if !strings.HasSuffix(t, ")") {
+		return nil, fmt.Errorf("unterminated enum type: %s", t)
+	}
+	body := t[len("enum(") : len(t)-1]
+	if strings.TrimSpace(body) == "" {
+		return nil, fmt.Errorf("empty enum type: %s", t)
+	}
+	values := make([]string, 0, strings.Count(body, "|")+1)
+	seen := make(map[string]bool)
+	for _, raw := range strings.Split(body, "|") {
+		v := strings.TrimSpace(raw)
+		if v == "" {
+			return nil, fmt.Errorf("empty enum value in: %s", t)
+		}
+		if strings.ContainsAny(v, "{}[]()") {
+			return nil, fmt.Errorf("invalid enum value %q in: %s", v, t)
+		}
+		if strings.Contains(v, ",") {
+			return nil, fmt.Errorf("invalid enum value %q in: %s (separate enum values with |)", v, t)
+		}
+		if seen[v] {
+			return nil, fmt.Errorf("duplicate enum value %q in: %s", v, t)
+		}
+		seen[v] = true
+		values = append(values, v)
+	}
+	return map[string]interface{}{
+		"type": "string",
+		"enum": values,
+	}, nil
+}
+
+// splitTopLevel splits s on sep, ignoring separators nested inside braces or
+// parentheses.
+func splitTopLevel(s string, sep byte) ([]string, error) {
+	var parts []string
+	braces, parens := 0, 0
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			braces++
+		case '}':
+			braces--
+			if braces < 0 {
+				return nil, fmt.Errorf("unbalanced braces in format: %s", s)
 			}
-			if strings.HasSuffix(elementType, "[]") {
-				return nil, fmt.Errorf("nested array types are not supported: %s", typeStr)
+		case '(':
+			parens++
+		case ')':
+			parens--
+			if parens < 0 {
+				return nil, fmt.Errorf("unbalanced parentheses in format: %s", s)
 			}
-
-			properties[key] = map[string]interface{}{
-				"type": "array",
-				"items": map[string]interface{}{
-					"type": elementType,
-				},
+		case sep:
+			if braces == 0 && parens == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
 			}
-		} else {
-			properties[key] = map[string]interface{}{"type": typeStr}
 		}
-
 	}
+	if braces != 0 {
+		return nil, fmt.Errorf("unbalanced braces in format: %s", s)
+	}
+	if parens != 0 {
+		return nil, fmt.Errorf("unbalanced parentheses in format: %s", s)
+	}
+	return append(parts, s[start:]), nil
+}
 
-	return properties, nil
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -205,6 +206,258 @@ func TestParseFormat(t *testing.T) {
 			format:  "   ",
 			wantErr: true,
 		},
+		{
+			name:   "nested object",
+			format: "user:{name:string,age:integer},tags[]",
+			wantProperties: map[string]interface{}{
+				"user": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"name": map[string]interface{}{"type": "string"},
+						"age":  map[string]interface{}{"type": "integer"},
+					},
+					"required": []string{"age", "name"},
+				},
+				"tags": map[string]interface{}{
+					"type": "array",
+					"items": map[string]interface{}{
+						"type": "string",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:   "array of objects",
+			format: "items:{sku:string,qty:integer}[]",
+			wantProperties: map[string]interface{}{
+				"items": map[string]interface{}{
+					"type": "array",
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"sku": map[string]interface{}{"type": "string"},
+							"qty": map[string]interface{}{"type": "integer"},
+						},
+						"required": []string{"qty", "sku"},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:   "key[] shorthand with object element",
+			format: "items[]:{ sku , tags[] }",
+			wantProperties: map[string]interface{}{
+				"items": map[string]interface{}{
+					"type": "array",
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"sku": map[string]interface{}{"type": "string"},
+							"tags": map[string]interface{}{
+								"type":  "array",
+								"items": map[string]interface{}{"type": "string"},
+							},
+						},
+						"required": []string{"sku", "tags"},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:   "deeply nested objects",
+			format: "a:{b:{c:boolean}}",
+			wantProperties: map[string]interface{}{
+				"a": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"b": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"c": map[string]interface{}{"type": "boolean"},
+							},
+							"required": []string{"c"},
+						},
+					},
+					"required": []string{"b"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "empty object is invalid",
+			format:  "user:{}",
+			wantErr: true,
+		},
+		{
+			name:    "unbalanced braces",
+			format:  "user:{name:string",
+			wantErr: true,
+		},
+		{
+			name:    "stray closing brace",
+			format:  "user:name}",
+			wantErr: true,
+		},
+		{
+			name:    "trailing comma inside object is invalid",
+			format:  "user:{name:string,}",
+			wantErr: true,
+		},
+		{
+			name:   "enum type",
+			format: "sentiment:enum(positive|neutral|negative)",
+			wantProperties: map[string]interface{}{
+				"sentiment": map[string]interface{}{
+					"type": "string",
+					"enum": []string{"positive", "neutral", "negative"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:   "enum array and enum inside object",
+			format: "labels:enum( a | b )[],meta:{kind:enum(x|y)}",
+			wantProperties: map[string]interface{}{
+				"labels": map[string]interface{}{
+					"type": "array",
+					"items": map[string]interface{}{
+						"type": "string",
+						"enum": []string{"a", "b"},
+					},
+				},
+				"meta": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"kind": map[string]interface{}{
+							"type": "string",
+							"enum": []string{"x", "y"},
+						},
+					},
+					"required": []string{"kind"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "empty enum",
+			format:  "sentiment:enum()",
+			wantErr: true,
+		},
+		{
+			name:    "empty enum value",
+			format:  "sentiment:enum(a||b)",
+			wantErr: true,
+		},
+		{
+			name:    "duplicate enum value",
+			format:  "sentiment:enum(a|a)",
+			wantErr: true,
+		},
+		{
+			name:    "unterminated enum",
+			format:  "sentiment:enum(a|b",
+			wantErr: true,
+		},
+		{
+			name:    "comma-separated enum",
+			format:  "sentiment:enum(x,y)",
+			wantErr: true,
+		},
+		{
+			name:    "comma inside enum value",
+			format:  "sentiment:enum(a|x,y)",
+			wantErr: true,
+		},
+		{
+			name:   "nullable scalar, array and object",
+			format: "nick:string|null,tags:string[]|null,user:{name}|null",
+			wantProperties: map[string]interface{}{
+				"nick": map[string]interface{}{"type": "string", "nullable": true},
+				"tags": map[string]interface{}{
+					"type":     "array",
+					"items":    map[string]interface{}{"type": "string"},
+					"nullable": true,
+				},
+				"user": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"name": map[string]interface{}{"type": "string"},
+					},
+					"required": []string{"name"},
+					"nullable": true,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:   "nullable enum and array of nullable elements",
+			format: "mood:enum(a|b)|null,scores[]:number|null",
+			wantProperties: map[string]interface{}{
+				"mood": map[string]interface{}{
+					"type":     "string",
+					"enum":     []string{"a", "b"},
+					"nullable": true,
+				},
+				"scores": map[string]interface{}{
+					"type":  "array",
+					"items": map[string]interface{}{"type": "number", "nullable": true},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:   "optional keys inside object",
+			format: "user:{name,nick?}",
+			wantProperties: map[string]interface{}{
+				"user": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"name": map[string]interface{}{"type": "string"},
+						"nick": map[string]interface{}{"type": "string"},
+					},
+					"required": []string{"name"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "union other than null",
+			format:  "id:string|integer",
+			wantErr: true,
+		},
+		{
+			name:    "null alone is not a type",
+			format:  "x:null",
+			wantErr: true,
+		},
+		{
+			name:    "array suffix after null",
+			format:  "x:string|null[]",
+			wantErr: true,
+		},
+		{
+			name:    "question mark in type",
+			format:  "x:string?",
+			wantErr: true,
+		},
+		{
+			name:    "unknown scalar type",
+			format:  "sources:array<string>",
+			wantErr: true,
+		},
+		{
+			name:    "misspelled scalar type",
+			format:  "age:int",
+			wantErr: true,
+		},
+		{
+			name:    "nested array of objects not supported",
+			format:  "grid:{x:integer}[][]",
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -226,3 +479,39 @@ func TestParseFormat(t *testing.T) {
 		})
 	}
 }
+
+func TestParseFormatSchema_Required(t *testing.T) {
+	tests := []struct {
+		name         string
+		format       string
+		wantRequired []string
+	}{
+		{name: "empty format", format: "", wantRequired: []string{}},
+		{name: "all required", format: "b,a:integer", wantRequired: []string{"a", "b"}},
+		{name: "optional key", format: "name,nick?:string", wantRequired: []string{"name"}},
+		{name: "optional array shorthand", format: "name,tags[]?,labels?[]", wantRequired: []string{"name"}},
+		{name: "nullable is still required", format: "note:string|null", wantRequired: []string{"note"}},
+		{name: "duplicate key last one wins", format: "a?,a", wantRequired: []string{"a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := ParseFormatSchema(tt.format)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if schema["type"] != "object" {
+				t.Fatalf("type mismatch: %v", schema["type"])
+			}
+			if !reflect.DeepEqual(schema["required"], tt.wantRequired) {
+				t.Fatalf("required = %v, want %v", schema["required"], tt.wantRequired)
+			}
+		})
+	}
+}
+
+func TestParseFormat_EnumCommaHint(t *testing.T) {
+	_, err := ParseFormat("sentiment:enum(x,y)")
+	if err == nil || !strings.Contains(err.Error(), "separate enum values with |") {
+		t.Fatalf("expected a hint to use |, got %v", err)
+	}
+}
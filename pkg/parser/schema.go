@@ -0,0 +1,60 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// LoadSchema loads a standard JSON Schema for the root output object. ref may
+// be a file path, a file path prefixed with "@", or an inline JSON object.
+// The root schema must describe an object with properties.
+func LoadSchema(ref string) (map[string]interface{}, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return nil, fmt.Errorf("empty schema reference")
+	}
+
+	var data []byte
+	if strings.HasPrefix(ref, "{") {
+		data = []byte(ref)
+	} else {
+		path := strings.TrimPrefix(ref, "@")
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read schema: %w", err)
+		}
+		data = b
+	}
+
+	var schema map[string]interface{}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("invalid JSON Schema: %w", err)
+	}
+	if t, _ := schema["type"].(string); t != "object" {
+		return nil, fmt.Errorf("root schema must have \"type\": \"object\"")
+	}
+	if _, ok := schema["properties"].(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("root schema must define \"properties\"")
+	}
+	return schema, nil
+}
+
+// RequiredKeys returns the "required" keys of an object schema. Unlike the
+// shorthand, a schema without "required" has no required keys, so the result
+// is never nil.
+func RequiredKeys(schema map[string]interface{}) []string {
+	out := []string{}
+	if rv, ok := schema["required"].([]interface{}); ok {
+		for _, v := range rv {
+			if s, ok := v.(string); ok {
+				out = append(out, s)
+			}
+		}
+	}
+	if rv, ok := schema["required"].([]string); ok {
+		out = append(out, rv...)
+	}
+	return out
+}
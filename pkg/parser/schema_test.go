@@ -0,0 +1,66 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadSchema(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "schema.json")
+	body := `{"type":"object","properties":{"name":{"type":"string","description":"full name"}},"required":["name"]}`
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatalf("write schema: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		ref     string
+		wantErr bool
+	}{
+		{name: "plain path", ref: path},
+		{name: "at-prefixed path", ref: "@" + path},
+		{name: "inline json", ref: body},
+		{name: "missing file", ref: filepath.Join(dir, "missing.json"), wantErr: true},
+		{name: "empty ref", ref: "  ", wantErr: true},
+		{name: "invalid json", ref: "{not json", wantErr: true},
+		{name: "root not object", ref: `{"type":"string"}`, wantErr: true},
+		{name: "root without properties", ref: `{"type":"object"}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := LoadSchema(tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err=%v, wantErr=%v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			props, _ := schema["properties"].(map[string]interface{})
+			if _, ok := props["name"]; !ok {
+				t.Fatalf("properties missing name: %v", schema)
+			}
+		})
+	}
+}
+
+func TestRequiredKeys(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema map[string]interface{}
+		want   []string
+	}{
+		{name: "absent means none", schema: map[string]interface{}{}, want: []string{}},
+		{name: "decoded json list", schema: map[string]interface{}{"required": []interface{}{"a", "b"}}, want: []string{"a", "b"}},
+		{name: "string list", schema: map[string]interface{}{"required": []string{"a"}}, want: []string{"a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RequiredKeys(tt.schema); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package parser
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// StructSchema derives a root object schema of the same form as
+// ParseFormatSchema from a struct type, given as a struct value or a pointer
+// to one (a nil pointer such as (*T)(nil) will do).
+//
+// Keys come from json tags, and fields tagged "-" or unexported are skipped.
+// Strings, booleans, integers and floats map to their JSON types; nested
+// structs become objects; slices and arrays become arrays; time.Time is a
+// date-time string. Pointer fields are optional and nullable, every other
+// field is required. Embedded structs without a json name are inlined as
+// encoding/json does.
+//
+// A jsonschema tag adds metadata:
+//
+//	Mood string `json:"mood" jsonschema:"description=Overall tone, in one word,enum=happy|sad"`
+//
+// description may contain commas; enum values are separated by "|" and
+// apply to string fields or the items of string slices.
+func StructSchema(v interface{}) (map[string]interface{}, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("struct schema needs a struct type, got %T", v)
+	}
+	return structObject(t, nil)
+}
+
+// structObject builds the object schema of struct type t. seen holds the
+// struct types being expanded, to reject recursive types.
+func structObject(t reflect.Type, seen []reflect.Type) (map[string]interface{}, error) {
+	for _, s := range seen {
+		if s == t {
+			return nil, fmt.Errorf("recursive type %s is not supported", t)
+		}
+	}
+	seen = append(seen, t)
+
+	properties := make(map[string]interface{})
+	optional := make(map[string]bool)
+	if err := addStructFields(t, seen, properties, optional, false); err != nil {
+		return nil, err
+	}
+	if len(properties) == 0 {
+		return nil, fmt.Errorf("struct %s has no exported fields", t)
+	}
+	required := make([]string, 0, len(properties))
+	for _, k := range sortedKeys(properties) {
+		if !optional[k] {
+			required = append(required, k)
+		}
+	}
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}, nil
+}
+
+// addStructFields adds the fields of t to properties. Direct fields are added
+// before the fields of embedded structs so that, as in encoding/json, an
+// outer field hides an embedded one with the same key. inlinedPtr marks
+// fields reached through an embedded pointer, which are optional.
+func addStructFields(t reflect.Type, seen []reflect.Type, properties map[string]interface{}, optional map[string]bool, inlinedPtr bool) error {
+	var embedded []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded = append(embedded, f)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if _, ok := properties[name]; ok {
+			continue
+		}
+		schema, isPtr, err := fieldSchema(f, seen)
+		if err != nil {
+			return err
+		}
+		properties[name] = schema
+		if isPtr || inlinedPtr {
+			optional[name] = true
+		}
+	}
+	for _, f := range embedded {
+		ft, isPtr := f.Type, false
+		if ft.Kind() == reflect.Ptr {
+			ft, isPtr = ft.Elem(), true
+		}
+		if err := addStructFields(ft, seen, properties, optional, inlinedPtr || isPtr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldSchema returns the schema of struct field f and whether it is a
+// pointer.
+func fieldSchema(f reflect.StructField, seen []reflect.Type) (map[string]interface{}, bool, error) {
+	ft, isPtr := f.Type, false
+	if ft.Kind() == reflect.Ptr {
+		ft, isPtr = ft.Elem(), true
+	}
+	schema, err := typeSchema(ft, seen)
+	if err != nil {
+		return nil, false, fmt.Errorf("field %s: %w", f.Name, err)
+	}
+	if err := applySchemaTag(schema, f.Tag.Get("jsonschema")); err != nil {
+		return nil, false, fmt.Errorf("field %s: %w", f.Name, err)
+	}
+	if isPtr {
+		schema["nullable"] = true
+	}
+	return schema, isPtr, nil
+}
+
+// typeSchema converts a Go type into its JSON Schema fragment.
+func typeSchema(t reflect.Type, seen []reflect.Type) (map[string]interface{}, error) {
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}, nil
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}, nil
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}, nil
+	case reflect.Struct:
+		return structObject(t, seen)
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			// encoding/json writes byte slices as base64 strings.
+			return map[string]interface{}{"type": "string"}, nil
+		}
+		et := t.Elem()
+		if et.Kind() == reflect.Ptr {
+			et = et.Elem()
+		}
+		items, err := typeSchema(et, seen)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"type":  "array",
+			"items": items,
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+// applySchemaTag adds the description and enum of a jsonschema tag to
+// schema. A comma that does not start a known key belongs to the previous
+// value, so descriptions may contain commas.
+func applySchemaTag(schema map[string]interface{}, tag string) error {
+	if tag == "" {
+		return nil
+	}
+	values := make(map[string]string)
+	var last string
+	for _, part := range strings.Split(tag, ",") {
+		key, value, ok := strings.Cut(part, "=")
+		key = strings.TrimSpace(key)
+		if !ok || (key != "description" && key != "enum") {
+			if last == "" {
+				return fmt.Errorf("invalid jsonschema tag %q", tag)
+			}
+			values[last] += "," + part
+			continue
+		}
+		if _, dup := values[key]; dup {
+			return fmt.Errorf("duplicate %s in jsonschema tag %q", key, tag)
+		}
+		values[key] = value
+		last = key
+	}
+
+	if d := strings.TrimSpace(values["description"]); d != "" {
+		schema["description"] = d
+	}
+	raw, ok := values["enum"]
+	if !ok {
+		return nil
+	}
+	target := schema
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		target = items
+	}
+	if target["type"] != "string" || target["format"] != nil {
+		return fmt.Errorf("enum applies only to strings, got %v", target["type"])
+	}
+	enum, err := parseEnum("enum(" + raw + ")")
+	if err != nil {
+		return err
+	}
+	target["enum"] = enum["enum"]
+	return nil
+}
@@ -0,0 +1,113 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type structSchemaLine struct {
+	SKU string `json:"sku"`
+	Qty int    `json:"qty"`
+}
+
+type structSchemaMeta struct {
+	Source string `json:"source"`
+	Note   string `json:"mood"` // hidden by the outer field
+}
+
+type structSchemaOrder struct {
+	structSchemaMeta
+	ID       string             `json:"id" jsonschema:"description=Order number, as printed"`
+	Mood     string             `json:"mood" jsonschema:"enum=happy|sad"`
+	Tags     []string           `json:"tags" jsonschema:"enum=a|b"`
+	Total    float64            `json:"total"`
+	Paid     bool               `json:"paid"`
+	Lines    []structSchemaLine `json:"lines"`
+	Shipping *structSchemaLine  `json:"shipping,omitempty"`
+	Due      *time.Time         `json:"due"`
+	Raw      []byte             `json:"raw"`
+	Count    uint8
+	Secret   string `json:"-"`
+	internal string
+}
+
+func TestStructSchema(t *testing.T) {
+	line := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"sku": map[string]interface{}{"type": "string"},
+			"qty": map[string]interface{}{"type": "integer"},
+		},
+		"required": []string{"qty", "sku"},
+	}
+	shipping := map[string]interface{}{"nullable": true}
+	for k, v := range line {
+		shipping[k] = v
+	}
+	want := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"source":   map[string]interface{}{"type": "string"},
+			"id":       map[string]interface{}{"type": "string", "description": "Order number, as printed"},
+			"mood":     map[string]interface{}{"type": "string", "enum": []string{"happy", "sad"}},
+			"tags":     map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string", "enum": []string{"a", "b"}}},
+			"total":    map[string]interface{}{"type": "number"},
+			"paid":     map[string]interface{}{"type": "boolean"},
+			"lines":    map[string]interface{}{"type": "array", "items": line},
+			"shipping": shipping,
+			"due":      map[string]interface{}{"type": "string", "format": "date-time", "nullable": true},
+			"raw":      map[string]interface{}{"type": "string"},
+			"Count":    map[string]interface{}{"type": "integer"},
+		},
+		"required": []string{"Count", "id", "lines", "mood", "paid", "raw", "source", "tags", "total"},
+	}
+
+	for _, v := range []interface{}{structSchemaOrder{}, &structSchemaOrder{}, (*structSchemaOrder)(nil)} {
+		got, err := StructSchema(v)
+		if err != nil {
+			t.Fatalf("StructSchema(%T): %v", v, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("schema mismatch:\n got=%#v\nwant=%#v", got, want)
+		}
+	}
+}
+
+type structSchemaNode struct {
+	Name     string              `json:"name"`
+	Children []*structSchemaNode `json:"children"`
+}
+
+func TestStructSchema_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+	}{
+		{name: "not a struct", v: map[string]string{}},
+		{name: "nil", v: nil},
+		{name: "no exported fields", v: struct{ a int }{}},
+		{name: "map field", v: struct{ M map[string]int }{}},
+		{name: "interface field", v: struct{ V interface{} }{}},
+		{name: "recursive type", v: structSchemaNode{}},
+		{name: "enum on integer", v: struct {
+			N int `jsonschema:"enum=1|2"`
+		}{}},
+		{name: "unknown tag key", v: struct {
+			S string `jsonschema:"title=x"`
+		}{}},
+		{name: "duplicate tag key", v: struct {
+			S string `jsonschema:"enum=a,enum=b"`
+		}{}},
+		{name: "empty enum value", v: struct {
+			S string `jsonschema:"enum=a||b"`
+		}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := StructSchema(tt.v); err == nil {
+				t.Fatalf("expected an error")
+			}
+		})
+	}
+}
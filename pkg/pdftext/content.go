@@ -0,0 +1,294 @@
+package pdftext
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// token is an operand in a content stream.
+type token struct {
+	kind  byte // 'n' number, 's' string, '/' name, '[' array, '?' other
+	num   float64
+	str   []byte
+	items []token
+}
+
+// textWriter accumulates shown text, inserting line breaks and spaces
+// without doubling them.
+type textWriter struct {
+	b strings.Builder
+}
+
+func (w *textWriter) text(s string) { w.b.WriteString(s) }
+
+func (w *textWriter) sep(c byte) {
+	s := w.b.String()
+	if s == "" || strings.HasSuffix(s, "\n") || (c == ' ' && strings.HasSuffix(s, " ")) {
+		return
+	}
+	w.b.WriteByte(c)
+}
+
+// interpret runs the text operators of a content stream and returns the
+// shown text. fonts maps font resource names to their ToUnicode maps (nil
+// when the font has none).
+func interpret(content []byte, fonts map[string]*cmap) string {
+	var (
+		w       textWriter
+		stack   []token
+		arrays  [][]token
+		font    *cmap
+		lastY   float64
+		haveY   bool
+		operand = func(i int) token {
+			if i < len(stack) {
+				return stack[i]
+			}
+			return token{}
+		}
+	)
+	show := func(s []byte) {
+		if font != nil {
+			w.text(font.decode(s))
+		} else {
+			w.text(decodeSimple(s))
+		}
+	}
+	push := func(t token) {
+		if n := len(arrays); n > 0 {
+			arrays[n-1] = append(arrays[n-1], t)
+			return
+		}
+		stack = append(stack, t)
+	}
+
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case isSpace(c):
+			i++
+		case c == '%':
+			for i < len(content) && content[i] != '\n' && content[i] != '\r' {
+				i++
+			}
+		case c == '(':
+			s, n := literalString(content[i:])
+			push(token{kind: 's', str: s})
+			i += n
+		case c == '<' && i+1 < len(content) && content[i+1] == '<', c == '>' && i+1 < len(content) && content[i+1] == '>':
+			// Dictionary operands (marked content properties) are ignored.
+			i += 2
+		case c == '<':
+			end := bytes.IndexByte(content[i:], '>')
+			if end < 0 {
+				end = len(content) - i - 1
+			}
+			push(token{kind: 's', str: hexString(content[i+1 : i+end])})
+			i += end + 1
+		case c == '[':
+			arrays = append(arrays, nil)
+			i++
+		case c == ']':
+			if n := len(arrays); n > 0 {
+				items := arrays[n-1]
+				arrays = arrays[:n-1]
+				push(token{kind: '[', items: items})
+			}
+			i++
+		case c == '/':
+			j := i + 1
+			for j < len(content) && !isSpace(content[j]) && !isDelim(content[j]) {
+				j++
+			}
+			push(token{kind: '/', str: content[i+1 : j]})
+			i = j
+		case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+			j := i + 1
+			for j < len(content) && (content[j] == '.' || (content[j] >= '0' && content[j] <= '9')) {
+				j++
+			}
+			f, _ := strconv.ParseFloat(string(content[i:j]), 64)
+			push(token{kind: 'n', num: f})
+			i = j
+		default:
+			j := i + 1
+			for j < len(content) && !isSpace(content[j]) && !isDelim(content[j]) {
+				j++
+			}
+			op := string(content[i:j])
+			i = j
+			if len(arrays) > 0 {
+				// Stray keyword inside an array (e.g., true/false/null).
+				push(token{kind: '?'})
+				continue
+			}
+			switch op {
+			case "Tf":
+				if len(stack) >= 2 && stack[len(stack)-2].kind == '/' {
+					font = fonts[string(stack[len(stack)-2].str)]
+				}
+			case "Tj":
+				if t := operand(len(stack) - 1); t.kind == 's' {
+					show(t.str)
+				}
+			case "'", "\"":
+				w.sep('\n')
+				if t := operand(len(stack) - 1); t.kind == 's' {
+					show(t.str)
+				}
+			case "TJ":
+				if t := operand(len(stack) - 1); t.kind == '[' {
+					for _, it := range t.items {
+						switch {
+						case it.kind == 's':
+							show(it.str)
+						case it.kind == 'n' && it.num < -200:
+							// A large negative adjustment is a word gap.
+							w.sep(' ')
+						}
+					}
+				}
+			case "Td", "TD":
+				if len(stack) >= 2 {
+					if dy := stack[len(stack)-1].num; dy != 0 {
+						w.sep('\n')
+						lastY += dy
+					} else if stack[len(stack)-2].num > 0 {
+						w.sep(' ')
+					}
+				}
+			case "T*":
+				w.sep('\n')
+			case "Tm":
+				if len(stack) >= 6 {
+					y := stack[len(stack)-1].num
+					if haveY && y != lastY {
+						w.sep('\n')
+					} else if haveY {
+						w.sep(' ')
+					}
+					lastY, haveY = y, true
+				}
+			case "ET":
+				w.sep(' ')
+			case "BI":
+				// Skip inline image data up to the EI operator.
+				if end := bytes.Index(content[i:], []byte("EI")); end >= 0 {
+					i += end + 2
+				} else {
+					i = len(content)
+				}
+			}
+			stack = stack[:0]
+		}
+	}
+	return tidy(w.b.String())
+}
+
+// literalString parses a (...) string with escapes and balanced
+// parentheses, returning its bytes and the number of bytes consumed.
+func literalString(s []byte) ([]byte, int) {
+	var out []byte
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '(':
+			depth++
+			if depth == 1 {
+				continue
+			}
+		case ')':
+			depth--
+			if depth == 0 {
+				return out, i + 1
+			}
+		case '\\':
+			i++
+			if i >= len(s) {
+				return out, i
+			}
+			switch e := s[i]; e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case '\r':
+				if i+1 < len(s) && s[i+1] == '\n' {
+					i++
+				}
+			case '\n':
+				// Line continuation.
+			default:
+				if e >= '0' && e <= '7' {
+					v, n := 0, 0
+					for n < 3 && i < len(s) && s[i] >= '0' && s[i] <= '7' {
+						v = v*8 + int(s[i]-'0')
+						i++
+						n++
+					}
+					i--
+					out = append(out, byte(v))
+				} else {
+					out = append(out, e)
+				}
+			}
+			continue
+		}
+		out = append(out, c)
+	}
+	return out, len(s)
+}
+
+func hexString(h []byte) []byte {
+	var digits []byte
+	for _, c := range h {
+		if (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, 0, len(digits)/2)
+	for i := 0; i < len(digits); i += 2 {
+		v, _ := strconv.ParseUint(string(digits[i:i+2]), 16, 8)
+		out = append(out, byte(v))
+	}
+	return out
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+func isDelim(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+// tidy trims trailing spaces on each line and collapses runs of blank lines.
+func tidy(s string) string {
+	lines := strings.Split(s, "\n")
+	out := make([]string, 0, len(lines))
+	blank := 0
+	for _, l := range lines {
+		l = strings.TrimRight(l, " \t")
+		if l == "" {
+			blank++
+			if blank > 1 {
+				continue
+			}
+		} else {
+			blank = 0
+		}
+		out = append(out, l)
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
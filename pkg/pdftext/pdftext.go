@@ -0,0 +1,441 @@
+// Package pdftext extracts plain text from PDF files for providers that
+// cannot read PDFs natively. It handles the common cases: uncompressed and
+// Flate-compressed content streams, object streams, and fonts with ToUnicode
+// maps. Scanned (image-only) documents yield no text.
+package pdftext
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// ErrNoText is returned when a PDF parses but contains no extractable text,
+// e.g., a scanned document.
+var ErrNoText = errors.New("no extractable text (scanned or image-only PDF?)")
+
+// object is an indirect PDF object: its dictionary (or value) source and,
+// for stream objects, the decoded stream data.
+type object struct {
+	dict   []byte
+	stream []byte
+}
+
+type document struct {
+	objects map[int]*object
+	cmaps   map[int]*cmap
+}
+
+var (
+	objHeaderRe = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+	refRe       = regexp.MustCompile(`^\s*(\d+)\s+(\d+)\s+R`)
+	refsRe      = regexp.MustCompile(`(\d+)\s+\d+\s+R`)
+	fontRefRe   = regexp.MustCompile(`/([^\s/<>\[\]()]+)\s+(\d+)\s+\d+\s+R`)
+)
+
+// Extract returns the text of every page, in page order, separated by blank
+// lines.
+func Extract(data []byte) (string, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("%PDF-")) {
+		return "", fmt.Errorf("not a PDF file")
+	}
+	if bytes.Contains(data, []byte("/Encrypt")) {
+		return "", fmt.Errorf("encrypted PDFs are not supported")
+	}
+	doc := &document{objects: map[int]*object{}, cmaps: map[int]*cmap{}}
+	doc.parseObjects(data)
+	if len(doc.objects) == 0 {
+		return "", fmt.Errorf("no PDF objects found")
+	}
+
+	var pages []string
+	for _, page := range doc.pages(data) {
+		if text := strings.TrimSpace(doc.pageText(page)); text != "" {
+			pages = append(pages, text)
+		}
+	}
+	if len(pages) == 0 {
+		return "", ErrNoText
+	}
+	return strings.Join(pages, "\n\n"), nil
+}
+
+// parseObjects indexes every "N G obj ... endobj" in the file, then expands
+// object streams. Later definitions (incremental updates) win.
+func (d *document) parseObjects(data []byte) {
+	for _, m := range objHeaderRe.FindAllSubmatchIndex(data, -1) {
+		num, _ := strconv.Atoi(string(data[m[2]:m[3]]))
+		body := data[m[1]:]
+		if end := bytes.Index(body, []byte("endobj")); end >= 0 {
+			body = body[:end]
+		}
+		obj := &object{dict: body}
+		if i := bytes.Index(body, []byte("stream")); i >= 0 && !bytes.Contains(body[:i], []byte("endstream")) {
+			obj.dict = body[:i]
+			raw := body[i+len("stream"):]
+			raw = bytes.TrimPrefix(raw, []byte("\r"))
+			raw = bytes.TrimPrefix(raw, []byte("\n"))
+			if end := bytes.LastIndex(raw, []byte("endstream")); end >= 0 {
+				raw = raw[:end]
+			}
+			obj.stream = decodeStream(obj.dict, raw)
+		}
+		d.objects[num] = obj
+	}
+
+	for _, obj := range d.objects {
+		if obj.stream == nil || !bytes.Contains(obj.dict, []byte("/ObjStm")) {
+			continue
+		}
+		n, _ := dictInt(obj.dict, "N")
+		first, _ := dictInt(obj.dict, "First")
+		if first <= 0 || first > len(obj.stream) {
+			continue
+		}
+		header := strings.Fields(string(obj.stream[:first]))
+		for i := 0; i+1 < len(header) && i/2 < n; i += 2 {
+			num, err1 := strconv.Atoi(header[i])
+			off, err2 := strconv.Atoi(header[i+1])
+			if err1 != nil || err2 != nil || first+off > len(obj.stream) {
+				continue
+			}
+			end := len(obj.stream)
+			if i+3 < len(header) {
+				if next, err := strconv.Atoi(header[i+3]); err == nil && first+next <= end && next >= off {
+					end = first + next
+				}
+			}
+			if _, exists := d.objects[num]; !exists {
+				d.objects[num] = &object{dict: obj.stream[first+off : end]}
+			}
+		}
+	}
+}
+
+// decodeStream applies the stream's filter. Only FlateDecode (or no filter)
+// yields usable data; other filters (images, etc.) are dropped.
+func decodeStream(dict, raw []byte) []byte {
+	filter := dictValue(dict, "Filter")
+	switch {
+	case len(filter) == 0:
+		return raw
+	case bytes.Contains(filter, []byte("/FlateDecode")) && !bytes.Contains(filter, []byte("/DCTDecode")):
+		r, err := zlib.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil
+		}
+		out, err := io.ReadAll(r)
+		if err != nil && len(out) == 0 {
+			return nil
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+// pages returns the page objects in document order, each paired with its
+// (possibly inherited) resources dictionary.
+func (d *document) pages(data []byte) []pageRef {
+	root := -1
+	if i := bytes.LastIndex(data, []byte("/Root")); i >= 0 {
+		if m := refRe.FindSubmatch(data[i+len("/Root"):]); m != nil {
+			root, _ = strconv.Atoi(string(m[1]))
+		}
+	}
+	var out []pageRef
+	if cat, ok := d.objects[root]; ok {
+		if n, ok := dictRef(cat.dict, "Pages"); ok {
+			d.walkPages(n, nil, &out, map[int]bool{})
+		}
+	}
+	if len(out) > 0 {
+		return out
+	}
+	// Fall back to every page object in object-number order.
+	for num := 0; num <= maxKey(d.objects); num++ {
+		if obj, ok := d.objects[num]; ok && isType(obj.dict, "Page") {
+			out = append(out, pageRef{obj: obj, resources: d.resolveDict(dictValue(obj.dict, "Resources"))})
+		}
+	}
+	return out
+}
+
+type pageRef struct {
+	obj       *object
+	resources []byte
+}
+
+func (d *document) walkPages(num int, inherited []byte, out *[]pageRef, seen map[int]bool) {
+	obj, ok := d.objects[num]
+	if !ok || seen[num] {
+		return
+	}
+	seen[num] = true
+	resources := inherited
+	if r := dictValue(obj.dict, "Resources"); len(r) > 0 {
+		resources = d.resolveDict(r)
+	}
+	if isType(obj.dict, "Page") {
+		*out = append(*out, pageRef{obj: obj, resources: resources})
+		return
+	}
+	for _, kid := range refList(dictValue(obj.dict, "Kids")) {
+		d.walkPages(kid, resources, out, seen)
+	}
+}
+
+// pageText decodes and interprets the page's content streams.
+func (d *document) pageText(page pageRef) string {
+	var content []byte
+	for _, num := range refList(dictValue(page.obj.dict, "Contents")) {
+		if obj, ok := d.objects[num]; ok {
+			content = append(content, obj.stream...)
+			content = append(content, '\n')
+		}
+	}
+	fonts := map[string]*cmap{}
+	fontDict := d.resolveDict(dictValue(page.resources, "Font"))
+	for _, m := range fontRefRe.FindAllSubmatch(fontDict, -1) {
+		num, _ := strconv.Atoi(string(m[2]))
+		fonts[string(m[1])] = d.fontCMap(num)
+	}
+	return interpret(content, fonts)
+}
+
+func (d *document) fontCMap(fontNum int) *cmap {
+	font, ok := d.objects[fontNum]
+	if !ok {
+		return nil
+	}
+	num, ok := dictRef(font.dict, "ToUnicode")
+	if !ok {
+		return nil
+	}
+	if cm, ok := d.cmaps[num]; ok {
+		return cm
+	}
+	var cm *cmap
+	if obj, ok := d.objects[num]; ok && obj.stream != nil {
+		cm = parseCMap(obj.stream)
+	}
+	d.cmaps[num] = cm
+	return cm
+}
+
+// resolveDict follows an indirect reference to a dictionary, if v is one.
+func (d *document) resolveDict(v []byte) []byte {
+	if m := refRe.FindSubmatch(v); m != nil {
+		num, _ := strconv.Atoi(string(m[1]))
+		if obj, ok := d.objects[num]; ok {
+			return obj.dict
+		}
+		return nil
+	}
+	return v
+}
+
+// dictValue returns the raw value of /key in a dictionary source: a
+// balanced <<...>> or [...], a reference, or a single token.
+func dictValue(dict []byte, key string) []byte {
+	name := []byte("/" + key)
+	var rest []byte
+	for i := 0; ; {
+		j := bytes.Index(dict[i:], name)
+		if j < 0 {
+			return nil
+		}
+		end := i + j + len(name)
+		// Skip longer names sharing the prefix (e.g., /Type vs /Type0).
+		if end == len(dict) || bytes.IndexByte([]byte(" \t\r\n/<>[]()"), dict[end]) >= 0 {
+			rest = bytes.TrimLeft(dict[end:], " \t\r\n")
+			break
+		}
+		i = end
+	}
+	switch {
+	case bytes.HasPrefix(rest, []byte("<<")):
+		return balanced(rest, "<<", ">>")
+	case bytes.HasPrefix(rest, []byte("[")):
+		return balanced(rest, "[", "]")
+	}
+	if m := refRe.FindSubmatch(rest); m != nil {
+		return rest[:len(m[0])]
+	}
+	end := bytes.IndexAny(rest[1:], " \t\r\n/<>[]")
+	if end < 0 {
+		return rest
+	}
+	return rest[:end+1]
+}
+
+func balanced(s []byte, open, close string) []byte {
+	depth := 0
+	for i := 0; i < len(s); {
+		switch {
+		case bytes.HasPrefix(s[i:], []byte(open)):
+			depth++
+			i += len(open)
+		case bytes.HasPrefix(s[i:], []byte(close)):
+			depth--
+			i += len(close)
+			if depth == 0 {
+				return s[:i]
+			}
+		default:
+			i++
+		}
+	}
+	return s
+}
+
+func dictRef(dict []byte, key string) (int, bool) {
+	m := refRe.FindSubmatch(dictValue(dict, key))
+	if m == nil {
+		return 0, false
+	}
+	n, err := strconv.Atoi(string(m[1]))
+	return n, err == nil
+}
+
+func dictInt(dict []byte, key string) (int, bool) {
+	n, err := strconv.Atoi(string(bytes.TrimSpace(dictValue(dict, key))))
+	return n, err == nil
+}
+
+// refList parses a single reference or an array of references.
+func refList(v []byte) []int {
+	var out []int
+	for _, m := range refsRe.FindAllSubmatch(v, -1) {
+		n, _ := strconv.Atoi(string(m[1]))
+		out = append(out, n)
+	}
+	return out
+}
+
+func isType(dict []byte, typ string) bool {
+	return bytes.Equal(bytes.TrimSpace(dictValue(dict, "Type")), []byte("/"+typ))
+}
+
+func maxKey(m map[int]*object) int {
+	max := 0
+	for k := range m {
+		if k > max {
+			max = k
+		}
+	}
+	return max
+}
+
+// cmap is a parsed ToUnicode map from character codes to text.
+type cmap struct {
+	codeLen int
+	chars   map[int]string
+	ranges  []cmapRange
+}
+
+type cmapRange struct {
+	lo, hi int
+	start  []rune
+}
+
+var (
+	hexTokenRe = regexp.MustCompile(`<([0-9A-Fa-f]*)>`)
+	codespace  = regexp.MustCompile(`(?s)begincodespacerange(.*?)endcodespacerange`)
+	bfcharRe   = regexp.MustCompile(`(?s)beginbfchar(.*?)endbfchar`)
+	bfrangeRe  = regexp.MustCompile(`(?s)beginbfrange(.*?)endbfrange`)
+	rangeRe    = regexp.MustCompile(`<([0-9A-Fa-f]+)>\s*<([0-9A-Fa-f]+)>\s*(<[0-9A-Fa-f]*>|\[[^\]]*\])`)
+)
+
+func parseCMap(data []byte) *cmap {
+	cm := &cmap{codeLen: 1, chars: map[int]string{}}
+	if m := codespace.FindSubmatch(data); m != nil {
+		if h := hexTokenRe.FindSubmatch(m[1]); h != nil {
+			cm.codeLen = max(1, len(h[1])/2)
+		}
+	}
+	for _, block := range bfcharRe.FindAllSubmatch(data, -1) {
+		toks := hexTokenRe.FindAllSubmatch(block[1], -1)
+		for i := 0; i+1 < len(toks); i += 2 {
+			code, _ := strconv.ParseInt(string(toks[i][1]), 16, 32)
+			cm.chars[int(code)] = utf16Hex(toks[i+1][1])
+			if len(toks[i][1]) == 4 && cm.codeLen < 2 {
+				cm.codeLen = 2
+			}
+		}
+	}
+	for _, block := range bfrangeRe.FindAllSubmatch(data, -1) {
+		for _, r := range rangeRe.FindAllSubmatch(block[1], -1) {
+			lo, _ := strconv.ParseInt(string(r[1]), 16, 32)
+			hi, _ := strconv.ParseInt(string(r[2]), 16, 32)
+			if bytes.HasPrefix(r[3], []byte("[")) {
+				for i, h := range hexTokenRe.FindAllSubmatch(r[3], -1) {
+					cm.chars[int(lo)+i] = utf16Hex(h[1])
+				}
+				continue
+			}
+			dst := []rune(utf16Hex(bytes.Trim(r[3], "<>")))
+			cm.ranges = append(cm.ranges, cmapRange{lo: int(lo), hi: int(hi), start: dst})
+		}
+	}
+	return cm
+}
+
+func (cm *cmap) decode(s []byte) string {
+	var b strings.Builder
+	for i := 0; i+cm.codeLen <= len(s); i += cm.codeLen {
+		code := 0
+		for _, c := range s[i : i+cm.codeLen] {
+			code = code<<8 | int(c)
+		}
+		if t, ok := cm.chars[code]; ok {
+			b.WriteString(t)
+			continue
+		}
+		for _, r := range cm.ranges {
+			if code >= r.lo && code <= r.hi && len(r.start) > 0 {
+				rs := append([]rune{}, r.start...)
+				rs[len(rs)-1] += rune(code - r.lo)
+				b.WriteString(string(rs))
+				break
+			}
+		}
+	}
+	return b.String()
+}
+
+// utf16Hex decodes a hex string of UTF-16BE code units.
+func utf16Hex(h []byte) string {
+	var units []uint16
+	for i := 0; i+4 <= len(h); i += 4 {
+		u, _ := strconv.ParseUint(string(h[i:i+4]), 16, 16)
+		units = append(units, uint16(u))
+	}
+	if len(h)%4 == 2 {
+		u, _ := strconv.ParseUint(string(h[len(h)-2:]), 16, 8)
+		units = append(units, uint16(u))
+	}
+	return string(utf16.Decode(units))
+}
+
+// decodeSimple decodes a string shown with a font lacking a ToUnicode map,
+// treating bytes as Latin-1 (close to WinAnsi for text) and dropping
+// control characters.
+func decodeSimple(s []byte) string {
+	if bytes.HasPrefix(s, []byte("\xfe\xff")) {
+		return utf16Hex([]byte(fmt.Sprintf("%X", s[2:])))
+	}
+	var b strings.Builder
+	for _, c := range s {
+		if c >= 0x20 || c == '\t' {
+			b.WriteRune(rune(c))
+		}
+	}
+	return b.String()
+}
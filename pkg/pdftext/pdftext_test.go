@@ -0,0 +1,123 @@
+package pdftext
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// buildPDF assembles a minimal PDF from object bodies (object i+1 is objs[i]).
+// Readers here do not need a valid xref table.
+func buildPDF(objs ...string) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.7\n")
+	for i, o := range objs {
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, o)
+	}
+	b.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return b.Bytes()
+}
+
+func streamObj(dict, data string) string {
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data)
+}
+
+func flate(s string) string {
+	var b bytes.Buffer
+	w := zlib.NewWriter(&b)
+	_, _ = w.Write([]byte(s))
+	_ = w.Close()
+	return b.String()
+}
+
+func TestExtract(t *testing.T) {
+	helvetica := "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>"
+	cmapData := `/CIDInit /ProcSet findresource begin
+begincmap
+1 begincodespacerange <0000> <FFFF> endcodespacerange
+2 beginbfchar
+<0001> <0048>
+<0002> <0069>
+endbfchar
+1 beginbfrange
+<0010> <0012> <0061>
+endbfrange
+endcmap`
+
+	tests := []struct {
+		name    string
+		pdf     []byte
+		want    string
+		wantErr error
+	}{
+		{
+			name: "uncompressed simple font",
+			pdf: buildPDF(
+				"<< /Type /Catalog /Pages 2 0 R >>",
+				"<< /Type /Pages /Kids [3 0 R] /Count 1 /Resources << /Font << /F1 4 0 R >> >> >>",
+				"<< /Type /Page /Parent 2 0 R /Contents 5 0 R >>",
+				helvetica,
+				streamObj("", "BT /F1 12 Tf 72 720 Td (Invoice \\(draft\\)) Tj 0 -14 Td (Total: 42) Tj ET"),
+			),
+			want: "Invoice (draft)\nTotal: 42",
+		},
+		{
+			name: "flate streams, TJ kerning and page order",
+			pdf: buildPDF(
+				"<< /Type /Catalog /Pages 2 0 R >>",
+				"<< /Type /Pages /Kids [4 0 R 3 0 R] /Count 2 >>",
+				"<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 5 0 R >> >> /Contents [6 0 R] >>",
+				"<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 5 0 R >> >> /Contents [7 0 R] >>",
+				helvetica,
+				streamObj("/Filter /FlateDecode", flate("BT /F1 12 Tf 1 0 0 1 72 700 Tm [(Sec)10(ond)-300(page)] TJ ET")),
+				streamObj("/Filter /FlateDecode", flate("BT /F1 12 Tf 1 0 0 1 72 700 Tm (First) Tj T* (page) Tj ET")),
+			),
+			want: "First\npage\n\nSecond page",
+		},
+		{
+			name: "ToUnicode cmap in object stream",
+			pdf: buildPDF(
+				"<< /Type /Catalog /Pages 2 0 R >>",
+				"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+				"<< /Type /Page /Parent 2 0 R /Resources 8 0 R /Contents 5 0 R >>",
+				streamObj("/Type /ObjStm /N 2 /First 9 /Filter /FlateDecode", flate("8 0 9 29 << /Font << /F2 9 0 R >> >>  << /Type /Font /Subtype /Type0 /ToUnicode 6 0 R >>")),
+				streamObj("", "BT /F2 10 Tf 72 700 Td <00010002> Tj 0 -12 Td <001000110012> Tj ET"),
+				streamObj("", cmapData),
+			),
+			want: "Hi\nabc",
+		},
+		{
+			name: "image only",
+			pdf: buildPDF(
+				"<< /Type /Catalog /Pages 2 0 R >>",
+				"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+				"<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>",
+				streamObj("", "q 612 0 0 792 0 0 cm /Im0 Do Q"),
+			),
+			wantErr: ErrNoText,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Extract(tt.pdf)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("Extract() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Extract([]byte("hello")); err == nil {
+		t.Fatalf("expected error for non-PDF input")
+	}
+}
@@ -0,0 +1,187 @@
+// Package pricing estimates what requests cost. Prices are USD per million
+// tokens, keyed by "provider/model"; the built-in table can be extended or
+// overridden from a JSON file. Estimates ignore provider surcharges such as
+// Anthropic cache writes and long-context tiers.
+package pricing
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"llmx/pkg/provider"
+)
+
+// Price is the cost of a model in USD per million tokens.
+type Price struct {
+	Input float64 `json:"input"`
+	// CachedInput applies to input tokens served from the prompt cache
+	// (Input if zero).
+	CachedInput float64 `json:"cached_input,omitempty"`
+	Output      float64 `json:"output"`
+}
+
+// Cost returns the cost of u in USD. Reasoning tokens are billed as output.
+func (p Price) Cost(u provider.Usage) float64 {
+	cached := p.CachedInput
+	if cached == 0 {
+		cached = p.Input
+	}
+	return (float64(u.InputTokens-u.CachedTokens)*p.Input +
+		float64(u.CachedTokens)*cached +
+		float64(u.OutputTokens)*p.Output) / 1e6
+}
+
+// Table maps "provider/model" to a price.
+type Table map[string]Price
+
+// builtin lists list prices at the time of writing. Check your provider's
+// pricing page, and override entries with a pricing file when they change.
+var builtin = Table{
+	"openai/gpt-5":        {Input: 1.25, CachedInput: 0.125, Output: 10},
+	"openai/gpt-5-mini":   {Input: 0.25, CachedInput: 0.025, Output: 2},
+	"openai/gpt-5-nano":   {Input: 0.05, CachedInput: 0.005, Output: 0.40},
+	"openai/gpt-4.1":      {Input: 2, CachedInput: 0.50, Output: 8},
+	"openai/gpt-4.1-mini": {Input: 0.40, CachedInput: 0.10, Output: 1.60},
+	"openai/gpt-4.1-nano": {Input: 0.10, CachedInput: 0.025, Output: 0.40},
+	"openai/gpt-4o":       {Input: 2.50, CachedInput: 1.25, Output: 10},
+	"openai/gpt-4o-mini":  {Input: 0.15, CachedInput: 0.075, Output: 0.60},
+	"openai/o3":           {Input: 2, CachedInput: 0.50, Output: 8},
+	"openai/o4-mini":      {Input: 1.10, CachedInput: 0.275, Output: 4.40},
+
+	"anthropic/claude-opus-4":     {Input: 15, CachedInput: 1.50, Output: 75},
+	"anthropic/claude-sonnet-4":   {Input: 3, CachedInput: 0.30, Output: 15},
+	"anthropic/claude-3-7-sonnet": {Input: 3, CachedInput: 0.30, Output: 15},
+	"anthropic/claude-3-5-sonnet": {Input: 3, CachedInput: 0.30, Output: 15},
+	"anthropic/claude-3-5-haiku":  {Input: 0.80, CachedInput: 0.08, Output: 4},
+	"anthropic/claude-3-haiku":    {Input: 0.25, CachedInput: 0.03, Output: 1.25},
+
+	"gemini/gemini-2.5-pro":        {Input: 1.25, CachedInput: 0.31, Output: 10},
+	"gemini/gemini-2.5-flash":      {Input: 0.30, CachedInput: 0.075, Output: 2.50},
+	"gemini/gemini-2.5-flash-lite": {Input: 0.10, CachedInput: 0.025, Output: 0.40},
+	"gemini/gemini-2.0-flash":      {Input: 0.10, CachedInput: 0.025, Output: 0.40},
+	"gemini/gemini-2.0-flash-lite": {Input: 0.075, Output: 0.30},
+}
+
+// Default returns a copy of the built-in table.
+func Default() Table {
+	t := make(Table, len(builtin))
+	for k, v := range builtin {
+		t[k] = v
+	}
+	return t
+}
+
+// LoadFile reads a pricing file: a JSON object mapping "provider/model" to
+// {"input", "cached_input", "output"} prices in USD per million tokens.
+func LoadFile(path string) (Table, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pricing file: %w", err)
+	}
+	var t Table
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("invalid pricing file: %w", err)
+	}
+	for key, p := range t {
+		if !strings.Contains(key, "/") {
+			return nil, fmt.Errorf("pricing file: key %q must be provider/model", key)
+		}
+		if p.Input < 0 || p.CachedInput < 0 || p.Output < 0 {
+			return nil, fmt.Errorf("pricing file: %s has a negative price", key)
+		}
+	}
+	return t, nil
+}
+
+// Merge adds the entries of o to t, replacing existing ones.
+func (t Table) Merge(o Table) {
+	for k, v := range o {
+		t[k] = v
+	}
+}
+
+// snapshotSuffix matches the suffixes that name a snapshot or alias of a
+// model billed like the model itself: a date (gpt-4o-2024-08-06,
+// claude-3-5-haiku-20241022), a Gemini version number (gemini-2.0-flash-001)
+// or "-latest".
+var snapshotSuffix = regexp.MustCompile(`^-(\d{4}-\d{2}-\d{2}|\d{8}|\d{3}|latest)$`)
+
+// Lookup returns the price of model on provider. Besides an exact match, a
+// model matches an entry it extends with a snapshot suffix (see
+// snapshotSuffix). Any other suffix, as in o3-pro or gpt-4o-mini-audio,
+// names a differently priced model and is unknown.
+func (t Table) Lookup(providerName, model string) (Price, bool) {
+	key := providerName + "/" + model
+	if p, ok := t[key]; ok {
+		return p, true
+	}
+	for k, p := range t {
+		if strings.HasPrefix(key, k) && snapshotSuffix.MatchString(key[len(k):]) {
+			return p, true
+		}
+	}
+	return Price{}, false
+}
+
+// imageTokens is a rough per-image input cost; providers bill between a few
+// hundred and ~1,600 tokens for a typical image.
+const imageTokens = 1600
+
+// EstimateTokens approximates the token count of s at four bytes per token.
+func EstimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+// EstimateInputTokens approximates the input tokens of a request from its
+// text, schema, tools and attachments. It runs before the request is sent, so
+// it is only a guide; provider-reported usage is authoritative.
+func EstimateInputTokens(opts provider.Options) int {
+	var b strings.Builder
+	b.WriteString(opts.Instructions)
+	b.WriteString(opts.Message)
+	images := len(opts.Images)
+	for _, t := range opts.History {
+		b.WriteString(t.Content)
+		for _, f := range t.Files {
+			b.WriteString(fileText(f))
+		}
+		images += len(t.Images)
+		for _, call := range t.ToolCalls {
+			args, _ := json.Marshal(call.Arguments)
+			b.WriteString(call.Name)
+			b.Write(args)
+		}
+	}
+	if opts.Schema != nil {
+		s, _ := json.Marshal(opts.Schema)
+		b.Write(s)
+	} else if len(opts.Properties) > 0 {
+		s, _ := json.Marshal(opts.Properties)
+		b.Write(s)
+	}
+	for _, tool := range opts.Tools {
+		b.WriteString(tool.Name)
+		b.WriteString(tool.Description)
+		s, _ := json.Marshal(tool.Properties)
+		b.Write(s)
+		s, _ = json.Marshal(tool.Schema)
+		b.Write(s)
+	}
+	for _, f := range opts.Files {
+		b.WriteString(fileText(f))
+	}
+	return EstimateTokens(b.String()) + imageTokens*images
+}
+
+// fileText returns the text a document contributes to the prompt. PDFs
+// count by their extracted Text, or at their full size when it is unknown;
+// they are not extracted here, since the estimate runs before every attempt.
+func fileText(f provider.File) string {
+	if f.MIMEType == "application/pdf" && strings.TrimSpace(f.Text) != "" {
+		return f.Text
+	}
+	return string(f.Data)
+}
@@ -0,0 +1,110 @@
+package pricing
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"llmx/pkg/provider"
+)
+
+func TestLookup(t *testing.T) {
+	table := Default()
+	tests := []struct {
+		provider, model string
+		want            string
+	}{
+		{"openai", "gpt-4o-mini", "openai/gpt-4o-mini"},
+		{"openai", "gpt-4o-mini-2024-07-18", "openai/gpt-4o-mini"},
+		{"openai", "gpt-4o-2024-08-06", "openai/gpt-4o"},
+		{"anthropic", "claude-3-5-haiku-latest", "anthropic/claude-3-5-haiku"},
+		{"gemini", "gemini-2.0-flash-001", "gemini/gemini-2.0-flash"},
+		{"anthropic", "claude-3-5-haiku-20241022", "anthropic/claude-3-5-haiku"},
+		{"openai", "gpt-4o2", ""},
+		{"openai", "o3-pro", ""},
+		{"openai", "gpt-5-pro", ""},
+		{"openai", "gpt-4o-mini-audio-preview", ""},
+		{"openai", "gpt-4o-mini-audio-preview-2024-12-17", ""},
+		{"openai-compat", "gpt-4o-mini", ""},
+	}
+	for _, tt := range tests {
+		got, ok := table.Lookup(tt.provider, tt.model)
+		if ok != (tt.want != "") || (ok && got != table[tt.want]) {
+			t.Errorf("Lookup(%s, %s) = %+v, %v; want %s", tt.provider, tt.model, got, ok, tt.want)
+		}
+	}
+}
+
+func TestPriceCost(t *testing.T) {
+	p := Price{Input: 2, CachedInput: 0.5, Output: 8}
+	got := p.Cost(provider.Usage{InputTokens: 1_000_000, CachedTokens: 400_000, OutputTokens: 500_000})
+	if want := 1.2 + 0.2 + 4.0; math.Abs(got-want) > 1e-9 {
+		t.Fatalf("Cost = %v, want %v", got, want)
+	}
+	p.CachedInput = 0
+	if got := p.Cost(provider.Usage{InputTokens: 1_000_000, CachedTokens: 1_000_000}); math.Abs(got-2) > 1e-9 {
+		t.Fatalf("cached tokens should fall back to the input price: %v", got)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.json")
+	if err := os.WriteFile(path, []byte(`{"openai/gpt-4o": {"input": 1, "output": 3}, "openai-compat/llama": {"input": 0.2, "output": 0.2}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	overrides, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile: %v", err)
+	}
+	table := Default()
+	table.Merge(overrides)
+	if p, _ := table.Lookup("openai", "gpt-4o"); p != (Price{Input: 1, Output: 3}) {
+		t.Errorf("override not applied: %+v", p)
+	}
+	if _, ok := table.Lookup("openai-compat", "llama"); !ok {
+		t.Errorf("new entry not added")
+	}
+	if _, ok := builtin.Lookup("openai-compat", "llama"); ok {
+		t.Errorf("Merge must not modify the built-in table")
+	}
+
+	for name, body := range map[string]string{
+		"no provider": `{"gpt-4o": {"input": 1, "output": 1}}`,
+		"negative":    `{"openai/x": {"input": -1, "output": 1}}`,
+		"not json":    `prices`,
+	} {
+		if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadFile(path); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestEstimateInputTokens(t *testing.T) {
+	opts := provider.Options{
+		Instructions: "abcd",
+		Message:      "abcdefgh",
+		History:      []provider.Turn{{Role: provider.RoleUser, Content: "abcd"}},
+		Images:       []provider.Image{{URL: "https://example.com/a.png"}},
+		Files:        []provider.File{{Name: "a.txt", MIMEType: "text/plain", Data: []byte("abcd")}},
+	}
+	if got, want := EstimateInputTokens(opts), 5+imageTokens; got != want {
+		t.Fatalf("EstimateInputTokens = %d, want %d", got, want)
+	}
+
+	// PDFs count by their extracted text, or at full size without it.
+	pdf := provider.File{Name: "a.pdf", MIMEType: "application/pdf", Data: []byte(strings.Repeat("x", 400)), Text: "abcd"}
+	opts = provider.Options{Files: []provider.File{pdf}}
+	if got := EstimateInputTokens(opts); got != 1 {
+		t.Fatalf("EstimateInputTokens with PDF text = %d, want 1", got)
+	}
+	pdf.Text = ""
+	opts.Files[0] = pdf
+	if got := EstimateInputTokens(opts); got != 100 {
+		t.Fatalf("EstimateInputTokens without PDF text = %d, want 100", got)
+	}
+}
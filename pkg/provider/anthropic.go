@@ -2,13 +2,20 @@ package provider
 
 import (
 	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"strings"
 )
 
+// anthropicOutputTool is the tool forced for structured output; its input is
+// the output object.
+const anthropicOutputTool = "structured_output"
+
 // AnthropicProvider implements Provider for Anthropic Messages API.
 type AnthropicProvider struct{}
 
@@ -20,27 +27,165 @@ func (p *AnthropicProvider) DefaultOptions() Options {
 	}
 }
 
+func (p *AnthropicProvider) Capabilities() Capabilities {
+	return Capabilities{
+		Streaming:        true,
+		Tools:            true,
+		Vision:           true,
+		SystemPrompt:     true,
+		MaxContextTokens: 200000,
+	}
+}
+
 func (p *AnthropicProvider) BuildAPIPayload(opts Options) (map[string]interface{}, error) {
+	// Anthropic takes a single system prompt and strictly alternating roles:
+	// fold system turns into the instructions and merge adjacent same-role turns.
+	opts = foldSystemTurns(opts)
+	messages := make([]map[string]interface{}, 0, len(opts.History)+1)
+	for _, t := range opts.History {
+		messages = appendAnthropicMessage(messages, anthropicMessage(t))
+	}
+	if !userTurnOmitted(opts) {
+		messages = appendAnthropicMessage(messages, map[string]interface{}{"role": RoleUser, "content": anthropicUserContent(opts.Message, opts.Images, opts.Files)})
+	}
+
 	payload := map[string]interface{}{
 		"model":      opts.Model,
 		"max_tokens": opts.MaxTokens,
-		"messages": []map[string]interface{}{
-			{
-				"role":    "user",
-				"content": opts.Message,
-			},
-		},
+		"messages":   messages,
 	}
 
-	// Merge instruction with strict JSON hint if properties exist.
-	if sys := buildStrictJSONSystem(opts.Properties, opts.Instructions); strings.TrimSpace(sys) != "" {
+	// Structured output is enforced by forcing a single tool whose input is
+	// the requested object. Declared tools must stay free for the model to
+	// choose, so alongside them the strict JSON system hint is used instead.
+	structured := len(opts.Properties) > 0 || opts.Schema != nil
+	if structured && len(opts.Tools) == 0 {
+		if instr := strings.TrimSpace(opts.Instructions); instr != "" {
+			payload["system"] = instr
+		}
+		output := Tool{Properties: opts.Properties, Required: opts.Required, Schema: opts.Schema}
+		payload["tools"] = []map[string]interface{}{{
+			"name":         anthropicOutputTool,
+			"description":  "Return the final answer as this tool's input.",
+			"input_schema": output.jsonSchema(),
+		}}
+		payload["tool_choice"] = map[string]interface{}{"type": "tool", "name": anthropicOutputTool}
+	} else if sys := strictJSONSystemFor(opts); strings.TrimSpace(sys) != "" {
 		payload["system"] = sys
 	}
 
+	if len(opts.Tools) > 0 {
+		tools := make([]map[string]interface{}, 0, len(opts.Tools))
+		for _, t := range opts.Tools {
+			tool := map[string]interface{}{
+				"name":         t.Name,
+				"input_schema": t.jsonSchema(),
+			}
+			if t.Description != "" {
+				tool["description"] = t.Description
+			}
+			tools = append(tools, tool)
+		}
+		payload["tools"] = tools
+		switch mode, name := toolChoiceMode(opts.ToolChoice); {
+		case name != "":
+			payload["tool_choice"] = map[string]interface{}{"type": "tool", "name": name}
+		case mode == ToolChoiceRequired:
+			payload["tool_choice"] = map[string]interface{}{"type": "any"}
+		default:
+			payload["tool_choice"] = map[string]interface{}{"type": mode}
+		}
+	}
+
 	return payload, nil
 }
 
-func (p *AnthropicProvider) BuildAPIRequest(payload map[string]interface{}, baseURL string, reqOpts RequestOptions) (*http.Request, error) {
+// anthropicMessage converts a turn to a Messages API message. Tool calls
+// become tool_use blocks and tool results are user tool_result blocks.
+func anthropicMessage(t Turn) map[string]interface{} {
+	if t.Role == RoleTool {
+		return map[string]interface{}{
+			"role": RoleUser,
+			"content": []map[string]interface{}{{
+				"type":        "tool_result",
+				"tool_use_id": t.ToolCallID,
+				"content":     t.Content,
+			}},
+		}
+	}
+	if t.hasAttachments() {
+		return map[string]interface{}{"role": RoleUser, "content": anthropicUserContent(t.Content, t.Images, t.Files)}
+	}
+	if len(t.ToolCalls) == 0 {
+		return map[string]interface{}{"role": t.Role, "content": t.Content}
+	}
+	blocks := make([]map[string]interface{}, 0, len(t.ToolCalls)+1)
+	if t.Content != "" {
+		blocks = append(blocks, map[string]interface{}{"type": "text", "text": t.Content})
+	}
+	for _, c := range t.ToolCalls {
+		input := c.Arguments
+		if input == nil {
+			input = map[string]interface{}{}
+		}
+		blocks = append(blocks, map[string]interface{}{"type": "tool_use", "id": c.ID, "name": c.Name, "input": input})
+	}
+	return map[string]interface{}{"role": t.Role, "content": blocks}
+}
+
+// anthropicUserContent returns the content of a user message: the text
+// alone, or blocks when images or files are attached. Documents and images
+// go before the text, as Anthropic recommends.
+func anthropicUserContent(text string, images []Image, files []File) interface{} {
+	if len(images) == 0 && len(files) == 0 {
+		return text
+	}
+	blocks := make([]map[string]interface{}, 0, len(files)+len(images)+1)
+	for _, f := range files {
+		blocks = append(blocks, anthropicDocumentBlock(f))
+	}
+	for _, img := range images {
+		blocks = append(blocks, anthropicImageBlock(img))
+	}
+	if text != "" {
+		blocks = append(blocks, map[string]interface{}{"type": "text", "text": text})
+	}
+	return blocks
+}
+
+// appendAnthropicMessage appends msg, merging it into the previous message
+// when both share a role. Plain strings are joined; otherwise the contents
+// are combined as blocks.
+func appendAnthropicMessage(messages []map[string]interface{}, msg map[string]interface{}) []map[string]interface{} {
+	n := len(messages)
+	if n == 0 || messages[n-1]["role"] != msg["role"] {
+		return append(messages, msg)
+	}
+	prev := messages[n-1]
+	a, aStr := prev["content"].(string)
+	b, bStr := msg["content"].(string)
+	if aStr && bStr {
+		prev["content"] = a + "\n\n" + b
+		return messages
+	}
+	prev["content"] = append(anthropicBlocks(prev["content"]), anthropicBlocks(msg["content"])...)
+	return messages
+}
+
+func anthropicBlocks(content interface{}) []map[string]interface{} {
+	switch c := content.(type) {
+	case string:
+		if c == "" {
+			return nil
+		}
+		return []map[string]interface{}{{"type": "text", "text": c}}
+	case []map[string]interface{}:
+		return c
+	}
+	return nil
+}
+
+func (p *AnthropicProvider) BuildAPIRequest(ctx context.Context, payload map[string]interface{}, baseURL string, reqOpts RequestOptions) (*http.Request, error) {
 	body, err := json.Marshal(payload)
 	if err != nil {
 		return nil, fmt.Errorf("failed to encode payload: %w", err)
@@ -51,12 +196,15 @@ func (p *AnthropicProvider) BuildAPIRequest(payload map[string]interface{}, base
 		baseURL = "https://api.anthropic.com/v1"
 	}
 
-	req, err := http.NewRequest("POST", strings.TrimRight(baseURL, "/")+"/messages", bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimRight(baseURL, "/")+"/messages", bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
+	if stream, _ := payload["stream"].(bool); stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
 	req.Header.Set("anthropic-version", "2023-06-01")
 
 	apiKey := reqOpts.APIKey
@@ -78,26 +226,180 @@ func (p *AnthropicProvider) BuildAPIRequest(payload map[string]interface{}, base
 	return req, nil
 }
 
-func (p *AnthropicProvider) ParseAPIResponse(respBody []byte) (string, error) {
-	// Aggregate all text content blocks.
+func (p *AnthropicProvider) ParseAPIResponse(respBody []byte) (Response, error) {
+	// Aggregate all text content blocks, unless the forced output tool was
+	// called: its input is the structured output.
 	var apiResp struct {
-		Content []struct {
-			Type string `json:"type"`
-			Text string `json:"text"`
+		ID         string `json:"id"`
+		Model      string `json:"model"`
+		StopReason string `json:"stop_reason"`
+		Content    []struct {
+			Type  string          `json:"type"`
+			Text  string          `json:"text"`
+			Name  string          `json:"name"`
+			Input json.RawMessage `json:"input"`
 		} `json:"content"`
+		Usage anthropicUsage `json:"usage"`
 	}
 
 	if err := json.Unmarshal(respBody, &apiResp); err != nil {
-		return "", fmt.Errorf("failed to parse response: %v", err)
+		return Response{}, fmt.Errorf("failed to parse response: %v", err)
 	}
 
+	out := Response{ID: apiResp.ID, Model: apiResp.Model, Usage: apiResp.Usage.usage()}
+	forced := false
 	var b strings.Builder
 	for _, c := range apiResp.Content {
+		if c.Type == "tool_use" && c.Name == anthropicOutputTool && len(c.Input) > 0 {
+			out.Text, forced = string(c.Input), true
+			break
+		}
 		if c.Type == "text" && c.Text != "" {
 			b.WriteString(c.Text)
 		}
 	}
-	return b.String(), nil
+	if !forced {
+		out.Text = b.String()
+	}
+	out.FinishReason = anthropicFinishReason(apiResp.StopReason, forced)
+	return out, finishError("anthropic", out, apiResp.StopReason, "")
+}
+
+// anthropicUsage is the Messages API usage object. Its input_tokens excludes
+// cache reads and writes, which Usage.InputTokens includes.
+type anthropicUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+}
+
+func (u anthropicUsage) usage() Usage {
+	return Usage{
+		InputTokens:  u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens,
+		OutputTokens: u.OutputTokens,
+		CachedTokens: u.CacheReadInputTokens,
+	}
+}
+
+// anthropicFinishReason normalizes a stop_reason. Calling the forced output
+// tool is a normal end of the answer, not a tool call.
+func anthropicFinishReason(stopReason string, forcedOutput bool) string {
+	if stopReason == "tool_use" && forcedOutput {
+		return FinishStop
+	}
+	return normalizeFinishReason(stopReason, map[string]string{
+		"end_turn":      FinishStop,
+		"stop_sequence": FinishStop,
+		"max_tokens":    FinishLength,
+		"tool_use":      FinishToolCalls,
+		"refusal":       FinishContentFilter,
+	})
+}
+
+// ParseToolCalls implements ToolCallingProvider for tool_use content blocks.
+func (p *AnthropicProvider) ParseToolCalls(respBody []byte) ([]ToolCall, error) {
+	var apiResp struct {
+		Content []struct {
+			Type  string                 `json:"type"`
+			ID    string                 `json:"id"`
+			Name  string                 `json:"name"`
+			Input map[string]interface{} `json:"input"`
+		} `json:"content"`
+	}
+	if err := json.Unmarshal(respBody, &apiResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %v", err)
+	}
+	var calls []ToolCall
+	for _, c := range apiResp.Content {
+		if c.Type != "tool_use" {
+			continue
+		}
+		args := c.Input
+		if args == nil {
+			args = map[string]interface{}{}
+		}
+		calls = append(calls, ToolCall{ID: c.ID, Name: c.Name, Arguments: args})
+	}
+	return calls, nil
+}
+
+// EnableStreaming implements StreamingProvider.
+func (p *AnthropicProvider) EnableStreaming(payload map[string]interface{}) {
+	payload["stream"] = true
+}
+
+// ParseAPIStream implements StreamingProvider for Messages API stream events.
+func (p *AnthropicProvider) ParseAPIStream(body io.Reader, onText func(string)) (Response, error) {
+	var (
+		b          strings.Builder
+		out        Response
+		usage      anthropicUsage
+		stopReason string
+		forced     bool
+	)
+	err := readSSE(body, func(_, data string) error {
+		var ev struct {
+			Type    string `json:"type"`
+			Message struct {
+				ID    string         `json:"id"`
+				Model string         `json:"model"`
+				Usage anthropicUsage `json:"usage"`
+			} `json:"message"`
+			ContentBlock struct {
+				Type string `json:"type"`
+				Name string `json:"name"`
+			} `json:"content_block"`
+			Delta struct {
+				Type        string `json:"type"`
+				Text        string `json:"text"`
+				PartialJSON string `json:"partial_json"`
+				StopReason  string `json:"stop_reason"`
+			} `json:"delta"`
+			Usage *anthropicUsage `json:"usage"`
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(data), &ev); err != nil {
+			return fmt.Errorf("failed to parse stream event: %v", err)
+		}
+		switch ev.Type {
+		case "message_start":
+			out.ID, out.Model, usage = ev.Message.ID, ev.Message.Model, ev.Message.Usage
+		case "content_block_start":
+			if ev.ContentBlock.Type == "tool_use" && ev.ContentBlock.Name == anthropicOutputTool {
+				forced = true
+			}
+		case "content_block_delta":
+			switch {
+			case ev.Delta.Type == "text_delta" && ev.Delta.Text != "":
+				b.WriteString(ev.Delta.Text)
+				onText(ev.Delta.Text)
+			case ev.Delta.Type == "input_json_delta" && ev.Delta.PartialJSON != "":
+				// Structured output arrives as the forced tool's input.
+				b.WriteString(ev.Delta.PartialJSON)
+				onText(ev.Delta.PartialJSON)
+			}
+		case "message_delta":
+			stopReason = ev.Delta.StopReason
+			if ev.Usage != nil {
+				// The final output count is cumulative.
+				usage.OutputTokens = ev.Usage.OutputTokens
+			}
+		case "error":
+			return fmt.Errorf("anthropic: stream error: %s: %s", ev.Error.Type, ev.Error.Message)
+		}
+		return nil
+	})
+	out.Text = b.String()
+	out.Usage = usage.usage()
+	out.FinishReason = anthropicFinishReason(stopReason, forced)
+	if err != nil {
+		return out, err
+	}
+	return out, finishError("anthropic", out, stopReason, "")
 }
 
 // anthropicDefaultMaxTokens returns a default max_tokens per model family
@@ -124,3 +426,31 @@ func anthropicDefaultMaxTokens(model string) int {
 		return 4_096
 	}
 }
+
+func anthropicImageBlock(img Image) map[string]interface{} {
+	source := map[string]interface{}{"type": "url", "url": img.URL}
+	if img.URL == "" {
+		source = map[string]interface{}{
+			"type":       "base64",
+			"media_type": img.MIMEType,
+			"data":       base64.StdEncoding.EncodeToString(img.Data),
+		}
+	}
+	return map[string]interface{}{"type": "image", "source": source}
+}
+
+func anthropicDocumentBlock(f File) map[string]interface{} {
+	source := map[string]interface{}{
+		"type":       "text",
+		"media_type": "text/plain",
+		"data":       string(f.Data),
+	}
+	if f.isPDF() {
+		source = map[string]interface{}{
+			"type":       "base64",
+			"media_type": mimePDF,
+			"data":       base64.StdEncoding.EncodeToString(f.Data),
+		}
+	}
+	return map[string]interface{}{"type": "document", "source": source, "title": f.Name}
+}
@@ -1,9 +1,12 @@
 package provider
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -25,6 +28,11 @@ func TestAnthropicProvider_ParseAPIResponse(t *testing.T) {
 			body: []byte(`{"content":[{"type":"text","text":"Hello "},{"type":"tool_use","id":"x"},{"type":"text","text":"World"}]}`),
 			want: "Hello World",
 		},
+		{
+			name: "forced output tool input",
+			body: []byte(`{"content":[{"type":"tool_use","id":"t1","name":"structured_output","input":{"name":"Ann"}}]}`),
+			want: `{"name":"Ann"}`,
+		},
 		{
 			name:    "invalid json",
 			body:    []byte(`invalid`),
@@ -42,8 +50,8 @@ func TestAnthropicProvider_ParseAPIResponse(t *testing.T) {
 			if (err != nil) != tt.wantErr {
 				t.Fatalf("error=%v, wantErr=%v", err, tt.wantErr)
 			}
-			if got != tt.want {
-				t.Fatalf("got %q, want %q", got, tt.want)
+			if got.Text != tt.want {
+				t.Fatalf("got %q, want %q", got.Text, tt.want)
 			}
 		})
 	}
@@ -77,7 +85,7 @@ func TestAnthropicProvider_BuildAPIPayload_Defaults(t *testing.T) {
 func TestAnthropicProvider_BuildAPIRequest_DefaultsAndHeaders(t *testing.T) {
 	p := &AnthropicProvider{}
 	payload := map[string]interface{}{"model": "claude-3-5-haiku-latest", "messages": []map[string]interface{}{}}
-	req, err := p.BuildAPIRequest(payload, "", RequestOptions{APIKey: "anth-key"})
+	req, err := p.BuildAPIRequest(context.Background(), payload, "", RequestOptions{APIKey: "anth-key"})
 	if err != nil {
 		t.Fatalf("unexpected err: %v", err)
 	}
@@ -105,3 +113,190 @@ func TestAnthropicProvider_BuildAPIRequest_DefaultsAndHeaders(t *testing.T) {
 		t.Fatalf("body model mismatch: %v", got["model"])
 	}
 }
+
+func TestAnthropicProvider_BuildAPIPayload_ForcedOutputTool(t *testing.T) {
+	p := &AnthropicProvider{}
+	payload, err := p.BuildAPIPayload(Options{
+		Model:        "claude-3-5-haiku-latest",
+		MaxTokens:    1024,
+		Message:      "Hello",
+		Instructions: "sys",
+		Properties: map[string]interface{}{
+			"name": map[string]interface{}{"type": "string"},
+			"note": map[string]interface{}{"type": "string", "nullable": true},
+		},
+		Required: []string{"name"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if payload["system"] != "sys" {
+		t.Fatalf("system should carry only the instructions: %v", payload["system"])
+	}
+	tools := payload["tools"].([]map[string]interface{})
+	if len(tools) != 1 || tools[0]["name"] != "structured_output" {
+		t.Fatalf("tools mismatch: %v", tools)
+	}
+	want := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"name": map[string]interface{}{"type": "string"},
+			"note": map[string]interface{}{"type": []interface{}{"string", "null"}},
+		},
+		"required": []string{"name"},
+	}
+	if !reflect.DeepEqual(tools[0]["input_schema"], want) {
+		t.Fatalf("input_schema mismatch:\ngot=%v\nwant=%v", tools[0]["input_schema"], want)
+	}
+	if !reflect.DeepEqual(payload["tool_choice"], map[string]interface{}{"type": "tool", "name": "structured_output"}) {
+		t.Fatalf("tool_choice mismatch: %v", payload["tool_choice"])
+	}
+
+	// Plain text requests declare no tools.
+	payload, err = p.BuildAPIPayload(Options{Model: "claude-3-5-haiku-latest", MaxTokens: 1024, Message: "Hello"})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if _, ok := payload["tools"]; ok {
+		t.Fatalf("unexpected tools for plain text: %v", payload["tools"])
+	}
+}
+
+// The strict JSON system hint is used when declared tools rule out forcing
+// the structured output tool.
+func TestAnthropicProvider_BuildAPIPayload_NestedSchemaHint(t *testing.T) {
+	p := &AnthropicProvider{}
+	payload, err := p.BuildAPIPayload(Options{
+		Model:     "claude-3-5-haiku-latest",
+		MaxTokens: 1024,
+		Message:   "Hello",
+		Tools:     []Tool{{Name: "ping"}},
+		Properties: map[string]interface{}{
+			"user": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{"type": "string"},
+					"age":  map[string]interface{}{"type": "integer"},
+				},
+			},
+			"items": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"sku": map[string]interface{}{"type": "string"},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	sys, _ := payload["system"].(string)
+	for _, want := range []string{
+		"items: array<object{sku: string}>",
+		"user: object{age: integer, name: string}",
+	} {
+		if !strings.Contains(sys, want) {
+			t.Fatalf("system hint missing %q: %q", want, sys)
+		}
+	}
+}
+
+func TestAnthropicProvider_BuildAPIPayload_EnumHint(t *testing.T) {
+	p := &AnthropicProvider{}
+	payload, err := p.BuildAPIPayload(Options{
+		Model:     "claude-3-5-haiku-latest",
+		MaxTokens: 1024,
+		Message:   "Hello",
+		Tools:     []Tool{{Name: "ping"}},
+		Properties: map[string]interface{}{
+			"sentiment": map[string]interface{}{"type": "string", "enum": []string{"positive", "neutral", "negative"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	sys, _ := payload["system"].(string)
+	want := `sentiment: string (one of: "positive", "neutral", "negative")`
+	if !strings.Contains(sys, want) {
+		t.Fatalf("system hint missing %q: %q", want, sys)
+	}
+}
+
+func TestAnthropicProvider_BuildAPIPayload_OptionalAndNullableHint(t *testing.T) {
+	p := &AnthropicProvider{}
+	payload, err := p.BuildAPIPayload(Options{
+		Model:     "claude-3-5-haiku-latest",
+		MaxTokens: 1024,
+		Message:   "Hello",
+		Tools:     []Tool{{Name: "ping"}},
+		Properties: map[string]interface{}{
+			"name": map[string]interface{}{"type": "string"},
+			"nick": map[string]interface{}{"type": "string"},
+			"note": map[string]interface{}{"type": "string", "nullable": true},
+		},
+		Required: []string{"name", "note"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	sys, _ := payload["system"].(string)
+	for _, want := range []string{"optional", "name: string, nick?: string, note: string|null"} {
+		if !strings.Contains(sys, want) {
+			t.Fatalf("system hint missing %q: %q", want, sys)
+		}
+	}
+}
+
+func TestAnthropicProvider_BuildAPIPayload_History(t *testing.T) {
+	p := &AnthropicProvider{}
+	payload, err := p.BuildAPIPayload(Options{
+		Model:     "claude-3-5-haiku-latest",
+		MaxTokens: 1024,
+		Message:   "fix it",
+		History: []Turn{
+			{Role: RoleUser, Content: "Hello"},
+			{Role: RoleAssistant, Content: "{bad"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	msgs := payload["messages"].([]map[string]interface{})
+	if len(msgs) != 3 || msgs[1]["role"] != "assistant" || msgs[2]["content"] != "fix it" {
+		t.Fatalf("messages mismatch: %v", msgs)
+	}
+}
+
+func TestAnthropicProvider_BuildAPIPayload_SystemTurnsAndAlternation(t *testing.T) {
+	p := &AnthropicProvider{}
+	payload, err := p.BuildAPIPayload(Options{
+		Model:        "claude-3-5-haiku-latest",
+		MaxTokens:    1024,
+		Instructions: "sys",
+		Message:      "third",
+		History: []Turn{
+			{Role: RoleSystem, Content: "from transcript"},
+			{Role: RoleUser, Content: "first"},
+			{Role: RoleAssistant, Content: "reply"},
+			{Role: RoleUser, Content: "second"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if payload["system"] != "sys\n\nfrom transcript" {
+		t.Fatalf("system mismatch: %q", payload["system"])
+	}
+	msgs := payload["messages"].([]map[string]interface{})
+	want := []map[string]interface{}{
+		{"role": "user", "content": "first"},
+		{"role": "assistant", "content": "reply"},
+		{"role": "user", "content": "second\n\nthird"},
+	}
+	if !reflect.DeepEqual(msgs, want) {
+		t.Fatalf("messages mismatch:\ngot=%v\nwant=%v", msgs, want)
+	}
+}
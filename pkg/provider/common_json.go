@@ -1,16 +1,18 @@
 package provider
 
 import (
+	"encoding/json"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 )
 
 // buildStrictJSONSystem returns a unified system instruction string that
 // enforces strict JSON output. It merges the given instruction with a concise
 // schema hint derived from properties. The function accepts properties first
-// to match repository conventions.
-func buildStrictJSONSystem(properties map[string]interface{}, instruction string) string {
+// to match repository conventions; a nil required marks every key required.
+func buildStrictJSONSystem(properties map[string]interface{}, required []string, instruction string) string {
 	instr := strings.TrimSpace(instruction)
 
 	// If no properties, only return the original instruction.
@@ -18,42 +20,168 @@ func buildStrictJSONSystem(properties map[string]interface{}, instruction string
 		return instr
 	}
 
-	// Build a concise schema hint for fields and types based on provided
-	// properties. Keep ordering deterministic for tests and logs.
+	var b strings.Builder
+	b.WriteString("RETURN ONLY A STRICT JSON OBJECT. NO PROSE, NO EXPLANATIONS, NO MARKDOWN.\n")
+	fields, hasOptional := describeFields(properties, required)
+	if hasOptional {
+		b.WriteString("Fields (keys marked ? are optional and may be omitted; all others are required): ")
+	} else {
+		b.WriteString("Fields (all required): ")
+	}
+	b.WriteString(fields)
+
+	schemaHint := b.String()
+	if instr == "" {
+		return schemaHint
+	}
+	return instr + "\n\n" + schemaHint
+}
+
+// strictJSONSystemFor returns the strict JSON system instruction for opts,
+// embedding the full JSON Schema when one was supplied.
+func strictJSONSystemFor(opts Options) string {
+	if opts.Schema == nil {
+		return buildStrictJSONSystem(opts.Properties, opts.Required, opts.Instructions)
+	}
+	var b strings.Builder
+	b.WriteString("RETURN ONLY A STRICT JSON OBJECT. NO PROSE, NO EXPLANATIONS, NO MARKDOWN.\n")
+	b.WriteString("The object must validate against this JSON Schema:\n")
+	if schemaJSON, err := json.Marshal(opts.Schema); err == nil {
+		b.Write(schemaJSON)
+	}
+	schemaHint := b.String()
+	if instr := strings.TrimSpace(opts.Instructions); instr != "" {
+		return instr + "\n\n" + schemaHint
+	}
+	return schemaHint
+}
+
+// describeFields renders "key: type" pairs for properties in a deterministic
+// order, marking optional keys with "?" and quoting any description. It also reports whether any key
+// (at any depth) is optional.
+func describeFields(properties map[string]interface{}, required []string) (string, bool) {
+	isRequired := requiredSet(properties, required)
 	keys := make([]string, 0, len(properties))
 	for k := range properties {
 		keys = append(keys, k)
 	}
 	sort.Strings(keys)
 
-	var b strings.Builder
-	b.WriteString("RETURN ONLY A STRICT JSON OBJECT. NO PROSE, NO EXPLANATIONS, NO MARKDOWN.\n")
-	b.WriteString("Fields (all required): ")
-	for i, k := range keys {
-		if i > 0 {
-			b.WriteString(", ")
-		}
+	hasOptional := false
+	fields := make([]string, 0, len(keys))
+	for _, k := range keys {
 		// Best-effort type extraction from shorthand
 		t := "string"
 		if m, ok := properties[k].(map[string]interface{}); ok {
-			if tt, ok := m["type"].(string); ok {
-				if strings.EqualFold(tt, "array") {
-					if it, ok := m["items"].(map[string]interface{}); ok {
-						if itype, ok := it["type"].(string); ok {
-							t = fmt.Sprintf("array<%s>", itype)
-						}
-					}
-				} else {
-					t = tt
-				}
+			var nestedOptional bool
+			t, nestedOptional = describeSchemaType(m)
+			hasOptional = hasOptional || nestedOptional
+			if d, _ := m["description"].(string); strings.TrimSpace(d) != "" {
+				t += " (description: " + strconv.Quote(strings.TrimSpace(d)) + ")"
 			}
 		}
-		b.WriteString(k + ": " + t)
+		name := k
+		if !isRequired[k] {
+			name += "?"
+			hasOptional = true
+		}
+		fields = append(fields, name+": "+t)
 	}
+	return strings.Join(fields, ", "), hasOptional
+}
 
-	schemaHint := b.String()
-	if instr == "" {
-		return schemaHint
+// describeSchemaType renders a compact, human-readable type for a property
+// schema, e.g. "string", "array<integer>", "object{name: string}" or
+// "string|null". It also reports whether a nested object has optional keys.
+func describeSchemaType(m map[string]interface{}) (string, bool) {
+	tt, _ := m["type"].(string)
+	desc, hasOptional := tt, false
+	switch strings.ToLower(tt) {
+	case "":
+		desc = "string"
+	case "array":
+		if it, ok := m["items"].(map[string]interface{}); ok {
+			var itemDesc string
+			itemDesc, hasOptional = describeSchemaType(it)
+			desc = "array<" + itemDesc + ">"
+		}
+	case "object":
+		props, _ := m["properties"].(map[string]interface{})
+		var fields string
+		fields, hasOptional = describeFields(props, schemaRequired(m))
+		desc = "object{" + fields + "}"
+	default:
+		if values := enumStrings(m["enum"]); len(values) > 0 {
+			quoted := make([]string, len(values))
+			for i, v := range values {
+				quoted[i] = strconv.Quote(v)
+			}
+			desc = tt + " (one of: " + strings.Join(quoted, ", ") + ")"
+		}
+	}
+	if nullable, _ := m["nullable"].(bool); nullable {
+		desc += "|null"
+	}
+	return desc, hasOptional
+}
+
+// requiredSet returns the set of required keys. A nil required means every
+// key in properties is required.
+func requiredSet(properties map[string]interface{}, required []string) map[string]bool {
+	set := make(map[string]bool, len(properties))
+	if required == nil {
+		for k := range properties {
+			set[k] = true
+		}
+		return set
+	}
+	for _, k := range required {
+		set[k] = true
+	}
+	return set
+}
+
+// schemaRequired returns the "required" keyword of an object schema, or nil
+// when it is absent.
+func schemaRequired(m map[string]interface{}) []string {
+	switch rv := m["required"].(type) {
+	case []string:
+		return rv
+	case []interface{}:
+		out := make([]string, 0, len(rv))
+		for _, v := range rv {
+			if s, ok := v.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+// withNullable returns a shallow copy of m marked nullable.
+func withNullable(m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m)+1)
+	for k, v := range m {
+		out[k] = v
+	}
+	out["nullable"] = true
+	return out
+}
+
+// enumStrings returns the allowed values of an enum keyword as strings.
+func enumStrings(v interface{}) []string {
+	switch vs := v.(type) {
+	case []string:
+		return vs
+	case []interface{}:
+		out := make([]string, 0, len(vs))
+		for _, e := range vs {
+			out = append(out, fmt.Sprint(e))
+		}
+		return out
+	default:
+		return nil
 	}
-	return instr + "\n\n" + schemaHint
 }
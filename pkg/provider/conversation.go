@@ -0,0 +1,58 @@
+package provider
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// foldSystemTurns moves RoleSystem turns out of opts.History and appends
+// their content to opts.Instructions, for APIs that take a single system
+// prompt. The returned Options shares nothing mutable with opts.
+func foldSystemTurns(opts Options) Options {
+	if len(opts.History) == 0 {
+		return opts
+	}
+	instr := []string{}
+	if s := strings.TrimSpace(opts.Instructions); s != "" {
+		instr = append(instr, s)
+	}
+	rest := make([]Turn, 0, len(opts.History))
+	for _, t := range opts.History {
+		if t.Role == RoleSystem {
+			if s := strings.TrimSpace(t.Content); s != "" {
+				instr = append(instr, s)
+			}
+			continue
+		}
+		rest = append(rest, t)
+	}
+	opts.Instructions = strings.Join(instr, "\n\n")
+	opts.History = rest
+	return opts
+}
+
+// hasAttachments reports whether t is a user turn with images or files.
+func (t Turn) hasAttachments() bool {
+	return t.Role == RoleUser && (len(t.Images) > 0 || len(t.Files) > 0)
+}
+
+// userTurnOmitted reports whether opts continues after tool results without
+// a new user message.
+func userTurnOmitted(opts Options) bool {
+	n := len(opts.History)
+	return opts.Message == "" && len(opts.Images) == 0 && len(opts.Files) == 0 &&
+		n > 0 && opts.History[n-1].Role == RoleTool
+}
+
+// encodeToolArguments renders call arguments as the JSON string some APIs
+// expect.
+func encodeToolArguments(args map[string]interface{}) string {
+	if args == nil {
+		return "{}"
+	}
+	b, err := json.Marshal(args)
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
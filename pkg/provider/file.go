@@ -0,0 +1,44 @@
+package provider
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"llmx/pkg/pdftext"
+)
+
+const mimePDF = "application/pdf"
+
+func (f File) isPDF() bool { return f.MIMEType == mimePDF }
+
+// dataURL returns the file as a base64 data URL.
+func (f File) dataURL() string {
+	return "data:" + f.MIMEType + ";base64," + base64.StdEncoding.EncodeToString(f.Data)
+}
+
+// inlineText wraps the text of a file so the model can tell where it starts
+// and ends, for APIs without a native document input.
+func (f File) inlineText(text string) string {
+	return fmt.Sprintf("<file name=%q>\n%s\n</file>", f.Name, strings.TrimRight(text, "\n"))
+}
+
+// extractedText returns the file's text for APIs that only accept text,
+// extracting it locally from PDFs unless Text is set.
+func (f File) extractedText() (string, error) {
+	if !f.isPDF() {
+		return string(f.Data), nil
+	}
+	if f.Text != "" {
+		return f.Text, nil
+	}
+	text, err := pdftext.Extract(f.Data)
+	if err != nil {
+		if errors.Is(err, pdftext.ErrNoText) {
+			return "", fmt.Errorf("%s: %w; use a provider with native PDF support (openai, anthropic, gemini)", f.Name, err)
+		}
+		return "", fmt.Errorf("%s: failed to extract PDF text: %w", f.Name, err)
+	}
+	return text, nil
+}
@@ -0,0 +1,101 @@
+package provider
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var (
+	testPDF = File{Name: "a.pdf", MIMEType: "application/pdf", Data: []byte(
+		"%PDF-1.4\n1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n" +
+			"2 0 obj\n<< /Type /Pages /Kids [3 0 R] /Count 1 >>\nendobj\n" +
+			"3 0 obj\n<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>\nendobj\n" +
+			"4 0 obj\n<< /Length 24 >>\nstream\nBT (Total due: 42) Tj ET\nendstream\nendobj\n" +
+			"trailer\n<< /Root 1 0 R >>\n")}
+	testTextFile = File{Name: "notes.txt", MIMEType: "text/plain", Data: []byte("hello\n")}
+)
+
+func TestOpenAIProvider_BuildAPIPayload_Files(t *testing.T) {
+	p := &OpenAIProvider{}
+	payload, err := p.BuildAPIPayload(Options{Model: "gpt-5-nano", Message: "read", Files: []File{testPDF, testTextFile}})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	parts := payload["input"].([]map[string]interface{})[0]["content"].([]map[string]interface{})
+	if len(parts) != 3 {
+		t.Fatalf("expected 3 parts, got %v", parts)
+	}
+	if parts[1]["type"] != "input_file" || parts[1]["filename"] != "a.pdf" || !strings.HasPrefix(parts[1]["file_data"].(string), "data:application/pdf;base64,") {
+		t.Fatalf("pdf part mismatch: %v", parts[1])
+	}
+	if parts[2]["text"] != "<file name=\"notes.txt\">\nhello\n</file>" {
+		t.Fatalf("text part mismatch: %v", parts[2])
+	}
+}
+
+func TestOpenAICompatProvider_BuildAPIPayload_FilesExtractText(t *testing.T) {
+	p := &OpenAICompatProvider{}
+	payload, err := p.BuildAPIPayload(Options{Model: "gpt-4o-mini", Message: "read", Files: []File{testPDF}})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	msgs := payload["messages"].([]map[string]interface{})
+	parts := msgs[len(msgs)-1]["content"].([]map[string]interface{})
+	if parts[1]["text"] != "<file name=\"a.pdf\">\nTotal due: 42\n</file>" {
+		t.Fatalf("extracted text mismatch: %v", parts[1])
+	}
+
+	scanned := File{Name: "scan.pdf", MIMEType: "application/pdf", Data: []byte("%PDF-1.4\n1 0 obj\n<< /Type /Page >>\nendobj\n")}
+	if _, err := p.BuildAPIPayload(Options{Model: "gpt-4o-mini", Message: "read", Files: []File{scanned}}); err == nil || !strings.Contains(err.Error(), "native PDF support") {
+		t.Fatalf("expected a clear error for PDFs without text, got %v", err)
+	}
+
+	// Text extracted when the file was loaded is reused.
+	scanned.Text = "OCR text"
+	payload, err = p.BuildAPIPayload(Options{Model: "gpt-4o-mini", Message: "read", Files: []File{scanned}})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	msgs = payload["messages"].([]map[string]interface{})
+	parts = msgs[len(msgs)-1]["content"].([]map[string]interface{})
+	if parts[1]["text"] != "<file name=\"scan.pdf\">\nOCR text\n</file>" {
+		t.Fatalf("preset text not used: %v", parts[1])
+	}
+}
+
+func TestAnthropicProvider_BuildAPIPayload_Files(t *testing.T) {
+	p := &AnthropicProvider{}
+	payload, err := p.BuildAPIPayload(Options{Model: "claude-3-5-haiku-latest", MaxTokens: 1024, Message: "read", Files: []File{testTextFile}, Images: []Image{testPNG}})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	blocks := payload["messages"].([]map[string]interface{})[0]["content"].([]map[string]interface{})
+	want := map[string]interface{}{
+		"type":   "document",
+		"title":  "notes.txt",
+		"source": map[string]interface{}{"type": "text", "media_type": "text/plain", "data": "hello\n"},
+	}
+	if len(blocks) != 3 || !reflect.DeepEqual(blocks[0], want) || blocks[1]["type"] != "image" || blocks[2]["type"] != "text" {
+		t.Fatalf("blocks mismatch: %v", blocks)
+	}
+
+	payload, _ = p.BuildAPIPayload(Options{Model: "claude-3-5-haiku-latest", MaxTokens: 1024, Message: "read", Files: []File{testPDF}})
+	src := payload["messages"].([]map[string]interface{})[0]["content"].([]map[string]interface{})[0]["source"].(map[string]interface{})
+	if src["type"] != "base64" || src["media_type"] != "application/pdf" {
+		t.Fatalf("pdf source mismatch: %v", src)
+	}
+}
+
+func TestGeminiProvider_BuildAPIPayload_Files(t *testing.T) {
+	p := &GeminiProvider{}
+	payload, err := p.BuildAPIPayload(Options{Model: "gemini-2.0-flash", Message: "read", Files: []File{testPDF}})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	parts := payload["contents"].([]map[string]interface{})[0]["parts"].([]map[string]interface{})
+	inline := parts[0]["inline_data"].(map[string]interface{})
+	if inline["mime_type"] != "application/pdf" || parts[1]["text"] != "read" {
+		t.Fatalf("parts mismatch: %v", parts)
+	}
+}
@@ -2,11 +2,15 @@ package provider
 
 import (
 	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"sort"
 	"strings"
 )
 
@@ -20,14 +24,48 @@ func (p *GeminiProvider) DefaultOptions() Options {
 	}
 }
 
+func (p *GeminiProvider) Capabilities() Capabilities {
+	return Capabilities{
+		NativeSchema:     true,
+		Streaming:        true,
+		Tools:            true,
+		Vision:           true,
+		SystemPrompt:     true,
+		MaxContextTokens: 1048576,
+	}
+}
+
 func (p *GeminiProvider) BuildAPIPayload(opts Options) (map[string]interface{}, error) {
-	// Build contents with a single user turn.
-	contents := []map[string]interface{}{
-		{
-			"parts": []map[string]interface{}{
-				{"text": opts.Message},
-			},
-		},
+	// System turns become part of systemInstruction.
+	opts = foldSystemTurns(opts)
+
+	// Build contents from prior turns (assistant maps to Gemini's "model"
+	// role) followed by the user turn.
+	contents := make([]map[string]interface{}, 0, len(opts.History)+1)
+	for i, t := range opts.History {
+		parts := geminiParts(t)
+		if t.hasAttachments() {
+			var err error
+			if parts, err = geminiUserParts(t.Content, t.Images, t.Files); err != nil {
+				return nil, err
+			}
+		}
+		// Results of parallel calls must share a single content.
+		if n := len(contents); t.Role == RoleTool && i > 0 && opts.History[i-1].Role == RoleTool {
+			contents[n-1]["parts"] = append(contents[n-1]["parts"].([]map[string]interface{}), parts...)
+			continue
+		}
+		contents = append(contents, map[string]interface{}{"role": geminiRole(t.Role), "parts": parts})
+	}
+	parts, err := geminiUserParts(opts.Message, opts.Images, opts.Files)
+	if err != nil {
+		return nil, err
+	}
+	if !userTurnOmitted(opts) {
+		contents = append(contents, map[string]interface{}{
+			"role":  "user",
+			"parts": parts,
+		})
 	}
 
 	payload := map[string]interface{}{
@@ -36,11 +74,19 @@ func (p *GeminiProvider) BuildAPIPayload(opts Options) (map[string]interface{},
 		"contents": contents,
 	}
 
+	// Gemini rejects JSON mode combined with function calling, so with tools
+	// the output schema is requested through the system instruction instead.
+	jsonMode := len(opts.Tools) == 0
+	instructions := opts.Instructions
+	if !jsonMode {
+		instructions = strictJSONSystemFor(opts)
+	}
+
 	// Optional system instruction
-	if strings.TrimSpace(opts.Instructions) != "" {
+	if strings.TrimSpace(instructions) != "" {
 		payload["systemInstruction"] = map[string]interface{}{
 			"parts": []map[string]interface{}{
-				{"text": opts.Instructions},
+				{"text": instructions},
 			},
 		}
 	}
@@ -52,64 +98,281 @@ func (p *GeminiProvider) BuildAPIPayload(opts Options) (map[string]interface{},
 		genCfg["maxOutputTokens"] = opts.MaxTokens
 	}
 
-	// If properties are provided (via --format), request JSON output.
-	if len(opts.Properties) > 0 {
+	// If a schema or properties are provided (via --schema/--format), request JSON output.
+	if jsonMode && opts.Schema != nil {
+		schema, err := convertGeminiJSONSchema(opts.Schema, opts.Schema, nil)
+		if err != nil {
+			return nil, err
+		}
+		genCfg["responseMimeType"] = "application/json"
+		genCfg["responseSchema"] = schema
+	} else if jsonMode && len(opts.Properties) > 0 {
 		genCfg["responseMimeType"] = "application/json"
-		genCfg["responseSchema"] = buildGeminiObjectSchema(opts.Properties)
+		genCfg["responseSchema"] = buildGeminiObjectSchema(opts.Properties, opts.Required)
 	}
 
 	if len(genCfg) > 0 {
 		payload["generationConfig"] = genCfg
 	}
 
+	if len(opts.Tools) > 0 {
+		decls := make([]map[string]interface{}, 0, len(opts.Tools))
+		for _, t := range opts.Tools {
+			decl := map[string]interface{}{"name": t.Name}
+			if t.Description != "" {
+				decl["description"] = t.Description
+			}
+			// Gemini rejects empty OBJECT schemas; omit parameters instead.
+			if t.Schema != nil {
+				params, err := convertGeminiJSONSchema(t.Schema, t.Schema, nil)
+				if err != nil {
+					return nil, fmt.Errorf("tool %s: %w", t.Name, err)
+				}
+				if props, _ := params["properties"].(map[string]interface{}); len(props) > 0 {
+					decl["parameters"] = params
+				}
+			} else if len(t.Properties) > 0 {
+				decl["parameters"] = buildGeminiObjectSchema(t.Properties, t.Required)
+			}
+			decls = append(decls, decl)
+		}
+		payload["tools"] = []map[string]interface{}{{"functionDeclarations": decls}}
+
+		callCfg := map[string]interface{}{}
+		switch mode, name := toolChoiceMode(opts.ToolChoice); {
+		case name != "":
+			callCfg["mode"] = "ANY"
+			callCfg["allowedFunctionNames"] = []string{name}
+		case mode == ToolChoiceRequired:
+			callCfg["mode"] = "ANY"
+		default:
+			callCfg["mode"] = strings.ToUpper(mode)
+		}
+		payload["toolConfig"] = map[string]interface{}{"functionCallingConfig": callCfg}
+	}
+
 	return payload, nil
 }
 
+// geminiRole maps conversation roles onto Gemini's "user"/"model" roles.
+func geminiRole(role string) string {
+	if role == RoleAssistant {
+		return "model"
+	}
+	return "user"
+}
+
+// geminiUserParts returns the parts of a user message: its files and images,
+// then the text, which is left out when empty alongside attachments. Gemini
+// only accepts inline bytes here; remote image URLs are rejected. PDFs and
+// text files are native inline_data types.
+func geminiUserParts(text string, images []Image, files []File) ([]map[string]interface{}, error) {
+	parts := make([]map[string]interface{}, 0, len(files)+len(images)+1)
+	for _, f := range files {
+		parts = append(parts, map[string]interface{}{
+			"inline_data": map[string]interface{}{
+				"mime_type": f.MIMEType,
+				"data":      base64.StdEncoding.EncodeToString(f.Data),
+			},
+		})
+	}
+	for _, img := range images {
+		if img.URL != "" {
+			return nil, fmt.Errorf("gemini: image URLs are not supported; pass a local file: %s", img.URL)
+		}
+		parts = append(parts, map[string]interface{}{
+			"inline_data": map[string]interface{}{
+				"mime_type": img.MIMEType,
+				"data":      base64.StdEncoding.EncodeToString(img.Data),
+			},
+		})
+	}
+	if text != "" || len(parts) == 0 {
+		parts = append(parts, map[string]interface{}{"text": text})
+	}
+	return parts, nil
+}
+
+// geminiParts converts a turn to content parts. Tool calls become
+// functionCall parts and tool results functionResponse parts, whose response
+// is the tool output when it is a JSON object and {"output": text} otherwise.
+func geminiParts(t Turn) []map[string]interface{} {
+	if t.Role == RoleTool {
+		var response map[string]interface{}
+		if err := json.Unmarshal([]byte(t.Content), &response); err != nil || response == nil {
+			response = map[string]interface{}{"output": t.Content}
+		}
+		return []map[string]interface{}{{
+			"functionResponse": map[string]interface{}{"name": t.ToolName, "response": response},
+		}}
+	}
+	parts := make([]map[string]interface{}, 0, len(t.ToolCalls)+1)
+	if t.Content != "" || len(t.ToolCalls) == 0 {
+		parts = append(parts, map[string]interface{}{"text": t.Content})
+	}
+	for _, c := range t.ToolCalls {
+		args := c.Arguments
+		if args == nil {
+			args = map[string]interface{}{}
+		}
+		parts = append(parts, map[string]interface{}{
+			"functionCall": map[string]interface{}{"name": c.Name, "args": args},
+		})
+	}
+	return parts
+}
+
 // buildGeminiObjectSchema converts our shorthand properties map into
-// Gemini's simplified schema representation for JSON mode.
-func buildGeminiObjectSchema(properties map[string]interface{}) map[string]interface{} {
-	// Collect required keys (all provided properties are required)
-	required := make([]string, 0, len(properties))
+// Gemini's simplified schema representation for JSON mode. A nil required
+// marks every property as required.
+func buildGeminiObjectSchema(properties map[string]interface{}, required []string) map[string]interface{} {
+	isRequired := requiredSet(properties, required)
+	req := make([]string, 0, len(properties))
 	convProps := make(map[string]interface{}, len(properties))
 	for k, v := range properties {
-		required = append(required, k)
-		// v is a map with keys like type, items
+		if isRequired[k] {
+			req = append(req, k)
+		}
+		// v is a map with keys like type, items, properties
 		if m, ok := v.(map[string]interface{}); ok {
 			convProps[k] = convertGeminiSchemaForProperty(m)
 		}
 	}
+	sort.Strings(req)
 	return map[string]interface{}{
 		"type":       "OBJECT",
 		"properties": convProps,
-		"required":   required,
+		"required":   req,
 	}
 }
 
 func convertGeminiSchemaForProperty(m map[string]interface{}) map[string]interface{} {
+	out := convertGeminiSchemaType(m)
+	if nullable, _ := m["nullable"].(bool); nullable {
+		out["nullable"] = true
+	}
+	if d, ok := m["description"].(string); ok && d != "" {
+		out["description"] = d
+	}
+	return out
+}
+
+func convertGeminiSchemaType(m map[string]interface{}) map[string]interface{} {
 	t, _ := m["type"].(string)
 	switch strings.ToLower(t) {
 	case "array":
-		// Items element type
-		itemType := ""
-		if rawItems, ok := m["items"].(map[string]interface{}); ok {
-			if it, ok := rawItems["type"].(string); ok {
-				itemType = it
-			}
-		}
 		out := map[string]interface{}{
 			"type": "ARRAY",
 		}
-		if itemType != "" {
-			out["items"] = map[string]interface{}{
-				"type": toGeminiType(itemType),
+		if rawItems, ok := m["items"].(map[string]interface{}); ok {
+			if _, ok := rawItems["type"].(string); ok {
+				out["items"] = convertGeminiSchemaForProperty(rawItems)
 			}
 		}
 		return out
+	case "object":
+		nested, _ := m["properties"].(map[string]interface{})
+		return buildGeminiObjectSchema(nested, schemaRequired(m))
 	default:
-		return map[string]interface{}{
+		out := map[string]interface{}{
 			"type": toGeminiType(t),
 		}
+		if enum, ok := m["enum"]; ok {
+			out["format"] = "enum"
+			out["enum"] = enum
+		}
+		return out
+	}
+}
+
+// geminiSchemaKeywords lists the JSON Schema keywords that map onto Gemini's
+// OpenAPI-style Schema object. $ref/$defs are inlined during conversion.
+var geminiSchemaKeywords = map[string]bool{
+	"type": true, "format": true, "title": true, "description": true, "nullable": true,
+	"enum": true, "properties": true, "required": true, "items": true, "anyOf": true,
+	"minItems": true, "maxItems": true, "minimum": true, "maximum": true,
+	"minLength": true, "maxLength": true, "pattern": true, "minProperties": true,
+	"maxProperties": true, "propertyOrdering": true, "default": true,
+	"$ref": true, "$defs": true, "definitions": true,
+}
+
+// UnsupportedSchemaKeywords implements SchemaKeywordReporter.
+func (p *GeminiProvider) UnsupportedSchemaKeywords(schema map[string]interface{}) []string {
+	return collectUnsupportedKeywords(schema, geminiSchemaKeywords)
+}
+
+// convertGeminiJSONSchema translates a standard JSON Schema node into Gemini's
+// OpenAPI subset: local $refs are inlined, ["type","null"] unions become
+// nullable, types are uppercased and unsupported keywords are dropped.
+func convertGeminiJSONSchema(root, node map[string]interface{}, refStack []string) (map[string]interface{}, error) {
+	if ref, ok := node["$ref"].(string); ok {
+		for _, seen := range refStack {
+			if seen == ref {
+				return nil, fmt.Errorf("gemini: recursive schema reference %s is not supported", ref)
+			}
+		}
+		def, ok := resolveSchemaRef(root, ref)
+		if !ok {
+			return nil, fmt.Errorf("gemini: unresolved schema reference %s", ref)
+		}
+		return convertGeminiJSONSchema(root, def, append(refStack, ref))
+	}
+
+	out := map[string]interface{}{}
+	t, nullable := schemaTypeAndNullable(node)
+	if t != "" {
+		out["type"] = toGeminiType(t)
+	}
+	if nullable {
+		out["nullable"] = true
+	}
+	for k, v := range node {
+		switch k {
+		case "type", "nullable", "$ref", "$defs", "definitions", "properties", "items", "anyOf":
+			// handled separately
+		default:
+			if geminiSchemaKeywords[k] {
+				out[k] = v
+			}
+		}
 	}
+	if _, ok := out["enum"]; ok {
+		if _, hasFormat := out["format"]; !hasFormat {
+			out["format"] = "enum"
+		}
+	}
+	if props, ok := node["properties"].(map[string]interface{}); ok {
+		conv := make(map[string]interface{}, len(props))
+		for k, v := range props {
+			child, _ := v.(map[string]interface{})
+			c, err := convertGeminiJSONSchema(root, child, refStack)
+			if err != nil {
+				return nil, err
+			}
+			conv[k] = c
+		}
+		out["properties"] = conv
+	}
+	if items, ok := node["items"].(map[string]interface{}); ok {
+		c, err := convertGeminiJSONSchema(root, items, refStack)
+		if err != nil {
+			return nil, err
+		}
+		out["items"] = c
+	}
+	if list, ok := node["anyOf"].([]interface{}); ok {
+		conv := make([]interface{}, 0, len(list))
+		for _, v := range list {
+			child, _ := v.(map[string]interface{})
+			c, err := convertGeminiJSONSchema(root, child, refStack)
+			if err != nil {
+				return nil, err
+			}
+			conv = append(conv, c)
+		}
+		out["anyOf"] = conv
+	}
+	return out, nil
 }
 
 func toGeminiType(t string) string {
@@ -135,16 +398,24 @@ func toGeminiType(t string) string {
 	}
 }
 
-func (p *GeminiProvider) BuildAPIRequest(payload map[string]interface{}, baseURL string, reqOpts RequestOptions) (*http.Request, error) {
-	// Extract model for URL path, and remove it from the body payload.
+func (p *GeminiProvider) BuildAPIRequest(ctx context.Context, payload map[string]interface{}, baseURL string, reqOpts RequestOptions) (*http.Request, error) {
+	// Extract model (and the streaming marker) for the URL and leave them out
+	// of the body. payload itself is not modified, so it can be rebuilt into
+	// a new request on retry.
 	model, _ := payload["model"].(string)
-	delete(payload, "model")
+	stream, _ := payload["stream"].(bool)
 
 	if strings.TrimSpace(model) == "" {
 		return nil, fmt.Errorf("gemini: model is required")
 	}
 
-	body, err := json.Marshal(payload)
+	fields := make(map[string]interface{}, len(payload))
+	for k, v := range payload {
+		if k != "model" && k != "stream" {
+			fields[k] = v
+		}
+	}
+	body, err := json.Marshal(fields)
 	if err != nil {
 		return nil, fmt.Errorf("failed to encode payload: %w", err)
 	}
@@ -154,7 +425,12 @@ func (p *GeminiProvider) BuildAPIRequest(payload map[string]interface{}, baseURL
 	}
 
 	// Build URL: {base}/v1beta/models/{model}:generateContent?key=API_KEY
-	u, err := url.Parse(strings.TrimRight(baseURL, "/") + "/v1beta/models/" + url.PathEscape(model) + ":generateContent")
+	// (or :streamGenerateContent?alt=sse&key=API_KEY when streaming)
+	method := ":generateContent"
+	if stream {
+		method = ":streamGenerateContent"
+	}
+	u, err := url.Parse(strings.TrimRight(baseURL, "/") + "/v1beta/models/" + url.PathEscape(model) + method)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build URL: %w", err)
 	}
@@ -168,15 +444,21 @@ func (p *GeminiProvider) BuildAPIRequest(payload map[string]interface{}, baseURL
 	}
 
 	q := u.Query()
+	if stream {
+		q.Set("alt", "sse")
+	}
 	q.Set("key", apiKey)
 	u.RawQuery = q.Encode()
 
-	req, err := http.NewRequest("POST", u.String(), bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", u.String(), bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
 
 	for k, v := range reqOpts.ExtraHeaders {
 		if k == "" || v == "" {
@@ -188,30 +470,177 @@ func (p *GeminiProvider) BuildAPIRequest(payload map[string]interface{}, baseURL
 	return req, nil
 }
 
-func (p *GeminiProvider) ParseAPIResponse(respBody []byte) (string, error) {
+func (p *GeminiProvider) ParseAPIResponse(respBody []byte) (Response, error) {
 	// Extract aggregated text across candidate parts.
+	var apiResp geminiResponse
+	if err := json.Unmarshal(respBody, &apiResp); err != nil {
+		return Response{}, fmt.Errorf("failed to parse response: %v", err)
+	}
+
+	var b strings.Builder
+	if len(apiResp.Candidates) > 0 {
+		cand := apiResp.Candidates[0]
+		for _, part := range cand.Content.Parts {
+			if part.Text != "" {
+				b.WriteString(part.Text)
+			}
+		}
+	}
+	out := Response{Text: b.String()}
+	reason := apiResp.fill(&out)
+	return out, finishError("gemini", out, reason, "")
+}
+
+// geminiResponse is a GenerateContent response, or one streamed chunk of it.
+type geminiResponse struct {
+	ResponseID   string `json:"responseId"`
+	ModelVersion string `json:"modelVersion"`
+	Candidates   []struct {
+		Content struct {
+			Parts []struct {
+				Text         string          `json:"text"`
+				FunctionCall json.RawMessage `json:"functionCall"`
+			} `json:"parts"`
+		} `json:"content"`
+		FinishReason string `json:"finishReason"`
+	} `json:"candidates"`
+	// PromptFeedback is set when the prompt itself was blocked.
+	PromptFeedback *struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
+	UsageMetadata *struct {
+		PromptTokenCount        int `json:"promptTokenCount"`
+		CandidatesTokenCount    int `json:"candidatesTokenCount"`
+		CachedContentTokenCount int `json:"cachedContentTokenCount"`
+		ThoughtsTokenCount      int `json:"thoughtsTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+// fill copies the metadata r carries into out, keeping what earlier stream
+// chunks set when r lacks it, and returns Gemini's raw finish or block
+// reason ("" if r has none). Gemini reports STOP for function calls, so a
+// functionCall part makes the finish reason FinishToolCalls.
+func (r geminiResponse) fill(out *Response) string {
+	out.ID = ifEmptyString(r.ResponseID, out.ID)
+	out.Model = ifEmptyString(r.ModelVersion, out.Model)
+	if u := r.UsageMetadata; u != nil {
+		// candidatesTokenCount excludes thinking tokens.
+		out.Usage = Usage{
+			InputTokens:     u.PromptTokenCount,
+			OutputTokens:    u.CandidatesTokenCount + u.ThoughtsTokenCount,
+			CachedTokens:    u.CachedContentTokenCount,
+			ReasoningTokens: u.ThoughtsTokenCount,
+		}
+	}
+	if r.PromptFeedback != nil && r.PromptFeedback.BlockReason != "" {
+		out.FinishReason = FinishContentFilter
+		return r.PromptFeedback.BlockReason
+	}
+	if len(r.Candidates) == 0 {
+		return ""
+	}
+	cand := r.Candidates[0]
+	for _, part := range cand.Content.Parts {
+		if len(part.FunctionCall) > 0 {
+			out.FinishReason = FinishToolCalls
+			return cand.FinishReason
+		}
+	}
+	if cand.FinishReason != "" {
+		out.FinishReason = normalizeFinishReason(cand.FinishReason, geminiFinishReasons)
+	}
+	return cand.FinishReason
+}
+
+// geminiFinishReasons maps Gemini finish reasons onto the normalized ones.
+var geminiFinishReasons = map[string]string{
+	"STOP":               FinishStop,
+	"MAX_TOKENS":         FinishLength,
+	"SAFETY":             FinishContentFilter,
+	"RECITATION":         FinishContentFilter,
+	"BLOCKLIST":          FinishContentFilter,
+	"PROHIBITED_CONTENT": FinishContentFilter,
+	"SPII":               FinishContentFilter,
+	"IMAGE_SAFETY":       FinishContentFilter,
+}
+
+// ParseToolCalls implements ToolCallingProvider for functionCall parts.
+func (p *GeminiProvider) ParseToolCalls(respBody []byte) ([]ToolCall, error) {
 	var apiResp struct {
 		Candidates []struct {
 			Content struct {
 				Parts []struct {
-					Text string `json:"text"`
+					FunctionCall *struct {
+						ID   string                 `json:"id"`
+						Name string                 `json:"name"`
+						Args map[string]interface{} `json:"args"`
+					} `json:"functionCall"`
 				} `json:"parts"`
 			} `json:"content"`
 		} `json:"candidates"`
 	}
-
 	if err := json.Unmarshal(respBody, &apiResp); err != nil {
-		return "", fmt.Errorf("failed to parse response: %v", err)
+		return nil, fmt.Errorf("failed to parse response: %v", err)
 	}
+	if len(apiResp.Candidates) == 0 {
+		return nil, nil
+	}
+	var calls []ToolCall
+	for _, part := range apiResp.Candidates[0].Content.Parts {
+		if fc := part.FunctionCall; fc != nil {
+			args := fc.Args
+			if args == nil {
+				args = map[string]interface{}{}
+			}
+			calls = append(calls, ToolCall{ID: fc.ID, Name: fc.Name, Arguments: args})
+		}
+	}
+	return calls, nil
+}
 
-	var b strings.Builder
-	if len(apiResp.Candidates) > 0 {
-		cand := apiResp.Candidates[0]
-		for _, part := range cand.Content.Parts {
-			if part.Text != "" {
-				b.WriteString(part.Text)
+// EnableStreaming implements StreamingProvider. The marker is stripped from
+// the body by BuildAPIRequest, which switches to streamGenerateContent.
+func (p *GeminiProvider) EnableStreaming(payload map[string]interface{}) {
+	payload["stream"] = true
+}
+
+// ParseAPIStream implements StreamingProvider for streamGenerateContent
+// (alt=sse), where every event is a partial GenerateContentResponse.
+func (p *GeminiProvider) ParseAPIStream(body io.Reader, onText func(string)) (Response, error) {
+	var (
+		b      strings.Builder
+		out    Response
+		reason string
+	)
+	err := readSSE(body, func(_, data string) error {
+		var chunk struct {
+			geminiResponse
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("failed to parse stream chunk: %v", err)
+		}
+		if chunk.Error != nil {
+			return fmt.Errorf("gemini: stream error: %s", chunk.Error.Message)
+		}
+		if r := chunk.fill(&out); r != "" {
+			reason = r
+		}
+		if len(chunk.Candidates) > 0 {
+			for _, part := range chunk.Candidates[0].Content.Parts {
+				if part.Text != "" {
+					b.WriteString(part.Text)
+					onText(part.Text)
+				}
 			}
 		}
+		return nil
+	})
+	out.Text = b.String()
+	if err != nil {
+		return out, err
 	}
-	return b.String(), nil
+	return out, finishError("gemini", out, reason, "")
 }
@@ -1,10 +1,12 @@
 package provider
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
 	"net/url"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -44,8 +46,8 @@ func TestGeminiProvider_ParseAPIResponse(t *testing.T) {
 			if (err != nil) != tt.wantErr {
 				t.Fatalf("error=%v, wantErr=%v", err, tt.wantErr)
 			}
-			if got != tt.want {
-				t.Fatalf("got %q, want %q", got, tt.want)
+			if got.Text != tt.want {
+				t.Fatalf("got %q, want %q", got.Text, tt.want)
 			}
 		})
 	}
@@ -187,7 +189,7 @@ func TestGeminiProvider_BuildAPIRequest_DefaultsAndHeaders(t *testing.T) {
 		"contents": []map[string]interface{}{},
 	}
 
-	req, err := p.BuildAPIRequest(payload, "", RequestOptions{APIKey: "gk-test"})
+	req, err := p.BuildAPIRequest(context.Background(), payload, "", RequestOptions{APIKey: "gk-test"})
 	if err != nil {
 		t.Fatalf("unexpected err: %v", err)
 	}
@@ -209,7 +211,7 @@ func TestGeminiProvider_BuildAPIRequest_DefaultsAndHeaders(t *testing.T) {
 		t.Fatalf("headers mismatch")
 	}
 
-	// Body must not contain the model field (it is removed before send)
+	// Body must not contain the model field (it goes in the URL)
 	b, _ := io.ReadAll(req.Body)
 	var body map[string]interface{}
 	if err := json.Unmarshal(b, &body); err != nil {
@@ -218,4 +220,173 @@ func TestGeminiProvider_BuildAPIRequest_DefaultsAndHeaders(t *testing.T) {
 	if _, exists := body["model"]; exists {
 		t.Fatalf("body should not contain model field")
 	}
+	// The payload itself is left intact so a retry can rebuild the request.
+	if payload["model"] != "gemini-2.0-flash" {
+		t.Fatalf("payload was modified: %v", payload)
+	}
+}
+
+func TestGeminiProvider_BuildAPIPayload_NestedSchema(t *testing.T) {
+	p := &GeminiProvider{}
+	opts := Options{
+		Model:   "gemini-2.0-flash",
+		Message: "Hello",
+		Properties: map[string]interface{}{
+			"items": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"sku": map[string]interface{}{"type": "string"},
+						"qty": map[string]interface{}{"type": "integer"},
+					},
+					"required": []string{"qty", "sku"},
+				},
+			},
+		},
+	}
+	payload, err := p.BuildAPIPayload(opts)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	gen := payload["generationConfig"].(map[string]interface{})
+	want := map[string]interface{}{
+		"type": "OBJECT",
+		"properties": map[string]interface{}{
+			"items": map[string]interface{}{
+				"type": "ARRAY",
+				"items": map[string]interface{}{
+					"type": "OBJECT",
+					"properties": map[string]interface{}{
+						"sku": map[string]interface{}{"type": "STRING"},
+						"qty": map[string]interface{}{"type": "INTEGER"},
+					},
+					"required": []string{"qty", "sku"},
+				},
+			},
+		},
+		"required": []string{"items"},
+	}
+	if !reflect.DeepEqual(gen["responseSchema"], want) {
+		t.Fatalf("responseSchema mismatch:\ngot=%v\nwant=%v", gen["responseSchema"], want)
+	}
+}
+
+func TestGeminiProvider_BuildAPIPayload_EnumSchema(t *testing.T) {
+	p := &GeminiProvider{}
+	payload, err := p.BuildAPIPayload(Options{
+		Model:   "gemini-2.0-flash",
+		Message: "Hello",
+		Properties: map[string]interface{}{
+			"sentiment": map[string]interface{}{"type": "string", "enum": []string{"positive", "negative"}, "description": "overall tone"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	schema := payload["generationConfig"].(map[string]interface{})["responseSchema"].(map[string]interface{})
+	got := schema["properties"].(map[string]interface{})["sentiment"]
+	want := map[string]interface{}{
+		"type":        "STRING",
+		"format":      "enum",
+		"enum":        []string{"positive", "negative"},
+		"description": "overall tone",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("sentiment schema mismatch: got=%v want=%v", got, want)
+	}
+}
+
+func TestGeminiProvider_BuildAPIPayload_OptionalAndNullable(t *testing.T) {
+	p := &GeminiProvider{}
+	payload, err := p.BuildAPIPayload(Options{
+		Model:   "gemini-2.0-flash",
+		Message: "Hello",
+		Properties: map[string]interface{}{
+			"name": map[string]interface{}{"type": "string"},
+			"nick": map[string]interface{}{"type": "string"},
+			"note": map[string]interface{}{"type": "string", "nullable": true},
+		},
+		Required: []string{"name", "note"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	schema := payload["generationConfig"].(map[string]interface{})["responseSchema"].(map[string]interface{})
+	if !reflect.DeepEqual(schema["required"], []string{"name", "note"}) {
+		t.Fatalf("required mismatch: %v", schema["required"])
+	}
+	note := schema["properties"].(map[string]interface{})["note"]
+	if !reflect.DeepEqual(note, map[string]interface{}{"type": "STRING", "nullable": true}) {
+		t.Fatalf("note schema mismatch: %v", note)
+	}
+}
+
+func TestGeminiProvider_BuildAPIPayload_History(t *testing.T) {
+	p := &GeminiProvider{}
+	payload, err := p.BuildAPIPayload(Options{
+		Model:   "gemini-2.0-flash",
+		Message: "fix it",
+		History: []Turn{
+			{Role: RoleUser, Content: "Hello"},
+			{Role: RoleAssistant, Content: "{bad"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	contents := payload["contents"].([]map[string]interface{})
+	if len(contents) != 3 {
+		t.Fatalf("expected 3 contents, got %d", len(contents))
+	}
+	for i, r := range []string{"user", "model", "user"} {
+		if contents[i]["role"] != r {
+			t.Fatalf("content %d role = %v, want %s", i, contents[i]["role"], r)
+		}
+	}
+}
+
+func TestGeminiProvider_BuildAPIRequest_Streaming(t *testing.T) {
+	p := &GeminiProvider{}
+	payload := map[string]interface{}{
+		"model":    "gemini-2.0-flash",
+		"contents": []map[string]interface{}{},
+	}
+	p.EnableStreaming(payload)
+	req, err := p.BuildAPIRequest(context.Background(), payload, "", RequestOptions{APIKey: "gk-test"})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if !strings.HasSuffix(req.URL.Path, "/v1beta/models/gemini-2.0-flash:streamGenerateContent") {
+		t.Fatalf("url path mismatch: %s", req.URL.Path)
+	}
+	if req.URL.Query().Get("alt") != "sse" {
+		t.Fatalf("alt=sse missing: %s", req.URL.RawQuery)
+	}
+	if req.Header.Get("Accept") != "text/event-stream" {
+		t.Fatalf("accept header mismatch: %s", req.Header.Get("Accept"))
+	}
+	b, _ := io.ReadAll(req.Body)
+	if strings.Contains(string(b), "stream") {
+		t.Fatalf("body should not contain the stream marker: %s", b)
+	}
+}
+
+func TestGeminiProvider_BuildAPIPayload_SystemTurns(t *testing.T) {
+	p := &GeminiProvider{}
+	payload, err := p.BuildAPIPayload(Options{
+		Model:   "gemini-2.0-flash",
+		Message: "Hi",
+		History: []Turn{{Role: RoleSystem, Content: "be brief"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	sys := payload["systemInstruction"].(map[string]interface{})["parts"].([]map[string]interface{})
+	if sys[0]["text"] != "be brief" {
+		t.Fatalf("systemInstruction mismatch: %v", sys)
+	}
+	if contents := payload["contents"].([]map[string]interface{}); len(contents) != 1 {
+		t.Fatalf("system turns must not appear in contents: %v", contents)
+	}
 }
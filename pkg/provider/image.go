@@ -0,0 +1,12 @@
+package provider
+
+import "encoding/base64"
+
+// urlOrDataURL returns the remote URL of img, or its bytes as a base64
+// data URL for APIs that accept either form in one field.
+func (img Image) urlOrDataURL() string {
+	if img.URL != "" {
+		return img.URL
+	}
+	return "data:" + img.MIMEType + ";base64," + base64.StdEncoding.EncodeToString(img.Data)
+}
@@ -0,0 +1,117 @@
+package provider
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var (
+	testPNG      = Image{MIMEType: "image/png", Data: []byte("png")}
+	testImageURL = Image{MIMEType: "image/jpeg", URL: "https://example.com/a.jpg"}
+)
+
+func TestOpenAIProvider_BuildAPIPayload_Images(t *testing.T) {
+	p := &OpenAIProvider{}
+	payload, err := p.BuildAPIPayload(Options{Model: "gpt-5-nano", Message: "read", Images: []Image{testPNG, testImageURL}})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	input := payload["input"].([]map[string]interface{})
+	want := []map[string]interface{}{
+		{"type": "input_text", "text": "read"},
+		{"type": "input_image", "image_url": "data:image/png;base64,cG5n"},
+		{"type": "input_image", "image_url": "https://example.com/a.jpg"},
+	}
+	if len(input) != 1 || !reflect.DeepEqual(input[0]["content"], want) {
+		t.Fatalf("input mismatch: %v", input)
+	}
+}
+
+func TestOpenAICompatProvider_BuildAPIPayload_Images(t *testing.T) {
+	p := &OpenAICompatProvider{}
+	payload, err := p.BuildAPIPayload(Options{Model: "gpt-4o-mini", Message: "read", Images: []Image{testPNG}})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	msgs := payload["messages"].([]map[string]interface{})
+	want := []map[string]interface{}{
+		{"type": "text", "text": "read"},
+		{"type": "image_url", "image_url": map[string]interface{}{"url": "data:image/png;base64,cG5n"}},
+	}
+	if !reflect.DeepEqual(msgs[len(msgs)-1]["content"], want) {
+		t.Fatalf("content mismatch: %v", msgs[len(msgs)-1]["content"])
+	}
+}
+
+func TestAnthropicProvider_BuildAPIPayload_Images(t *testing.T) {
+	p := &AnthropicProvider{}
+	payload, err := p.BuildAPIPayload(Options{
+		Model:     "claude-3-5-haiku-latest",
+		MaxTokens: 1024,
+		Message:   "read",
+		History:   []Turn{{Role: RoleUser, Content: "first"}, {Role: RoleAssistant, Content: "ok"}},
+		Images:    []Image{testPNG, testImageURL},
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	msgs := payload["messages"].([]map[string]interface{})
+	if msgs[0]["content"] != "first" {
+		t.Fatalf("history turns must stay text: %v", msgs[0])
+	}
+	want := []map[string]interface{}{
+		{"type": "image", "source": map[string]interface{}{"type": "base64", "media_type": "image/png", "data": "cG5n"}},
+		{"type": "image", "source": map[string]interface{}{"type": "url", "url": "https://example.com/a.jpg"}},
+		{"type": "text", "text": "read"},
+	}
+	if !reflect.DeepEqual(msgs[2]["content"], want) {
+		t.Fatalf("content mismatch: %v", msgs[2]["content"])
+	}
+}
+
+func TestGeminiProvider_BuildAPIPayload_Images(t *testing.T) {
+	p := &GeminiProvider{}
+	payload, err := p.BuildAPIPayload(Options{Model: "gemini-2.0-flash", Message: "read", Images: []Image{testPNG}})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	contents := payload["contents"].([]map[string]interface{})
+	want := []map[string]interface{}{
+		{"inline_data": map[string]interface{}{"mime_type": "image/png", "data": "cG5n"}},
+		{"text": "read"},
+	}
+	if !reflect.DeepEqual(contents[0]["parts"], want) {
+		t.Fatalf("parts mismatch: %v", contents[0]["parts"])
+	}
+
+	if _, err := p.BuildAPIPayload(Options{Model: "gemini-2.0-flash", Message: "read", Images: []Image{testImageURL}}); err == nil {
+		t.Fatalf("expected error for image URL")
+	}
+}
+
+func TestBuildAPIPayload_HistoryAttachments(t *testing.T) {
+	// A replayed user turn keeps its image and, with no text, sends no
+	// empty text part.
+	history := []Turn{
+		{Role: RoleUser, Images: []Image{testPNG}},
+		{Role: RoleAssistant, Content: "not json"},
+	}
+	for _, name := range Names() {
+		p, _ := New(name)
+		payload, err := p.BuildAPIPayload(Options{Model: "m", MaxTokens: 10, Message: "fix it", History: history})
+		if err != nil {
+			t.Fatalf("%s: unexpected err: %v", name, err)
+		}
+		b, _ := json.Marshal(payload)
+		body := string(b)
+		if strings.Count(body, "cG5n") != 1 {
+			t.Errorf("%s: the image should be sent once with the history turn: %s", name, body)
+		}
+		// Ollama's message content is a plain string next to the images.
+		if strings.Contains(body, `"text":""`) || (name != "ollama" && strings.Contains(body, `"content":""`)) {
+			t.Errorf("%s: empty text sent: %s", name, body)
+		}
+	}
+}
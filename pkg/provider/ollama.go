@@ -0,0 +1,326 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// OllamaProvider implements Provider for the native Ollama chat API
+// (/api/chat), which constrains output to a JSON Schema through its format
+// field.
+type OllamaProvider struct{}
+
+func (p *OllamaProvider) DefaultOptions() Options {
+	return Options{
+		Model: "llama3.2",
+	}
+}
+
+func (p *OllamaProvider) Capabilities() Capabilities {
+	return Capabilities{
+		NativeSchema: true,
+		Streaming:    true,
+		Tools:        true,
+		Vision:       true,
+		ModelOptions: true,
+		KeepAlive:    true,
+		SystemPrompt: true,
+	}
+}
+
+func (p *OllamaProvider) BuildAPIPayload(opts Options) (map[string]interface{}, error) {
+	messages := make([]map[string]interface{}, 0, len(opts.History)+2)
+	if sys := strictJSONSystemFor(opts); strings.TrimSpace(sys) != "" {
+		messages = append(messages, map[string]interface{}{
+			"role":    "system",
+			"content": sys,
+		})
+	}
+	for _, t := range opts.History {
+		if t.hasAttachments() {
+			msg, err := ollamaUserMessage(t.Content, t.Images, t.Files)
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, msg)
+			continue
+		}
+		messages = append(messages, ollamaMessage(t))
+	}
+
+	if !userTurnOmitted(opts) {
+		msg, err := ollamaUserMessage(opts.Message, opts.Images, opts.Files)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+
+	payload := map[string]interface{}{
+		"model":    opts.Model,
+		"messages": messages,
+		// Ollama streams by default; streaming is opted into via
+		// EnableStreaming.
+		"stream": false,
+	}
+
+	// Constrain the output to the schema. Declared tools must stay free for
+	// the model to call, so alongside them only the system hint is used.
+	structured := len(opts.Properties) > 0 || opts.Schema != nil
+	if structured && len(opts.Tools) == 0 {
+		output := Tool{Properties: opts.Properties, Required: opts.Required, Schema: opts.Schema}
+		payload["format"] = output.jsonSchema()
+	}
+
+	options := make(map[string]interface{}, len(opts.ModelOptions)+1)
+	for k, v := range opts.ModelOptions {
+		options[k] = v
+	}
+	if _, ok := options["num_predict"]; !ok && opts.MaxTokens > 0 {
+		options["num_predict"] = opts.MaxTokens
+	}
+	if len(options) > 0 {
+		payload["options"] = options
+	}
+	if opts.KeepAlive != "" {
+		payload["keep_alive"] = opts.KeepAlive
+	}
+
+	if len(opts.Tools) > 0 {
+		mode, name := toolChoiceMode(opts.ToolChoice)
+		if mode == ToolChoiceRequired || name != "" {
+			return nil, fmt.Errorf("ollama: tool choice %q is not supported; only auto and none are", opts.ToolChoice)
+		}
+		// Ollama has no tool_choice; "none" is honored by not offering the
+		// tools.
+		if mode != ToolChoiceNone {
+			tools := make([]map[string]interface{}, 0, len(opts.Tools))
+			for _, t := range opts.Tools {
+				fn := map[string]interface{}{
+					"name":       t.Name,
+					"parameters": t.jsonSchema(),
+				}
+				if t.Description != "" {
+					fn["description"] = t.Description
+				}
+				tools = append(tools, map[string]interface{}{"type": "function", "function": fn})
+			}
+			payload["tools"] = tools
+		}
+	}
+
+	return payload, nil
+}
+
+// ollamaUserMessage returns a user message. Message content is plain text,
+// so documents are inlined after the text (PDFs are extracted locally) and
+// images go in their own field as raw base64.
+func ollamaUserMessage(text string, images []Image, files []File) (map[string]interface{}, error) {
+	var content []string
+	if text != "" || len(files) == 0 {
+		content = append(content, text)
+	}
+	for _, f := range files {
+		extracted, err := f.extractedText()
+		if err != nil {
+			return nil, fmt.Errorf("ollama: %w", err)
+		}
+		content = append(content, f.inlineText(extracted))
+	}
+	msg := map[string]interface{}{
+		"role":    "user",
+		"content": strings.Join(content, "\n\n"),
+	}
+	if len(images) > 0 {
+		encoded := make([]string, 0, len(images))
+		for _, img := range images {
+			if img.URL != "" {
+				return nil, fmt.Errorf("ollama: image URLs are not supported (%s); download the image and attach the file", img.URL)
+			}
+			encoded = append(encoded, base64.StdEncoding.EncodeToString(img.Data))
+		}
+		msg["images"] = encoded
+	}
+	return msg, nil
+}
+
+// ollamaMessage converts a turn to an Ollama chat message, including
+// assistant tool_calls and role "tool" results.
+func ollamaMessage(t Turn) map[string]interface{} {
+	if t.Role == RoleTool {
+		return map[string]interface{}{"role": "tool", "tool_name": t.ToolName, "content": t.Content}
+	}
+	msg := map[string]interface{}{"role": t.Role, "content": t.Content}
+	if len(t.ToolCalls) > 0 {
+		calls := make([]map[string]interface{}, 0, len(t.ToolCalls))
+		for _, c := range t.ToolCalls {
+			args := c.Arguments
+			if args == nil {
+				args = map[string]interface{}{}
+			}
+			calls = append(calls, map[string]interface{}{
+				"function": map[string]interface{}{"name": c.Name, "arguments": args},
+			})
+		}
+		msg["tool_calls"] = calls
+	}
+	return msg
+}
+
+func (p *OllamaProvider) BuildAPIRequest(ctx context.Context, payload map[string]interface{}, baseURL string, reqOpts RequestOptions) (*http.Request, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	if baseURL == "" {
+		baseURL = "http://localhost:11434"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimRight(baseURL, "/")+"/api/chat", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if stream, _ := payload["stream"].(bool); stream {
+		req.Header.Set("Accept", "application/x-ndjson")
+	}
+
+	// A local server needs no key; one is only sent when given (e.g., for
+	// a server behind an authenticating proxy).
+	apiKey := reqOpts.APIKey
+	if apiKey == "" {
+		apiKey = os.Getenv("OLLAMA_API_KEY")
+	}
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	for k, v := range reqOpts.ExtraHeaders {
+		if k == "" || v == "" {
+			continue
+		}
+		req.Header.Set(k, v)
+	}
+
+	return req, nil
+}
+
+// ollamaChunk is a /api/chat response: the whole reply, or one line of a
+// streamed reply where the last line has done set and carries the counts.
+type ollamaChunk struct {
+	Model   string `json:"model"`
+	Message struct {
+		Content   string `json:"content"`
+		ToolCalls []struct {
+			Function struct {
+				Name      string                 `json:"name"`
+				Arguments map[string]interface{} `json:"arguments"`
+			} `json:"function"`
+		} `json:"tool_calls"`
+	} `json:"message"`
+	Done            bool   `json:"done"`
+	DoneReason      string `json:"done_reason"`
+	PromptEvalCount int    `json:"prompt_eval_count"`
+	EvalCount       int    `json:"eval_count"`
+	Error           string `json:"error"`
+}
+
+func (c ollamaChunk) usage() Usage {
+	return Usage{InputTokens: c.PromptEvalCount, OutputTokens: c.EvalCount}
+}
+
+func (p *OllamaProvider) ParseAPIResponse(respBody []byte) (Response, error) {
+	var apiResp ollamaChunk
+	if err := json.Unmarshal(respBody, &apiResp); err != nil {
+		return Response{}, fmt.Errorf("failed to parse response: %v", err)
+	}
+	if apiResp.Error != "" {
+		return Response{}, fmt.Errorf("ollama: %s", apiResp.Error)
+	}
+	out := Response{
+		Text:         apiResp.Message.Content,
+		Model:        apiResp.Model,
+		FinishReason: normalizeFinishReason(apiResp.DoneReason, nil),
+		Usage:        apiResp.usage(),
+	}
+	// Ollama reports "stop" when the model calls tools.
+	if len(apiResp.Message.ToolCalls) > 0 {
+		out.FinishReason = FinishToolCalls
+	}
+	return out, finishError("ollama", out, apiResp.DoneReason, "")
+}
+
+// ParseToolCalls implements ToolCallingProvider for message tool_calls.
+// Ollama assigns no call IDs; results are matched by tool name.
+func (p *OllamaProvider) ParseToolCalls(respBody []byte) ([]ToolCall, error) {
+	var apiResp ollamaChunk
+	if err := json.Unmarshal(respBody, &apiResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %v", err)
+	}
+	var calls []ToolCall
+	for _, tc := range apiResp.Message.ToolCalls {
+		args := tc.Function.Arguments
+		if args == nil {
+			args = map[string]interface{}{}
+		}
+		calls = append(calls, ToolCall{Name: tc.Function.Name, Arguments: args})
+	}
+	return calls, nil
+}
+
+// EnableStreaming implements StreamingProvider.
+func (p *OllamaProvider) EnableStreaming(payload map[string]interface{}) {
+	payload["stream"] = true
+}
+
+// ParseAPIStream implements StreamingProvider for Ollama's newline-delimited
+// JSON stream.
+func (p *OllamaProvider) ParseAPIStream(body io.Reader, onText func(string)) (Response, error) {
+	var (
+		b         strings.Builder
+		out       Response
+		rawReason string
+	)
+	sc := bufio.NewScanner(body)
+	sc.Buffer(make([]byte, 0, 64*1024), maxSSELine)
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var chunk ollamaChunk
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			out.Text = b.String()
+			return out, fmt.Errorf("failed to parse stream chunk: %v", err)
+		}
+		if chunk.Error != "" {
+			out.Text = b.String()
+			return out, fmt.Errorf("ollama: stream error: %s", chunk.Error)
+		}
+		out.Model = ifEmptyString(chunk.Model, out.Model)
+		if chunk.Message.Content != "" {
+			b.WriteString(chunk.Message.Content)
+			onText(chunk.Message.Content)
+		}
+		if chunk.Done {
+			rawReason = chunk.DoneReason
+			out.FinishReason = normalizeFinishReason(chunk.DoneReason, nil)
+			out.Usage = chunk.usage()
+		}
+	}
+	out.Text = b.String()
+	if err := sc.Err(); err != nil {
+		return out, err
+	}
+	return out, finishError("ollama", out, rawReason, "")
+}
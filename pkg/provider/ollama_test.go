@@ -0,0 +1,196 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOllamaProvider_BuildAPIPayload(t *testing.T) {
+	p := &OllamaProvider{}
+	payload, err := p.BuildAPIPayload(Options{
+		Model:        "llama3.2",
+		Instructions: "be brief",
+		Message:      "Hello",
+		Images:       []Image{{MIMEType: "image/png", Data: []byte("png")}},
+		Files:        []File{{Name: "notes.txt", MIMEType: "text/plain", Data: []byte("remember")}},
+		Properties: map[string]interface{}{
+			"message": map[string]interface{}{"type": "string"},
+			"note":    map[string]interface{}{"type": "string", "nullable": true},
+		},
+		Required:     []string{"message"},
+		MaxTokens:    100,
+		ModelOptions: map[string]interface{}{"num_ctx": 8192, "temperature": 0.2},
+		KeepAlive:    "10m",
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if payload["stream"] != false || payload["keep_alive"] != "10m" {
+		t.Fatalf("stream/keep_alive mismatch: %v", payload)
+	}
+	wantOptions := map[string]interface{}{"num_ctx": 8192, "temperature": 0.2, "num_predict": 100}
+	if !reflect.DeepEqual(payload["options"], wantOptions) {
+		t.Fatalf("options mismatch: %v", payload["options"])
+	}
+	wantFormat := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"message": map[string]interface{}{"type": "string"},
+			"note":    map[string]interface{}{"type": []interface{}{"string", "null"}},
+		},
+		"required": []string{"message"},
+	}
+	if !reflect.DeepEqual(payload["format"], wantFormat) {
+		t.Fatalf("format mismatch:\n got=%v\nwant=%v", payload["format"], wantFormat)
+	}
+
+	msgs := payload["messages"].([]map[string]interface{})
+	if len(msgs) != 2 || msgs[0]["role"] != "system" || !strings.HasPrefix(msgs[0]["content"].(string), "be brief\n\nRETURN ONLY A STRICT JSON OBJECT") {
+		t.Fatalf("system message mismatch: %v", msgs)
+	}
+	user := msgs[1]
+	if user["content"] != "Hello\n\n<file name=\"notes.txt\">\nremember\n</file>" {
+		t.Fatalf("user content mismatch: %q", user["content"])
+	}
+	if !reflect.DeepEqual(user["images"], []string{"cG5n"}) {
+		t.Fatalf("images mismatch: %v", user["images"])
+	}
+
+	// An explicit num_predict wins over MaxTokens; no options means none sent.
+	payload, _ = p.BuildAPIPayload(Options{Model: "m", Message: "hi", MaxTokens: 100, ModelOptions: map[string]interface{}{"num_predict": 5}})
+	if payload["options"].(map[string]interface{})["num_predict"] != 5 {
+		t.Fatalf("num_predict should not be overridden: %v", payload["options"])
+	}
+	payload, _ = p.BuildAPIPayload(Options{Model: "m", Message: "hi"})
+	for _, k := range []string{"options", "keep_alive", "format", "tools"} {
+		if _, ok := payload[k]; ok {
+			t.Fatalf("%s should be omitted: %v", k, payload)
+		}
+	}
+
+	if _, err := p.BuildAPIPayload(Options{Model: "m", Message: "hi", Images: []Image{{URL: "https://example.com/a.png"}}}); err == nil {
+		t.Fatalf("expected an error for image URLs")
+	}
+}
+
+func TestOllamaProvider_BuildAPIPayload_Tools(t *testing.T) {
+	p := &OllamaProvider{}
+	tools := []Tool{{Name: "get_weather", Description: "Look up the weather", Properties: map[string]interface{}{"city": map[string]interface{}{"type": "string"}}}}
+	payload, err := p.BuildAPIPayload(Options{
+		Model:      "m",
+		Message:    "weather?",
+		Tools:      tools,
+		Properties: map[string]interface{}{"answer": map[string]interface{}{"type": "string"}},
+		History: []Turn{
+			{Role: RoleUser, Content: "weather in Paris?"},
+			{Role: RoleAssistant, ToolCalls: []ToolCall{{Name: "get_weather", Arguments: map[string]interface{}{"city": "Paris"}}}},
+			{Role: RoleTool, ToolName: "get_weather", Content: "sunny"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if _, ok := payload["format"]; ok {
+		t.Fatalf("format must not constrain output alongside tools")
+	}
+	fn := payload["tools"].([]map[string]interface{})[0]["function"].(map[string]interface{})
+	if fn["name"] != "get_weather" || fn["description"] != "Look up the weather" {
+		t.Fatalf("tool mismatch: %v", fn)
+	}
+	msgs := payload["messages"].([]map[string]interface{})
+	call := msgs[2]["tool_calls"].([]map[string]interface{})[0]["function"].(map[string]interface{})
+	if call["name"] != "get_weather" || call["arguments"].(map[string]interface{})["city"] != "Paris" {
+		t.Fatalf("assistant tool call mismatch: %v", msgs[2])
+	}
+	if msgs[3]["role"] != "tool" || msgs[3]["tool_name"] != "get_weather" || msgs[3]["content"] != "sunny" {
+		t.Fatalf("tool result mismatch: %v", msgs[3])
+	}
+
+	payload, _ = p.BuildAPIPayload(Options{Model: "m", Message: "hi", Tools: tools, ToolChoice: ToolChoiceNone})
+	if _, ok := payload["tools"]; ok {
+		t.Fatalf("tool choice none should not offer tools")
+	}
+	for _, choice := range []string{ToolChoiceRequired, "get_weather"} {
+		if _, err := p.BuildAPIPayload(Options{Model: "m", Message: "hi", Tools: tools, ToolChoice: choice}); err == nil {
+			t.Fatalf("expected an error for tool choice %q", choice)
+		}
+	}
+}
+
+func TestOllamaProvider_BuildAPIRequest(t *testing.T) {
+	t.Setenv("OLLAMA_API_KEY", "")
+	p := &OllamaProvider{}
+	req, err := p.BuildAPIRequest(context.Background(), map[string]interface{}{"model": "m"}, "", RequestOptions{})
+	if err != nil {
+		t.Fatalf("no API key should be needed: %v", err)
+	}
+	if req.URL.String() != "http://localhost:11434/api/chat" || req.Header.Get("Authorization") != "" {
+		t.Fatalf("request mismatch: %s %v", req.URL, req.Header)
+	}
+
+	req, err = p.BuildAPIRequest(context.Background(), map[string]interface{}{"model": "m", "stream": true}, "http://gpu-box:11434/", RequestOptions{APIKey: "k"})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if req.URL.String() != "http://gpu-box:11434/api/chat" || req.Header.Get("Authorization") != "Bearer k" || req.Header.Get("Accept") != "application/x-ndjson" {
+		t.Fatalf("request mismatch: %s %v", req.URL, req.Header)
+	}
+}
+
+func TestOllamaProvider_ParseAPIResponse(t *testing.T) {
+	p := &OllamaProvider{}
+	got, err := p.ParseAPIResponse([]byte(`{"model":"llama3.2","created_at":"2025-01-01T00:00:00Z","message":{"role":"assistant","content":"{\"a\":1}"},"done":true,"done_reason":"stop","prompt_eval_count":26,"eval_count":7}`))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	want := Response{Text: `{"a":1}`, Model: "llama3.2", FinishReason: FinishStop, Usage: Usage{InputTokens: 26, OutputTokens: 7}}
+	if got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	_, err = p.ParseAPIResponse([]byte(`{"model":"llama3.2","message":{"content":"{\"a\":"},"done":true,"done_reason":"length","eval_count":5}`))
+	if !errors.Is(err, ErrTruncated) {
+		t.Fatalf("expected truncation, got %v", err)
+	}
+
+	body := []byte(`{"model":"llama3.2","message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"get_weather","arguments":{"city":"Paris"}}}]},"done":true,"done_reason":"stop"}`)
+	got, err = p.ParseAPIResponse(body)
+	if err != nil || got.FinishReason != FinishToolCalls {
+		t.Fatalf("tool call response: %+v, %v", got, err)
+	}
+	calls, err := p.ParseToolCalls(body)
+	if err != nil || !reflect.DeepEqual(calls, []ToolCall{{Name: "get_weather", Arguments: map[string]interface{}{"city": "Paris"}}}) {
+		t.Fatalf("calls = %+v, %v", calls, err)
+	}
+
+	if _, err := p.ParseAPIResponse([]byte(`{"error":"model \"nope\" not found"}`)); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("expected the server error, got %v", err)
+	}
+}
+
+func TestOllamaProvider_ParseAPIStream(t *testing.T) {
+	p := &OllamaProvider{}
+	stream := strings.Join([]string{
+		`{"model":"llama3.2","message":{"role":"assistant","content":"Hel"},"done":false}`,
+		``,
+		`{"model":"llama3.2","message":{"role":"assistant","content":"lo"},"done":false}`,
+		`{"model":"llama3.2","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":3,"eval_count":2}`,
+	}, "\n")
+	var deltas []string
+	got, err := p.ParseAPIStream(strings.NewReader(stream), func(s string) { deltas = append(deltas, s) })
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	want := Response{Text: "Hello", Model: "llama3.2", FinishReason: FinishStop, Usage: Usage{InputTokens: 3, OutputTokens: 2}}
+	if got != want || strings.Join(deltas, "|") != "Hel|lo" {
+		t.Fatalf("got %+v (deltas %q), want %+v", got, deltas, want)
+	}
+
+	got, err = p.ParseAPIStream(strings.NewReader(`{"message":{"content":"Hi"},"done":false}`+"\n"+`{"error":"out of memory"}`), func(string) {})
+	if err == nil || !strings.Contains(err.Error(), "out of memory") || got.Text != "Hi" {
+		t.Fatalf("expected the stream error with partial text, got %+v, %v", got, err)
+	}
+}
@@ -2,10 +2,13 @@ package provider
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"sort"
 	"strings"
 )
 
@@ -18,35 +21,53 @@ func (p *OpenAIProvider) DefaultOptions() Options {
 	}
 }
 
+func (p *OpenAIProvider) Capabilities() Capabilities {
+	return Capabilities{
+		NativeSchema:     true,
+		Streaming:        true,
+		Tools:            true,
+		Vision:           true,
+		Verbosity:        true,
+		ReasoningEffort:  true,
+		SystemPrompt:     true,
+		MaxContextTokens: 400000,
+	}
+}
+
 func (p *OpenAIProvider) BuildAPIPayload(opts Options) (map[string]interface{}, error) {
 	textPayload := map[string]interface{}{
 		"verbosity": opts.Verbosity,
 	}
 
-	if len(opts.Properties) > 0 {
-		// Build JSON schema from provided properties and mark all as required
-		// Collect keys as required
-		required := make([]string, 0, len(opts.Properties))
-		for k := range opts.Properties {
-			required = append(required, k)
-		}
+	if opts.Schema != nil || len(opts.Properties) > 0 {
+		schema, strict := p.schemaFor(opts.Properties, opts.Required, opts.Schema)
 		textPayload["format"] = map[string]interface{}{
 			"type":   "json_schema",
 			"name":   "response",
-			"strict": true,
-			"schema": map[string]interface{}{
-				"type":                 "object",
-				"properties":           opts.Properties,
-				"required":             required,
-				"additionalProperties": false,
-			},
+			"strict": strict,
+			"schema": schema,
+		}
+	}
+
+	// A plain string input is enough for a single text turn; prior turns and
+	// images require the list form.
+	var input interface{} = opts.Message
+	if len(opts.History) > 0 || len(opts.Images) > 0 || len(opts.Files) > 0 {
+		items := make([]map[string]interface{}, 0, len(opts.History)+1)
+		for _, t := range opts.History {
+			items = append(items, openAIHistoryItems(t)...)
+		}
+		if userTurnOmitted(opts) {
+			input = items
+		} else {
+			input = append(items, map[string]interface{}{"role": RoleUser, "content": openAIUserContent(opts.Message, opts.Images, opts.Files)})
 		}
 	}
 
 	payload := map[string]interface{}{
 		"model":        opts.Model,
 		"instructions": opts.Instructions,
-		"input":        opts.Message,
+		"input":        input,
 		"store":        false,
 		"text":         textPayload,
 		"reasoning": map[string]interface{}{
@@ -58,10 +79,225 @@ func (p *OpenAIProvider) BuildAPIPayload(opts Options) (map[string]interface{},
 		payload["max_output_tokens"] = opts.MaxTokens
 	}
 
+	if len(opts.Tools) > 0 {
+		tools := make([]map[string]interface{}, 0, len(opts.Tools))
+		for _, t := range opts.Tools {
+			schema, strict := p.schemaFor(t.Properties, t.Required, t.Schema)
+			tool := map[string]interface{}{
+				"type":       "function",
+				"name":       t.Name,
+				"parameters": schema,
+				"strict":     strict,
+			}
+			if t.Description != "" {
+				tool["description"] = t.Description
+			}
+			tools = append(tools, tool)
+		}
+		payload["tools"] = tools
+		if mode, name := toolChoiceMode(opts.ToolChoice); name != "" {
+			payload["tool_choice"] = map[string]interface{}{"type": "function", "name": name}
+		} else {
+			payload["tool_choice"] = mode
+		}
+	}
+
 	return payload, nil
 }
 
-func (p *OpenAIProvider) BuildAPIRequest(payload map[string]interface{}, baseURL string, reqOpts RequestOptions) (*http.Request, error) {
+// openAIUserContent returns the content of a user message: the text alone,
+// or input parts when images or files are attached.
+func openAIUserContent(text string, images []Image, files []File) interface{} {
+	if len(images) == 0 && len(files) == 0 {
+		return text
+	}
+	parts := make([]map[string]interface{}, 0, len(images)+len(files)+1)
+	if text != "" {
+		parts = append(parts, map[string]interface{}{"type": "input_text", "text": text})
+	}
+	for _, img := range images {
+		parts = append(parts, map[string]interface{}{"type": "input_image", "image_url": img.urlOrDataURL()})
+	}
+	// PDFs are sent as input_file; text files are inlined.
+	for _, f := range files {
+		if f.isPDF() {
+			parts = append(parts, map[string]interface{}{"type": "input_file", "filename": f.Name, "file_data": f.dataURL()})
+			continue
+		}
+		parts = append(parts, map[string]interface{}{"type": "input_text", "text": f.inlineText(string(f.Data))})
+	}
+	return parts
+}
+
+// openAIHistoryItems converts a turn to Responses API input items. Tool calls
+// and their results are separate items rather than message content.
+func openAIHistoryItems(t Turn) []map[string]interface{} {
+	if t.Role == RoleTool {
+		return []map[string]interface{}{{"type": "function_call_output", "call_id": t.ToolCallID, "output": t.Content}}
+	}
+	if t.hasAttachments() {
+		return []map[string]interface{}{{"role": RoleUser, "content": openAIUserContent(t.Content, t.Images, t.Files)}}
+	}
+	var items []map[string]interface{}
+	if t.Content != "" || len(t.ToolCalls) == 0 {
+		items = append(items, map[string]interface{}{"role": t.Role, "content": t.Content})
+	}
+	for _, c := range t.ToolCalls {
+		items = append(items, map[string]interface{}{
+			"type":      "function_call",
+			"call_id":   c.ID,
+			"name":      c.Name,
+			"arguments": encodeToolArguments(c.Arguments),
+		})
+	}
+	return items
+}
+
+// schemaFor returns the JSON Schema for an object described either by
+// shorthand properties or by a full schema, and whether strict mode applies.
+// A full schema is used as-is; strict mode is only requested when it already
+// satisfies its constraints. Shorthand is always converted to a strict schema.
+func (p *OpenAIProvider) schemaFor(properties map[string]interface{}, required []string, schema map[string]interface{}) (map[string]interface{}, bool) {
+	if schema != nil {
+		return schema, isOpenAIStrictCompatible(schema) && len(p.UnsupportedSchemaKeywords(schema)) == 0
+	}
+	return buildOpenAIStrictObjectSchema(properties, required), true
+}
+
+// buildOpenAIStrictObjectSchema wraps properties into an object schema that
+// satisfies strict mode: every key is listed in required and additionalProperties
+// is false at every nesting level. Optional keys (absent from required, nil
+// meaning none) are expressed with the nullable union pattern instead.
+func buildOpenAIStrictObjectSchema(properties map[string]interface{}, required []string) map[string]interface{} {
+	isRequired := requiredSet(properties, required)
+	allKeys := make([]string, 0, len(properties))
+	props := make(map[string]interface{}, len(properties))
+	for k, v := range properties {
+		allKeys = append(allKeys, k)
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			props[k] = v
+			continue
+		}
+		if !isRequired[k] {
+			m = withNullable(m)
+		}
+		props[k] = strictOpenAIPropertySchema(m)
+	}
+	sort.Strings(allKeys)
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           props,
+		"required":             allKeys,
+		"additionalProperties": false,
+	}
+}
+
+func strictOpenAIPropertySchema(m map[string]interface{}) map[string]interface{} {
+	t, _ := m["type"].(string)
+	var out map[string]interface{}
+	switch strings.ToLower(t) {
+	case "object":
+		nested, _ := m["properties"].(map[string]interface{})
+		out = buildOpenAIStrictObjectSchema(nested, schemaRequired(m))
+		if d, ok := m["description"]; ok {
+			out["description"] = d
+		}
+	case "array":
+		items, ok := m["items"].(map[string]interface{})
+		if !ok {
+			out = m
+			break
+		}
+		out = make(map[string]interface{}, len(m))
+		for k, v := range m {
+			out[k] = v
+		}
+		out["items"] = strictOpenAIPropertySchema(items)
+	default:
+		out = m
+	}
+	if nullable, _ := m["nullable"].(bool); !nullable {
+		return out
+	}
+	// Strict mode has no nullable keyword; use a ["type","null"] union and
+	// allow null among enum values.
+	union := make(map[string]interface{}, len(out))
+	for k, v := range out {
+		union[k] = v
+	}
+	delete(union, "nullable")
+	union["type"] = []interface{}{t, "null"}
+	if values := enumStrings(union["enum"]); len(values) > 0 {
+		enum := make([]interface{}, 0, len(values)+1)
+		for _, v := range values {
+			enum = append(enum, v)
+		}
+		union["enum"] = append(enum, nil)
+	}
+	return union
+}
+
+// openAISchemaKeywords lists the JSON Schema keywords supported by structured
+// outputs in strict mode.
+var openAISchemaKeywords = map[string]bool{
+	"type": true, "properties": true, "required": true, "additionalProperties": true,
+	"items": true, "enum": true, "const": true, "anyOf": true, "$ref": true, "$defs": true,
+	"definitions": true, "description": true, "title": true, "pattern": true, "format": true,
+	"minimum": true, "maximum": true, "exclusiveMinimum": true, "exclusiveMaximum": true,
+	"multipleOf": true, "minItems": true, "maxItems": true,
+}
+
+// UnsupportedSchemaKeywords implements SchemaKeywordReporter.
+func (p *OpenAIProvider) UnsupportedSchemaKeywords(schema map[string]interface{}) []string {
+	return collectUnsupportedKeywords(schema, openAISchemaKeywords)
+}
+
+// isOpenAIStrictCompatible reports whether every object in schema sets
+// additionalProperties:false and requires all of its properties.
+func isOpenAIStrictCompatible(schema map[string]interface{}) bool {
+	var check func(node interface{}) bool
+	check = func(node interface{}) bool {
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return true
+		}
+		if props, ok := m["properties"].(map[string]interface{}); ok {
+			if ap, ok := m["additionalProperties"].(bool); !ok || ap {
+				return false
+			}
+			req := map[string]bool{}
+			for _, k := range schemaRequired(m) {
+				req[k] = true
+			}
+			for k, child := range props {
+				if !req[k] || !check(child) {
+					return false
+				}
+			}
+		}
+		for _, key := range []string{"$defs", "definitions"} {
+			if defs, ok := m[key].(map[string]interface{}); ok {
+				for _, child := range defs {
+					if !check(child) {
+						return false
+					}
+				}
+			}
+		}
+		if list, ok := m["anyOf"].([]interface{}); ok {
+			for _, child := range list {
+				if !check(child) {
+					return false
+				}
+			}
+		}
+		return check(m["items"])
+	}
+	return check(schema)
+}
+
+func (p *OpenAIProvider) BuildAPIRequest(ctx context.Context, payload map[string]interface{}, baseURL string, reqOpts RequestOptions) (*http.Request, error) {
 	body, err := json.Marshal(payload)
 	if err != nil {
 		return nil, fmt.Errorf("failed to encode payload: %w", err)
@@ -71,12 +307,15 @@ func (p *OpenAIProvider) BuildAPIRequest(payload map[string]interface{}, baseURL
 		baseURL = "https://api.openai.com/v1"
 	}
 
-	req, err := http.NewRequest("POST", strings.TrimRight(baseURL, "/")+"/responses", bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimRight(baseURL, "/")+"/responses", bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
+	if stream, _ := payload["stream"].(bool); stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
 
 	apiKey := reqOpts.APIKey
 	if apiKey == "" {
@@ -97,19 +336,10 @@ func (p *OpenAIProvider) BuildAPIRequest(payload map[string]interface{}, baseURL
 	return req, nil
 }
 
-func (p *OpenAIProvider) ParseAPIResponse(respBody []byte) (string, error) {
-	var apiResp struct {
-		OutputText string `json:"output_text"`
-		Output     []struct {
-			Content []struct {
-				Type string `json:"type"`
-				Text string `json:"text"`
-			} `json:"content"`
-		} `json:"output"`
-	}
-
+func (p *OpenAIProvider) ParseAPIResponse(respBody []byte) (Response, error) {
+	var apiResp openAIResponseObject
 	if err := json.Unmarshal(respBody, &apiResp); err != nil {
-		return "", fmt.Errorf("failed to parse response: %v", err)
+		return Response{}, fmt.Errorf("failed to parse response: %v", err)
 	}
 
 	textOut := apiResp.OutputText
@@ -127,5 +357,166 @@ func (p *OpenAIProvider) ParseAPIResponse(respBody []byte) (string, error) {
 		}
 	}
 
-	return textOut, nil
+	return apiResp.result(textOut)
+}
+
+// openAIResponseObject is a Responses API response object, as returned by
+// the API and carried by the final stream events.
+type openAIResponseObject struct {
+	ID                string `json:"id"`
+	Model             string `json:"model"`
+	Status            string `json:"status"`
+	IncompleteDetails *struct {
+		Reason string `json:"reason"`
+	} `json:"incomplete_details"`
+	OutputText string `json:"output_text"`
+	Output     []struct {
+		Type    string `json:"type"`
+		Content []struct {
+			Type    string `json:"type"`
+			Text    string `json:"text"`
+			Refusal string `json:"refusal"`
+		} `json:"content"`
+	} `json:"output"`
+	Usage struct {
+		InputTokens        int `json:"input_tokens"`
+		InputTokensDetails struct {
+			CachedTokens int `json:"cached_tokens"`
+		} `json:"input_tokens_details"`
+		OutputTokens        int `json:"output_tokens"`
+		OutputTokensDetails struct {
+			ReasoningTokens int `json:"reasoning_tokens"`
+		} `json:"output_tokens_details"`
+	} `json:"usage"`
+}
+
+// result builds the Response for r with the given text, classifying
+// truncated and refused responses.
+func (r openAIResponseObject) result(text string) (Response, error) {
+	out := r.response(text)
+	var refusal strings.Builder
+	for _, item := range r.Output {
+		for _, c := range item.Content {
+			if c.Type == "refusal" {
+				refusal.WriteString(c.Refusal)
+			}
+		}
+	}
+	reason := r.Status
+	if r.IncompleteDetails != nil {
+		reason = r.IncompleteDetails.Reason
+	}
+	if refusal.Len() > 0 {
+		out.FinishReason, reason = FinishContentFilter, "refusal"
+	}
+	return out, finishError("openai", out, reason, refusal.String())
+}
+
+// response builds the Response for r with the given text. The finish reason
+// derives from the status: an incomplete response reports why it stopped.
+func (r openAIResponseObject) response(text string) Response {
+	finish := r.Status
+	switch {
+	case r.Status == "incomplete" && r.IncompleteDetails != nil:
+		finish = normalizeFinishReason(r.IncompleteDetails.Reason, map[string]string{
+			"max_output_tokens": FinishLength,
+			"content_filter":    FinishContentFilter,
+		})
+	case r.Status == "completed":
+		finish = FinishStop
+		for _, item := range r.Output {
+			if item.Type == "function_call" {
+				finish = FinishToolCalls
+				break
+			}
+		}
+	}
+	return Response{
+		Text:         text,
+		ID:           r.ID,
+		Model:        r.Model,
+		FinishReason: finish,
+		Usage: Usage{
+			InputTokens:     r.Usage.InputTokens,
+			OutputTokens:    r.Usage.OutputTokens,
+			CachedTokens:    r.Usage.InputTokensDetails.CachedTokens,
+			ReasoningTokens: r.Usage.OutputTokensDetails.ReasoningTokens,
+		},
+	}
+}
+
+// ParseToolCalls implements ToolCallingProvider for Responses API
+// function_call output items.
+func (p *OpenAIProvider) ParseToolCalls(respBody []byte) ([]ToolCall, error) {
+	var apiResp struct {
+		Output []struct {
+			Type      string `json:"type"`
+			CallID    string `json:"call_id"`
+			Name      string `json:"name"`
+			Arguments string `json:"arguments"`
+		} `json:"output"`
+	}
+	if err := json.Unmarshal(respBody, &apiResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %v", err)
+	}
+	var calls []ToolCall
+	for _, item := range apiResp.Output {
+		if item.Type != "function_call" {
+			continue
+		}
+		args, err := decodeToolArguments(item.Name, item.Arguments)
+		if err != nil {
+			return nil, err
+		}
+		calls = append(calls, ToolCall{ID: item.CallID, Name: item.Name, Arguments: args})
+	}
+	return calls, nil
+}
+
+// EnableStreaming implements StreamingProvider.
+func (p *OpenAIProvider) EnableStreaming(payload map[string]interface{}) {
+	payload["stream"] = true
+}
+
+// ParseAPIStream implements StreamingProvider for Responses API stream events.
+func (p *OpenAIProvider) ParseAPIStream(body io.Reader, onText func(string)) (Response, error) {
+	var (
+		b     strings.Builder
+		final openAIResponseObject
+	)
+	err := readSSE(body, func(_, data string) error {
+		var ev struct {
+			Type     string `json:"type"`
+			Delta    string `json:"delta"`
+			Message  string `json:"message"`
+			Response struct {
+				openAIResponseObject
+				Error *struct {
+					Message string `json:"message"`
+				} `json:"error"`
+			} `json:"response"`
+		}
+		if err := json.Unmarshal([]byte(data), &ev); err != nil {
+			return fmt.Errorf("failed to parse stream event: %v", err)
+		}
+		switch ev.Type {
+		case "response.output_text.delta":
+			b.WriteString(ev.Delta)
+			onText(ev.Delta)
+		case "response.completed", "response.incomplete":
+			final = ev.Response.openAIResponseObject
+		case "error":
+			return fmt.Errorf("openai: stream error: %s", ev.Message)
+		case "response.failed":
+			if ev.Response.Error != nil {
+				return fmt.Errorf("openai: response failed: %s", ev.Response.Error.Message)
+			}
+			return fmt.Errorf("openai: response failed")
+		}
+		return nil
+	})
+	if err != nil {
+		return final.response(b.String()), err
+	}
+	return final.result(b.String())
 }
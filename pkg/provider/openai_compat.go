@@ -2,8 +2,11 @@ package provider
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"strings"
@@ -18,27 +21,61 @@ func (p *OpenAICompatProvider) DefaultOptions() Options {
 	}
 }
 
+func (p *OpenAICompatProvider) Capabilities() Capabilities {
+	return Capabilities{
+		NativeSchema: true,
+		Streaming:    true,
+		Tools:        true,
+		Vision:       true,
+		SystemPrompt: true,
+	}
+}
+
+// UnsupportedSchemaKeywords implements SchemaKeywordReporter. Compatible
+// servers are held to the keywords OpenAI structured outputs support.
+func (p *OpenAICompatProvider) UnsupportedSchemaKeywords(schema map[string]interface{}) []string {
+	return collectUnsupportedKeywords(schema, openAISchemaKeywords)
+}
+
 func (p *OpenAICompatProvider) BuildAPIPayload(opts Options) (map[string]interface{}, error) {
-	// Build messages: optional system with instructions (+ schema hint), then user message
-	messages := make([]map[string]interface{}, 0, 2)
+	// Build messages: optional system with instructions (+ schema hint), prior turns, then user message
+	messages := make([]map[string]interface{}, 0, len(opts.History)+2)
 
 	// Merge instruction with strict JSON hint if properties exist.
-	if sys := buildStrictJSONSystem(opts.Properties, opts.Instructions); strings.TrimSpace(sys) != "" {
+	if sys := strictJSONSystemFor(opts); strings.TrimSpace(sys) != "" {
 		messages = append(messages, map[string]interface{}{
 			"role":    "system",
 			"content": sys,
 		})
 	}
 
-	messages = append(messages, map[string]interface{}{
-		"role":    "user",
-		"content": opts.Message,
-	})
+	for _, t := range opts.History {
+		if t.hasAttachments() {
+			content, err := openAICompatUserContent(t.Content, t.Images, t.Files)
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, map[string]interface{}{"role": "user", "content": content})
+			continue
+		}
+		messages = append(messages, openAICompatMessage(t))
+	}
+
+	if !userTurnOmitted(opts) {
+		content, err := openAICompatUserContent(opts.Message, opts.Images, opts.Files)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, map[string]interface{}{
+			"role":    "user",
+			"content": content,
+		})
+	}
 
 	payload := map[string]interface{}{
 		"model":    opts.Model,
 		"messages": messages,
-		// Keep default n=1; no streaming
+		// Keep default n=1; streaming is opted into via EnableStreaming
 	}
 
 	if opts.MaxTokens > 0 {
@@ -46,10 +83,120 @@ func (p *OpenAICompatProvider) BuildAPIPayload(opts Options) (map[string]interfa
 		payload["max_tokens"] = opts.MaxTokens
 	}
 
+	// Constrain the output to the schema (non-strict, since compatible
+	// servers differ in what strict mode accepts). Declared tools must stay
+	// free for the model to call, so alongside them only the system hint is
+	// used.
+	structured := len(opts.Properties) > 0 || opts.Schema != nil
+	if structured && len(opts.Tools) == 0 {
+		output := Tool{Properties: opts.Properties, Required: opts.Required, Schema: opts.Schema}
+		payload["response_format"] = map[string]interface{}{
+			"type": "json_schema",
+			"json_schema": map[string]interface{}{
+				"name":   "response",
+				"schema": output.jsonSchema(),
+			},
+		}
+	}
+
+	if len(opts.Tools) > 0 {
+		tools := make([]map[string]interface{}, 0, len(opts.Tools))
+		for _, t := range opts.Tools {
+			fn := map[string]interface{}{
+				"name":       t.Name,
+				"parameters": t.jsonSchema(),
+			}
+			if t.Description != "" {
+				fn["description"] = t.Description
+			}
+			tools = append(tools, map[string]interface{}{"type": "function", "function": fn})
+		}
+		payload["tools"] = tools
+		if mode, name := toolChoiceMode(opts.ToolChoice); name != "" {
+			payload["tool_choice"] = map[string]interface{}{
+				"type":     "function",
+				"function": map[string]interface{}{"name": name},
+			}
+		} else {
+			payload["tool_choice"] = mode
+		}
+	}
+
 	return payload, nil
 }
 
-func (p *OpenAICompatProvider) BuildAPIRequest(payload map[string]interface{}, baseURL string, reqOpts RequestOptions) (*http.Request, error) {
+// WithoutNativeSchema implements SchemaFallbackProvider: servers that reject
+// response_format (older vLLM, llama.cpp and LM Studio builds, some proxies)
+// still get the system hint.
+func (p *OpenAICompatProvider) WithoutNativeSchema(payload map[string]interface{}) (map[string]interface{}, bool) {
+	if _, ok := payload["response_format"]; !ok {
+		return payload, false
+	}
+	out := make(map[string]interface{}, len(payload))
+	for k, v := range payload {
+		if k != "response_format" {
+			out[k] = v
+		}
+	}
+	return out, true
+}
+
+// openAICompatUserContent returns the content of a user message: the text
+// alone, or content parts when images or files are attached.
+func openAICompatUserContent(text string, images []Image, files []File) (interface{}, error) {
+	if len(images) == 0 && len(files) == 0 {
+		return text, nil
+	}
+	parts := make([]map[string]interface{}, 0, len(images)+len(files)+1)
+	if text != "" {
+		parts = append(parts, map[string]interface{}{"type": "text", "text": text})
+	}
+	for _, img := range images {
+		parts = append(parts, map[string]interface{}{
+			"type":      "image_url",
+			"image_url": map[string]interface{}{"url": img.urlOrDataURL()},
+		})
+	}
+	// Chat Completions endpoints have no portable document input, so file
+	// contents are sent as text (PDFs are extracted locally).
+	for _, f := range files {
+		text, err := f.extractedText()
+		if err != nil {
+			return nil, fmt.Errorf("openai-compat: %w", err)
+		}
+		parts = append(parts, map[string]interface{}{"type": "text", "text": f.inlineText(text)})
+	}
+	return parts, nil
+}
+
+// openAICompatMessage converts a turn to a Chat Completions message,
+// including assistant tool_calls and role "tool" results.
+func openAICompatMessage(t Turn) map[string]interface{} {
+	if t.Role == RoleTool {
+		return map[string]interface{}{"role": "tool", "tool_call_id": t.ToolCallID, "content": t.Content}
+	}
+	msg := map[string]interface{}{"role": t.Role, "content": t.Content}
+	if len(t.ToolCalls) > 0 {
+		calls := make([]map[string]interface{}, 0, len(t.ToolCalls))
+		for _, c := range t.ToolCalls {
+			calls = append(calls, map[string]interface{}{
+				"id":   c.ID,
+				"type": "function",
+				"function": map[string]interface{}{
+					"name":      c.Name,
+					"arguments": encodeToolArguments(c.Arguments),
+				},
+			})
+		}
+		msg["tool_calls"] = calls
+		if t.Content == "" {
+			msg["content"] = nil
+		}
+	}
+	return msg
+}
+
+func (p *OpenAICompatProvider) BuildAPIRequest(ctx context.Context, payload map[string]interface{}, baseURL string, reqOpts RequestOptions) (*http.Request, error) {
 	body, err := json.Marshal(payload)
 	if err != nil {
 		return nil, fmt.Errorf("failed to encode payload: %w", err)
@@ -59,12 +206,15 @@ func (p *OpenAICompatProvider) BuildAPIRequest(payload map[string]interface{}, b
 		baseURL = "https://api.openai.com/v1"
 	}
 
-	req, err := http.NewRequest("POST", strings.TrimRight(baseURL, "/")+"/chat/completions", bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimRight(baseURL, "/")+"/chat/completions", bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
+	if stream, _ := payload["stream"].(bool); stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
 
 	apiKey := reqOpts.APIKey
 	if apiKey == "" {
@@ -85,19 +235,163 @@ func (p *OpenAICompatProvider) BuildAPIRequest(payload map[string]interface{}, b
 	return req, nil
 }
 
-func (p *OpenAICompatProvider) ParseAPIResponse(respBody []byte) (string, error) {
+func (p *OpenAICompatProvider) ParseAPIResponse(respBody []byte) (Response, error) {
 	var apiResp struct {
+		ID      string `json:"id"`
+		Model   string `json:"model"`
 		Choices []struct {
 			Message struct {
 				Content string `json:"content"`
+				Refusal string `json:"refusal"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+		Usage chatUsage `json:"usage"`
+	}
+	if err := json.Unmarshal(respBody, &apiResp); err != nil {
+		return Response{}, fmt.Errorf("failed to parse response: %v", err)
+	}
+	if len(apiResp.Choices) == 0 {
+		return Response{}, fmt.Errorf("no choices in response")
+	}
+	choice := apiResp.Choices[0]
+	out := Response{
+		Text:         choice.Message.Content,
+		ID:           apiResp.ID,
+		Model:        apiResp.Model,
+		FinishReason: normalizeFinishReason(choice.FinishReason, chatFinishReasons),
+		Usage:        apiResp.Usage.usage(),
+	}
+	if choice.Message.Refusal != "" {
+		out.FinishReason = FinishContentFilter
+	}
+	return out, finishError("openai-compat", out, choice.FinishReason, choice.Message.Refusal)
+}
+
+// chatFinishReasons maps Chat Completions finish reasons that differ from
+// the normalized ones.
+var chatFinishReasons = map[string]string{"function_call": FinishToolCalls}
+
+// chatUsage is the Chat Completions usage object.
+type chatUsage struct {
+	PromptTokens        int `json:"prompt_tokens"`
+	PromptTokensDetails struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"prompt_tokens_details"`
+	CompletionTokens        int `json:"completion_tokens"`
+	CompletionTokensDetails struct {
+		ReasoningTokens int `json:"reasoning_tokens"`
+	} `json:"completion_tokens_details"`
+}
+
+func (u chatUsage) usage() Usage {
+	return Usage{
+		InputTokens:     u.PromptTokens,
+		OutputTokens:    u.CompletionTokens,
+		CachedTokens:    u.PromptTokensDetails.CachedTokens,
+		ReasoningTokens: u.CompletionTokensDetails.ReasoningTokens,
+	}
+}
+
+// errStreamDone stops SSE reading at the Chat Completions "[DONE]" sentinel.
+var errStreamDone = errors.New("stream done")
+
+// ParseToolCalls implements ToolCallingProvider for Chat Completions
+// message tool_calls.
+func (p *OpenAICompatProvider) ParseToolCalls(respBody []byte) ([]ToolCall, error) {
+	var apiResp struct {
+		Choices []struct {
+			Message struct {
+				ToolCalls []struct {
+					ID       string `json:"id"`
+					Function struct {
+						Name      string `json:"name"`
+						Arguments string `json:"arguments"`
+					} `json:"function"`
+				} `json:"tool_calls"`
 			} `json:"message"`
 		} `json:"choices"`
 	}
 	if err := json.Unmarshal(respBody, &apiResp); err != nil {
-		return "", fmt.Errorf("failed to parse response: %v", err)
+		return nil, fmt.Errorf("failed to parse response: %v", err)
 	}
 	if len(apiResp.Choices) == 0 {
-		return "", fmt.Errorf("no choices in response")
+		return nil, nil
+	}
+	var calls []ToolCall
+	for _, tc := range apiResp.Choices[0].Message.ToolCalls {
+		args, err := decodeToolArguments(tc.Function.Name, tc.Function.Arguments)
+		if err != nil {
+			return nil, err
+		}
+		calls = append(calls, ToolCall{ID: tc.ID, Name: tc.Function.Name, Arguments: args})
+	}
+	return calls, nil
+}
+
+// EnableStreaming implements StreamingProvider.
+func (p *OpenAICompatProvider) EnableStreaming(payload map[string]interface{}) {
+	payload["stream"] = true
+	// Ask for a final chunk carrying token usage.
+	payload["stream_options"] = map[string]interface{}{"include_usage": true}
+}
+
+// ParseAPIStream implements StreamingProvider for Chat Completions deltas.
+func (p *OpenAICompatProvider) ParseAPIStream(body io.Reader, onText func(string)) (Response, error) {
+	var (
+		b, refusal strings.Builder
+		out        Response
+		rawReason  string
+	)
+	err := readSSE(body, func(_, data string) error {
+		if strings.TrimSpace(data) == "[DONE]" {
+			return errStreamDone
+		}
+		var chunk struct {
+			ID      string `json:"id"`
+			Model   string `json:"model"`
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+					Refusal string `json:"refusal"`
+				} `json:"delta"`
+				FinishReason string `json:"finish_reason"`
+			} `json:"choices"`
+			Usage *chatUsage `json:"usage"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("failed to parse stream chunk: %v", err)
+		}
+		if chunk.Error != nil {
+			return fmt.Errorf("openai-compat: stream error: %s", chunk.Error.Message)
+		}
+		out.ID = ifEmptyString(chunk.ID, out.ID)
+		out.Model = ifEmptyString(chunk.Model, out.Model)
+		if chunk.Usage != nil {
+			out.Usage = chunk.Usage.usage()
+		}
+		if len(chunk.Choices) > 0 {
+			if reason := chunk.Choices[0].FinishReason; reason != "" {
+				rawReason = reason
+				out.FinishReason = normalizeFinishReason(reason, chatFinishReasons)
+			}
+			refusal.WriteString(chunk.Choices[0].Delta.Refusal)
+			if chunk.Choices[0].Delta.Content != "" {
+				b.WriteString(chunk.Choices[0].Delta.Content)
+				onText(chunk.Choices[0].Delta.Content)
+			}
+		}
+		return nil
+	})
+	out.Text = b.String()
+	if err != nil && !errors.Is(err, errStreamDone) {
+		return out, err
+	}
+	if refusal.Len() > 0 {
+		out.FinishReason = FinishContentFilter
 	}
-	return apiResp.Choices[0].Message.Content, nil
+	return out, finishError("openai-compat", out, rawReason, refusal.String())
 }
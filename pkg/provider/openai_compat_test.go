@@ -1,9 +1,11 @@
 package provider
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -21,14 +23,22 @@ func TestOpenAICompatProvider_ParseAPIResponse(t *testing.T) {
             "message": {"role": "assistant", "content": "Hello!"},
             "finish_reason": "stop"
           }
-        ]
+        ],
+        "usage": {"prompt_tokens": 19, "completion_tokens": 10, "prompt_tokens_details": {"cached_tokens": 4}}
       }`)
 	got, err := p.ParseAPIResponse(body)
 	if err != nil {
 		t.Fatalf("unexpected err: %v", err)
 	}
-	if got != "Hello!" {
-		t.Fatalf("got %q, want %q", got, "Hello!")
+	want := Response{
+		Text:         "Hello!",
+		ID:           "chatcmpl-xyz",
+		Model:        "gpt-4o-2025-04-14",
+		FinishReason: FinishStop,
+		Usage:        Usage{InputTokens: 19, OutputTokens: 10, CachedTokens: 4},
+	}
+	if got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
 	}
 }
 
@@ -88,12 +98,48 @@ func TestOpenAICompatProvider_BuildAPIPayload_SchemaAndMessages(t *testing.T) {
 	if payload["max_tokens"] != 321 {
 		t.Fatalf("expected max_tokens=321, got %v", payload["max_tokens"])
 	}
+
+	wantFormat := map[string]interface{}{
+		"type": "json_schema",
+		"json_schema": map[string]interface{}{
+			"name": "response",
+			"schema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"message": map[string]interface{}{"type": "string"},
+					"error":   map[string]interface{}{"type": "string"},
+				},
+				"required": []string{"error", "message"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(payload["response_format"], wantFormat) {
+		t.Fatalf("response_format mismatch:\n got=%v\nwant=%v", payload["response_format"], wantFormat)
+	}
+
+	// A full schema is sent as-is.
+	schema := map[string]interface{}{"type": "object", "properties": map[string]interface{}{"a": map[string]interface{}{"type": "string", "pattern": "^x"}}}
+	payload, _ = p.BuildAPIPayload(Options{Model: "m", Message: "hi", Schema: schema})
+	if got := payload["response_format"].(map[string]interface{})["json_schema"].(map[string]interface{})["schema"]; !reflect.DeepEqual(got, schema) {
+		t.Fatalf("schema mismatch: %v", got)
+	}
+
+	// Declared tools leave the output unconstrained; no schema means none sent.
+	opts.Tools = []Tool{{Name: "lookup", Properties: map[string]interface{}{"q": map[string]interface{}{"type": "string"}}}}
+	payload, _ = p.BuildAPIPayload(opts)
+	if _, ok := payload["response_format"]; ok {
+		t.Fatalf("response_format must not constrain output alongside tools")
+	}
+	payload, _ = p.BuildAPIPayload(Options{Model: "m", Message: "hi"})
+	if _, ok := payload["response_format"]; ok {
+		t.Fatalf("response_format should be omitted without a schema")
+	}
 }
 
 func TestOpenAICompatProvider_BuildAPIRequest(t *testing.T) {
 	p := &OpenAICompatProvider{}
 	payload := map[string]interface{}{"model": "gpt-4o-mini", "messages": []interface{}{}}
-	req, err := p.BuildAPIRequest(payload, "", RequestOptions{APIKey: "sk-test"})
+	req, err := p.BuildAPIRequest(context.Background(), payload, "", RequestOptions{APIKey: "sk-test"})
 	if err != nil {
 		t.Fatalf("unexpected err: %v", err)
 	}
@@ -119,3 +165,32 @@ func TestOpenAICompatProvider_BuildAPIRequest(t *testing.T) {
 		t.Fatalf("body model mismatch: %v", got["model"])
 	}
 }
+
+func TestOpenAICompatProvider_BuildAPIPayload_History(t *testing.T) {
+	p := &OpenAICompatProvider{}
+	payload, err := p.BuildAPIPayload(Options{
+		Model:        "gpt-4o-mini",
+		Instructions: "sys",
+		Message:      "fix it",
+		History: []Turn{
+			{Role: RoleUser, Content: "Hello"},
+			{Role: RoleAssistant, Content: "{bad"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	msgs := payload["messages"].([]map[string]interface{})
+	if len(msgs) != 4 {
+		t.Fatalf("expected 4 messages, got %d: %v", len(msgs), msgs)
+	}
+	roles := []string{"system", "user", "assistant", "user"}
+	for i, r := range roles {
+		if msgs[i]["role"] != r {
+			t.Fatalf("message %d role = %v, want %s", i, msgs[i]["role"], r)
+		}
+	}
+	if msgs[2]["content"] != "{bad" || msgs[3]["content"] != "fix it" {
+		t.Fatalf("message contents mismatch: %v", msgs)
+	}
+}
@@ -1,6 +1,7 @@
 package provider
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -43,8 +44,8 @@ func TestOpenAIProvider_ParseAPIResponse(t *testing.T) {
 			if (err != nil) != tt.wantErr {
 				t.Fatalf("error: %v, wantErr=%v", err, tt.wantErr)
 			}
-			if got != tt.want {
-				t.Fatalf("got %q, want %q", got, tt.want)
+			if got.Text != tt.want {
+				t.Fatalf("got %q, want %q", got.Text, tt.want)
 			}
 		})
 	}
@@ -129,7 +130,7 @@ func TestOpenAIProvider_BuildAPIPayload_DefaultsAndSchema(t *testing.T) {
 func TestOpenAIProvider_BuildAPIRequest_DefaultsAndHeaders(t *testing.T) {
 	p := &OpenAIProvider{}
 	payload := map[string]interface{}{"model": "gpt-5-nano"}
-	req, err := p.BuildAPIRequest(payload, "", RequestOptions{APIKey: "sk-test"})
+	req, err := p.BuildAPIRequest(context.Background(), payload, "", RequestOptions{APIKey: "sk-test"})
 	if err != nil {
 		t.Fatalf("unexpected err: %v", err)
 	}
@@ -175,3 +176,145 @@ func TestOpenAIProvider_BuildAPIPayload_MaxOutputTokens(t *testing.T) {
 		t.Fatalf("max_output_tokens should be omitted when zero")
 	}
 }
+
+func TestOpenAIProvider_BuildAPIPayload_NestedStrictSchema(t *testing.T) {
+	p := &OpenAIProvider{}
+	opts := Options{
+		Model:   "gpt-5-nano",
+		Message: "Hello",
+		Properties: map[string]interface{}{
+			"user": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{"type": "string"},
+				},
+				"required": []string{"name"},
+			},
+			"items": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"sku": map[string]interface{}{"type": "string"},
+						"qty": map[string]interface{}{"type": "integer"},
+					},
+					"required": []string{"qty", "sku"},
+				},
+			},
+		},
+	}
+	payload, err := p.BuildAPIPayload(opts)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	format := payload["text"].(map[string]interface{})["format"].(map[string]interface{})
+	schema := format["schema"].(map[string]interface{})
+	want := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"user": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{"type": "string"},
+				},
+				"required":             []string{"name"},
+				"additionalProperties": false,
+			},
+			"items": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"sku": map[string]interface{}{"type": "string"},
+						"qty": map[string]interface{}{"type": "integer"},
+					},
+					"required":             []string{"qty", "sku"},
+					"additionalProperties": false,
+				},
+			},
+		},
+		"required":             []string{"items", "user"},
+		"additionalProperties": false,
+	}
+	if !reflect.DeepEqual(schema, want) {
+		t.Fatalf("schema mismatch:\ngot=%v\nwant=%v", schema, want)
+	}
+}
+
+func TestOpenAIProvider_BuildAPIPayload_OptionalAndNullable(t *testing.T) {
+	p := &OpenAIProvider{}
+	opts := Options{
+		Model:   "gpt-5-nano",
+		Message: "Hello",
+		Properties: map[string]interface{}{
+			"name": map[string]interface{}{"type": "string"},
+			"nick": map[string]interface{}{"type": "string"},
+			"mood": map[string]interface{}{"type": "string", "enum": []string{"a", "b"}, "nullable": true},
+		},
+		Required: []string{"mood", "name"},
+	}
+	payload, err := p.BuildAPIPayload(opts)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	schema := payload["text"].(map[string]interface{})["format"].(map[string]interface{})["schema"].(map[string]interface{})
+	if !reflect.DeepEqual(schema["required"], []string{"mood", "name", "nick"}) {
+		t.Fatalf("strict mode must require every key: %v", schema["required"])
+	}
+	props := schema["properties"].(map[string]interface{})
+	wantProps := map[string]interface{}{
+		"name": map[string]interface{}{"type": "string"},
+		"nick": map[string]interface{}{"type": []interface{}{"string", "null"}},
+		"mood": map[string]interface{}{
+			"type": []interface{}{"string", "null"},
+			"enum": []interface{}{"a", "b", nil},
+		},
+	}
+	if !reflect.DeepEqual(props, wantProps) {
+		t.Fatalf("properties mismatch:\ngot=%v\nwant=%v", props, wantProps)
+	}
+}
+
+func TestOpenAIProvider_BuildAPIPayload_History(t *testing.T) {
+	p := &OpenAIProvider{}
+	payload, err := p.BuildAPIPayload(Options{
+		Model:   "gpt-5-nano",
+		Message: "fix it",
+		History: []Turn{
+			{Role: RoleUser, Content: "Hello"},
+			{Role: RoleAssistant, Content: "{bad"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	want := []map[string]interface{}{
+		{"role": "user", "content": "Hello"},
+		{"role": "assistant", "content": "{bad"},
+		{"role": "user", "content": "fix it"},
+	}
+	if !reflect.DeepEqual(payload["input"], want) {
+		t.Fatalf("input mismatch: %v", payload["input"])
+	}
+}
+
+func TestOpenAIProvider_BuildAPIRequest_Streaming(t *testing.T) {
+	p := &OpenAIProvider{}
+	payload := map[string]interface{}{"model": "gpt-5-nano"}
+	p.EnableStreaming(payload)
+	req, err := p.BuildAPIRequest(context.Background(), payload, "", RequestOptions{APIKey: "sk-test"})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if req.Header.Get("Accept") != "text/event-stream" {
+		t.Fatalf("accept header mismatch: %s", req.Header.Get("Accept"))
+	}
+	b, _ := io.ReadAll(req.Body)
+	var got map[string]interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("invalid body json: %v", err)
+	}
+	if got["stream"] != true {
+		t.Fatalf("body should request streaming: %v", got)
+	}
+}
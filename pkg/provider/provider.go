@@ -1,24 +1,136 @@
 package provider
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 )
 
+// Conversation roles used by Turn.
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	// RoleTool marks the result of a tool call made in an earlier
+	// assistant turn.
+	RoleTool = "tool"
+)
+
+// Turn is a prior conversation message that precedes Options.Message.
+type Turn struct {
+	// Role is RoleSystem, RoleUser, RoleAssistant or RoleTool.
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	// ToolCalls are the tool calls made by an assistant turn, after any
+	// text in Content.
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// ToolCallID and ToolName identify the call a RoleTool turn answers;
+	// Content holds the tool output.
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	ToolName   string `json:"tool_name,omitempty"`
+	// Images and Files are attachments of a RoleUser turn, sent like
+	// Options.Images and Options.Files. They are not saved in transcripts.
+	Images []Image `json:"-"`
+	Files  []File  `json:"-"`
+}
+
+// Image is an image attached to Options.Message. Either Data (a local file)
+// or URL (a remote http(s) image) is set.
+type Image struct {
+	// MIMEType is the image media type (e.g., image/png). It may be empty
+	// for URLs whose type is unknown.
+	MIMEType string
+	Data     []byte
+	URL      string
+}
+
+// File is a document attached to Options.Message: a PDF or a text file.
+type File struct {
+	// Name is the base file name shown to the model.
+	Name string
+	// MIMEType is application/pdf or a text type (e.g., text/plain).
+	MIMEType string
+	Data     []byte
+	// Text is the text of a PDF when it was already extracted (see
+	// attachment.LoadFile), so requests and cost estimates reuse it instead
+	// of extracting it again on every attempt.
+	Text string
+}
+
+// Tool is a function the model may call instead of answering directly. Its
+// arguments object is described like the output object in Options: either
+// by Properties/Required (--format shorthand) or by a full JSON Schema.
+type Tool struct {
+	Name        string
+	Description string
+	Properties  map[string]interface{}
+	// Required lists the keys of Properties that must be present. When nil,
+	// all keys are treated as required.
+	Required []string
+	// Schema optionally holds a complete JSON Schema for the arguments
+	// object and takes precedence over Properties/Required.
+	Schema map[string]interface{}
+}
+
+// Tool choice modes for Options.ToolChoice. Any other value names the tool
+// the model must call.
+const (
+	ToolChoiceAuto     = "auto"
+	ToolChoiceRequired = "required"
+	ToolChoiceNone     = "none"
+)
+
+// ToolCall is a tool invocation requested by the model.
+type ToolCall struct {
+	// ID identifies the call so a result can be matched to it. Providers
+	// that do not assign IDs leave it empty.
+	ID        string                 `json:"id,omitempty"`
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments"`
+}
+
 // Options represents common inputs to build an API payload.
 type Options struct {
-	Model           string
-	Instructions    string
-	Message         string
+	Model        string
+	Instructions string
+	// Message is the new user turn. It may be empty when History ends with
+	// tool results, in which case no user turn is sent.
+	Message string
+	// History holds earlier turns sent before Message, oldest first.
+	History []Turn
+	// Images are sent along with Message, in order.
+	Images []Image
+	// Files are documents sent along with Message, in order.
+	Files []File
+	// Tools are the functions the model may call.
+	Tools []Tool
+	// ToolChoice is ToolChoiceAuto (also when empty), ToolChoiceRequired,
+	// ToolChoiceNone or the name of a tool the model must call.
+	ToolChoice      string
 	Verbosity       string
 	ReasoningEffort string
 	// Properties holds the parsed properties map from CLI (--format shorthand).
-	// Providers wrap this into their schema representation and mark all keys required.
+	// Providers wrap this into their schema representation.
 	Properties map[string]interface{}
+	// Required lists the keys of Properties that must be present. When nil,
+	// all keys are treated as required.
+	Required []string
+	// Schema optionally holds a complete JSON Schema for the root object
+	// (e.g., loaded via --schema). When set, providers build their schema from
+	// it instead of Properties/Required, which should mirror its top level.
+	Schema map[string]interface{}
 	// MaxTokens is the provider-specific maximum output tokens, if applicable
 	// (e.g., Anthropic Messages API). 0 means unspecified.
 	MaxTokens int
+	// ModelOptions are runtime parameters for the model (e.g., num_ctx,
+	// temperature), sent as Ollama's options object.
+	ModelOptions map[string]interface{}
+	// KeepAlive is how long Ollama keeps the model loaded after the request
+	// (e.g., "10m", "0" to unload, "-1" to keep it loaded). Empty means the
+	// server default.
+	KeepAlive string
 }
 
 // RequestOptions represents options for building an HTTP request.
@@ -35,23 +147,123 @@ type Provider interface {
 	DefaultOptions() Options
 	// BuildAPIPayload builds a provider-specific payload from options.
 	BuildAPIPayload(opts Options) (map[string]interface{}, error)
-	// BuildAPIRequest creates the HTTP request to send the payload.
-	BuildAPIRequest(payload map[string]interface{}, baseURL string, reqOpts RequestOptions) (*http.Request, error)
-	// ParseAPIResponse extracts the text output from raw response bytes.
-	ParseAPIResponse(respBody []byte) (string, error)
+	// BuildAPIRequest creates the HTTP request to send the payload. ctx
+	// bounds the request, including reading a streamed response.
+	BuildAPIRequest(ctx context.Context, payload map[string]interface{}, baseURL string, reqOpts RequestOptions) (*http.Request, error)
+	// ParseAPIResponse extracts the text output and response metadata from
+	// raw response bytes. A response cut off at the max tokens limit returns
+	// a TruncatedError and a refused or blocked one a RefusalError, along
+	// with the parsed Response.
+	ParseAPIResponse(respBody []byte) (Response, error)
+	// Capabilities describes which Options the provider honors.
+	Capabilities() Capabilities
 }
 
-// Factory returns the Provider implementation by name.
-func New(name string) (Provider, error) {
+// Capabilities describes what a provider supports, so callers can reject or
+// warn about options it would ignore.
+type Capabilities struct {
+	// NativeSchema reports that the API enforces the output schema itself;
+	// otherwise the schema is only a prompt hint and output is checked
+	// locally.
+	NativeSchema bool `json:"native_schema"`
+	// Streaming and Tools mirror the StreamingProvider and
+	// ToolCallingProvider interfaces.
+	Streaming bool `json:"streaming"`
+	Tools     bool `json:"tools"`
+	// Vision reports support for Options.Images.
+	Vision bool `json:"vision"`
+	// Verbosity and ReasoningEffort report whether Options.Verbosity and
+	// Options.ReasoningEffort are sent; other providers ignore them.
+	Verbosity       bool `json:"verbosity"`
+	ReasoningEffort bool `json:"reasoning_effort"`
+	// ModelOptions and KeepAlive report whether Options.ModelOptions and
+	// Options.KeepAlive are sent.
+	ModelOptions bool `json:"model_options"`
+	KeepAlive    bool `json:"keep_alive"`
+	// SystemPrompt reports support for Options.Instructions and system
+	// turns.
+	SystemPrompt bool `json:"system_prompt"`
+	// MaxContextTokens is the context window of the default model, or 0
+	// when it depends on the server.
+	MaxContextTokens int `json:"max_context_tokens,omitempty"`
+}
+
+// StreamingProvider is implemented by providers that can deliver output
+// incrementally over server-sent events.
+type StreamingProvider interface {
+	Provider
+	// EnableStreaming marks a payload built by BuildAPIPayload for streaming so
+	// that BuildAPIRequest targets the provider's streaming endpoint.
+	EnableStreaming(payload map[string]interface{})
+	// ParseAPIStream consumes a streaming response body, calling onText for
+	// each text delta, and returns the complete response. Truncation and
+	// refusals are reported as in ParseAPIResponse.
+	ParseAPIStream(body io.Reader, onText func(string)) (Response, error)
+}
+
+// ToolCallingProvider is implemented by providers that support tool
+// (function) calling via Options.Tools.
+type ToolCallingProvider interface {
+	Provider
+	// ParseToolCalls extracts the tool calls from raw response bytes. It
+	// returns none when the model answered with text.
+	ParseToolCalls(respBody []byte) ([]ToolCall, error)
+}
+
+// SchemaKeywordReporter is implemented by providers that only honor a subset
+// of JSON Schema keywords when given Options.Schema.
+type SchemaKeywordReporter interface {
+	// UnsupportedSchemaKeywords lists keywords in schema that the provider
+	// drops or cannot enforce, sorted and de-duplicated.
+	UnsupportedSchemaKeywords(schema map[string]interface{}) []string
+}
+
+// SchemaFallbackProvider is implemented by providers whose native schema
+// enforcement not every server speaking their API understands.
+type SchemaFallbackProvider interface {
+	// WithoutNativeSchema returns a copy of payload that relies on the
+	// prompt hint alone, and false when payload has no native schema.
+	WithoutNativeSchema(payload map[string]interface{}) (map[string]interface{}, bool)
+}
+
+// Names lists the canonical provider names accepted by New.
+func Names() []string {
+	return []string{"openai", "openai-compat", "anthropic", "gemini", "ollama"}
+}
+
+// Canonical returns the canonical name (one of Names) of the provider called
+// name, which may be an alias such as "claude" or "compat", and false when
+// name is unknown.
+func Canonical(name string) (string, bool) {
 	switch name {
 	case "openai", "oa", "default", "":
-		return &OpenAIProvider{}, nil
+		return "openai", true
 	case "openai-compat", "openai-compatible", "oai-chat", "compat":
-		return &OpenAICompatProvider{}, nil
+		return "openai-compat", true
 	case "anthropic", "claude", "anth":
-		return &AnthropicProvider{}, nil
+		return "anthropic", true
 	case "gemini", "google", "gai":
+		return "gemini", true
+	case "ollama":
+		return "ollama", true
+	}
+	return "", false
+}
+
+// Factory returns the Provider implementation by name or alias.
+func New(name string) (Provider, error) {
+	canonical, _ := Canonical(name)
+	switch canonical {
+	case "openai":
+		return &OpenAIProvider{}, nil
+	case "openai-compat":
+		return &OpenAICompatProvider{}, nil
+	case "anthropic":
+		return &AnthropicProvider{}, nil
+	case "gemini":
 		return &GeminiProvider{}, nil
+	case "ollama":
+		return &OllamaProvider{}, nil
 	default:
 		return nil, ErrUnknownProvider{name: name}
 	}
@@ -0,0 +1,50 @@
+package provider
+
+import "testing"
+
+func TestCapabilities(t *testing.T) {
+	for _, name := range Names() {
+		p, err := New(name)
+		if err != nil {
+			t.Fatalf("New(%q): %v", name, err)
+		}
+		caps := p.Capabilities()
+		if _, ok := p.(StreamingProvider); ok != caps.Streaming {
+			t.Errorf("%s: Streaming = %v, but StreamingProvider implemented = %v", name, caps.Streaming, ok)
+		}
+		if _, ok := p.(ToolCallingProvider); ok != caps.Tools {
+			t.Errorf("%s: Tools = %v, but ToolCallingProvider implemented = %v", name, caps.Tools, ok)
+		}
+		// Providers must send exactly the provider-specific options they claim.
+		payload, err := p.BuildAPIPayload(Options{Model: "m", Message: "hi", Verbosity: "high", ReasoningEffort: "high", ModelOptions: map[string]interface{}{"temperature": 0}, KeepAlive: "1m"})
+		if err != nil {
+			t.Fatalf("%s: BuildAPIPayload: %v", name, err)
+		}
+		_, hasText := payload["text"]
+		_, hasReasoning := payload["reasoning"]
+		_, hasOptions := payload["options"]
+		_, hasKeepAlive := payload["keep_alive"]
+		if hasText != caps.Verbosity || hasReasoning != caps.ReasoningEffort || hasOptions != caps.ModelOptions || hasKeepAlive != caps.KeepAlive {
+			t.Errorf("%s: capabilities %+v disagree with payload %v", name, caps, payload)
+		}
+	}
+	if _, err := New("unknown"); err == nil {
+		t.Fatalf("expected an unknown provider error")
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	for alias, want := range map[string]string{"": "openai", "claude": "anthropic", "google": "gemini", "compat": "openai-compat", "ollama": "ollama"} {
+		if got, ok := Canonical(alias); !ok || got != want {
+			t.Errorf("Canonical(%q) = %q, %v; want %q", alias, got, ok, want)
+		}
+	}
+	for _, name := range Names() {
+		if got, ok := Canonical(name); !ok || got != name {
+			t.Errorf("Canonical(%q) = %q, %v", name, got, ok)
+		}
+	}
+	if _, ok := Canonical("unknown"); ok {
+		t.Fatalf("expected unknown")
+	}
+}
@@ -0,0 +1,130 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Normalized finish reasons reported in Response.FinishReason. Values a
+// provider reports that have no equivalent here are passed through in lower
+// case.
+const (
+	// FinishStop means the model ended its answer normally.
+	FinishStop = "stop"
+	// FinishLength means the output was cut off at the max tokens limit.
+	FinishLength = "length"
+	// FinishToolCalls means the model stopped to call tools.
+	FinishToolCalls = "tool_calls"
+	// FinishContentFilter means the output was blocked or refused.
+	FinishContentFilter = "content_filter"
+)
+
+// Usage reports the tokens a request consumed. Zero means the provider did
+// not report the count.
+type Usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	// CachedTokens is the part of InputTokens served from the prompt cache.
+	CachedTokens int `json:"cached_tokens"`
+	// ReasoningTokens is the part of OutputTokens spent on reasoning.
+	ReasoningTokens int `json:"reasoning_tokens"`
+}
+
+// Add accumulates o into u.
+func (u *Usage) Add(o Usage) {
+	u.InputTokens += o.InputTokens
+	u.OutputTokens += o.OutputTokens
+	u.CachedTokens += o.CachedTokens
+	u.ReasoningTokens += o.ReasoningTokens
+}
+
+// Response is a parsed model response.
+type Response struct {
+	// Text is the text output (the output object's JSON in structured mode).
+	Text string `json:"-"`
+	// ID is the provider's response id, if any.
+	ID string `json:"id,omitempty"`
+	// Model is the model (version) that served the request, as reported.
+	Model string `json:"model,omitempty"`
+	// FinishReason is one of the Finish* constants, or the provider's own
+	// value in lower case.
+	FinishReason string `json:"finish_reason,omitempty"`
+	Usage        Usage  `json:"usage"`
+}
+
+// normalizeFinishReason maps a provider finish reason through known, falling
+// back to the lower-cased value.
+func normalizeFinishReason(reason string, known map[string]string) string {
+	if n, ok := known[reason]; ok {
+		return n
+	}
+	return strings.ToLower(reason)
+}
+
+// ifEmptyString returns fallback when s is empty.
+func ifEmptyString(s, fallback string) string {
+	if s == "" {
+		return fallback
+	}
+	return s
+}
+
+// ErrTruncated is the sentinel wrapped by TruncatedError.
+var ErrTruncated = errors.New("output truncated")
+
+// TruncatedError reports a response cut off at the max tokens limit. It
+// unwraps to ErrTruncated.
+type TruncatedError struct {
+	Provider string
+	// Response holds the partial output and its metadata.
+	Response Response
+}
+
+func (e TruncatedError) Error() string {
+	if n := e.Response.Usage.OutputTokens; n > 0 {
+		return fmt.Sprintf("%s: output truncated at the max tokens limit (%d output tokens)", e.Provider, n)
+	}
+	return fmt.Sprintf("%s: output truncated at the max tokens limit", e.Provider)
+}
+
+func (e TruncatedError) Unwrap() error { return ErrTruncated }
+
+// ErrRefused is the sentinel wrapped by RefusalError.
+var ErrRefused = errors.New("response refused")
+
+// RefusalError reports a model refusal or a response blocked by the
+// provider's safety filters. It unwraps to ErrRefused.
+type RefusalError struct {
+	Provider string
+	// Reason is the provider's own finish or block reason (e.g., SAFETY).
+	Reason string
+	// Message is the model's refusal text, if it gave one.
+	Message  string
+	Response Response
+}
+
+func (e RefusalError) Error() string {
+	switch {
+	case e.Message != "":
+		return fmt.Sprintf("%s: the model refused: %s", e.Provider, e.Message)
+	case e.Reason != "":
+		return fmt.Sprintf("%s: response blocked (%s)", e.Provider, e.Reason)
+	}
+	return fmt.Sprintf("%s: response blocked", e.Provider)
+}
+
+func (e RefusalError) Unwrap() error { return ErrRefused }
+
+// finishError classifies a response that did not end normally: a refusal
+// message or FinishContentFilter yields a RefusalError and FinishLength a
+// TruncatedError. reason is the provider's raw finish or block reason.
+func finishError(provider string, resp Response, reason, refusal string) error {
+	switch {
+	case refusal != "" || resp.FinishReason == FinishContentFilter:
+		return RefusalError{Provider: provider, Reason: reason, Message: strings.TrimSpace(refusal), Response: resp}
+	case resp.FinishReason == FinishLength:
+		return TruncatedError{Provider: provider, Response: resp}
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package provider
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseAPIResponse_Metadata(t *testing.T) {
+	tests := []struct {
+		name string
+		p    Provider
+		body string
+		want Response
+		// wantErr is the sentinel the returned error must wrap, if any.
+		wantErr error
+	}{
+		{
+			name: "openai incomplete",
+			p:    &OpenAIProvider{},
+			body: `{"id":"resp_1","model":"gpt-5-nano-2025-08-07","status":"incomplete","incomplete_details":{"reason":"max_output_tokens"},
+				"output":[{"type":"message","content":[{"type":"output_text","text":"{\"a\":"}]}],
+				"usage":{"input_tokens":20,"input_tokens_details":{"cached_tokens":8},"output_tokens":16,"output_tokens_details":{"reasoning_tokens":12}}}`,
+			want: Response{Text: `{"a":`, ID: "resp_1", Model: "gpt-5-nano-2025-08-07", FinishReason: FinishLength,
+				Usage: Usage{InputTokens: 20, OutputTokens: 16, CachedTokens: 8, ReasoningTokens: 12}},
+			wantErr: ErrTruncated,
+		},
+		{
+			name: "openai function call",
+			p:    &OpenAIProvider{},
+			body: `{"id":"resp_2","status":"completed","output":[{"type":"function_call","name":"f","arguments":"{}"}]}`,
+			want: Response{ID: "resp_2", FinishReason: FinishToolCalls},
+		},
+		{
+			name: "chat tool calls",
+			p:    &OpenAICompatProvider{},
+			body: `{"choices":[{"message":{"content":null},"finish_reason":"tool_calls"}]}`,
+			want: Response{FinishReason: FinishToolCalls},
+		},
+		{
+			name: "anthropic max tokens with cache reads",
+			p:    &AnthropicProvider{},
+			body: `{"id":"msg_1","model":"claude-sonnet-4-0","stop_reason":"max_tokens","content":[{"type":"text","text":"Hi"}],
+				"usage":{"input_tokens":5,"cache_read_input_tokens":100,"output_tokens":7}}`,
+			want: Response{Text: "Hi", ID: "msg_1", Model: "claude-sonnet-4-0", FinishReason: FinishLength,
+				Usage: Usage{InputTokens: 105, OutputTokens: 7, CachedTokens: 100}},
+			wantErr: ErrTruncated,
+		},
+		{
+			name: "anthropic forced output tool",
+			p:    &AnthropicProvider{},
+			body: `{"stop_reason":"tool_use","content":[{"type":"tool_use","name":"structured_output","input":{"a":1}}]}`,
+			want: Response{Text: `{"a":1}`, FinishReason: FinishStop},
+		},
+		{
+			name: "gemini safety with thoughts",
+			p:    &GeminiProvider{},
+			body: `{"responseId":"r1","modelVersion":"gemini-2.5-flash","candidates":[{"content":{"parts":[]},"finishReason":"SAFETY"}],
+				"usageMetadata":{"promptTokenCount":9,"candidatesTokenCount":0,"thoughtsTokenCount":30}}`,
+			want: Response{ID: "r1", Model: "gemini-2.5-flash", FinishReason: FinishContentFilter,
+				Usage: Usage{InputTokens: 9, OutputTokens: 30, ReasoningTokens: 30}},
+			wantErr: ErrRefused,
+		},
+		{
+			name: "gemini function call",
+			p:    &GeminiProvider{},
+			body: `{"candidates":[{"content":{"parts":[{"functionCall":{"name":"f","args":{}}}]},"finishReason":"STOP"}]}`,
+			want: Response{FinishReason: FinishToolCalls},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.p.ParseAPIResponse([]byte(tt.body))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAPIStream_Metadata(t *testing.T) {
+	tests := []struct {
+		name string
+		p    StreamingProvider
+		body string
+		want Response
+		// wantErr is the sentinel the returned error must wrap, if any.
+		wantErr error
+	}{
+		{
+			name: "openai completed event",
+			p:    &OpenAIProvider{},
+			body: "data: {\"type\":\"response.output_text.delta\",\"delta\":\"Hi\"}\n\n" +
+				"data: {\"type\":\"response.completed\",\"response\":{\"id\":\"resp_1\",\"model\":\"gpt-5\",\"status\":\"completed\",\"usage\":{\"input_tokens\":3,\"output_tokens\":1}}}\n\n",
+			want: Response{Text: "Hi", ID: "resp_1", Model: "gpt-5", FinishReason: FinishStop, Usage: Usage{InputTokens: 3, OutputTokens: 1}},
+		},
+		{
+			name: "chat usage chunk",
+			p:    &OpenAICompatProvider{},
+			body: "data: {\"id\":\"c1\",\"model\":\"m\",\"choices\":[{\"delta\":{\"content\":\"Hi\"}}]}\n\n" +
+				"data: {\"id\":\"c1\",\"model\":\"m\",\"choices\":[{\"delta\":{},\"finish_reason\":\"length\"}]}\n\n" +
+				"data: {\"id\":\"c1\",\"model\":\"m\",\"choices\":[],\"usage\":{\"prompt_tokens\":4,\"completion_tokens\":2}}\n\n" +
+				"data: [DONE]\n\n",
+			want:    Response{Text: "Hi", ID: "c1", Model: "m", FinishReason: FinishLength, Usage: Usage{InputTokens: 4, OutputTokens: 2}},
+			wantErr: ErrTruncated,
+		},
+		{
+			name: "anthropic message events",
+			p:    &AnthropicProvider{},
+			body: "data: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\",\"model\":\"claude\",\"usage\":{\"input_tokens\":10,\"output_tokens\":1}}}\n\n" +
+				"data: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"Hi\"}}\n\n" +
+				"data: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":5}}\n\n",
+			want: Response{Text: "Hi", ID: "msg_1", Model: "claude", FinishReason: FinishStop, Usage: Usage{InputTokens: 10, OutputTokens: 5}},
+		},
+		{
+			name: "gemini final chunk",
+			p:    &GeminiProvider{},
+			body: "data: {\"responseId\":\"r1\",\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"Hi\"}]}}]}\n\n" +
+				"data: {\"candidates\":[{\"content\":{\"parts\":[]},\"finishReason\":\"MAX_TOKENS\"}],\"usageMetadata\":{\"promptTokenCount\":2,\"candidatesTokenCount\":8}}\n\n",
+			want:    Response{Text: "Hi", ID: "r1", FinishReason: FinishLength, Usage: Usage{InputTokens: 2, OutputTokens: 8}},
+			wantErr: ErrTruncated,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.p.ParseAPIStream(strings.NewReader(tt.body), func(string) {})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAPIResponse_Refusals(t *testing.T) {
+	tests := []struct {
+		name string
+		p    Provider
+		body string
+		want string
+	}{
+		{
+			name: "openai refusal content",
+			p:    &OpenAIProvider{},
+			body: `{"status":"completed","output":[{"type":"message","content":[{"type":"refusal","refusal":"I can't help with that."}]}]}`,
+			want: "openai: the model refused: I can't help with that.",
+		},
+		{
+			name: "openai content filter",
+			p:    &OpenAIProvider{},
+			body: `{"status":"incomplete","incomplete_details":{"reason":"content_filter"},"output":[]}`,
+			want: "openai: response blocked (content_filter)",
+		},
+		{
+			name: "chat refusal",
+			p:    &OpenAICompatProvider{},
+			body: `{"choices":[{"message":{"content":null,"refusal":"No."},"finish_reason":"stop"}]}`,
+			want: "openai-compat: the model refused: No.",
+		},
+		{
+			name: "anthropic refusal stop reason",
+			p:    &AnthropicProvider{},
+			body: `{"stop_reason":"refusal","content":[]}`,
+			want: "anthropic: response blocked (refusal)",
+		},
+		{
+			name: "gemini blocked prompt",
+			p:    &GeminiProvider{},
+			body: `{"promptFeedback":{"blockReason":"PROHIBITED_CONTENT"}}`,
+			want: "gemini: response blocked (PROHIBITED_CONTENT)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.p.ParseAPIResponse([]byte(tt.body))
+			var re RefusalError
+			if !errors.As(err, &re) || !errors.Is(err, ErrRefused) || err.Error() != tt.want {
+				t.Fatalf("err = %v, want %q", err, tt.want)
+			}
+			if got.FinishReason != FinishContentFilter {
+				t.Fatalf("finish reason = %q", got.FinishReason)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package provider
+
+import (
+	"sort"
+	"strings"
+)
+
+// schemaMetaKeywords are annotations every provider can safely ignore.
+var schemaMetaKeywords = map[string]bool{
+	"$schema":  true,
+	"$id":      true,
+	"$comment": true,
+}
+
+// collectUnsupportedKeywords walks a JSON Schema and returns the sorted set of
+// keywords not present in supported. Only schema-valued keywords are
+// descended into, so property names and enum values are never reported.
+func collectUnsupportedKeywords(schema map[string]interface{}, supported map[string]bool) []string {
+	found := map[string]bool{}
+	var walk func(node interface{})
+	walk = func(node interface{}) {
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for k, v := range m {
+			if !supported[k] && !schemaMetaKeywords[k] {
+				found[k] = true
+			}
+			switch k {
+			case "properties", "$defs", "definitions", "patternProperties", "dependentSchemas":
+				if children, ok := v.(map[string]interface{}); ok {
+					for _, child := range children {
+						walk(child)
+					}
+				}
+			case "items", "additionalProperties", "not", "if", "then", "else", "contains", "propertyNames", "unevaluatedProperties", "unevaluatedItems":
+				walk(v)
+			case "anyOf", "oneOf", "allOf", "prefixItems":
+				if list, ok := v.([]interface{}); ok {
+					for _, child := range list {
+						walk(child)
+					}
+				}
+			}
+		}
+	}
+	walk(schema)
+
+	out := make([]string, 0, len(found))
+	for k := range found {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// resolveSchemaRef resolves a local "#/$defs/Name" or "#/definitions/Name"
+// reference against the root schema.
+func resolveSchemaRef(root map[string]interface{}, ref string) (map[string]interface{}, bool) {
+	for _, prefix := range []string{"#/$defs/", "#/definitions/"} {
+		if !strings.HasPrefix(ref, prefix) {
+			continue
+		}
+		defs, _ := root[strings.TrimSuffix(strings.TrimPrefix(prefix, "#/"), "/")].(map[string]interface{})
+		def, ok := defs[strings.TrimPrefix(ref, prefix)].(map[string]interface{})
+		return def, ok
+	}
+	return nil, false
+}
+
+// schemaTypeAndNullable splits a JSON Schema "type" (a string or a list that
+// may include "null") into the base type and whether null is allowed.
+func schemaTypeAndNullable(m map[string]interface{}) (string, bool) {
+	nullable, _ := m["nullable"].(bool)
+	switch t := m["type"].(type) {
+	case string:
+		return t, nullable
+	case []interface{}:
+		base := ""
+		for _, v := range t {
+			s, _ := v.(string)
+			if s == "null" {
+				nullable = true
+			} else if base == "" {
+				base = s
+			}
+		}
+		return base, nullable
+	case []string:
+		base := ""
+		for _, s := range t {
+			if s == "null" {
+				nullable = true
+			} else if base == "" {
+				base = s
+			}
+		}
+		return base, nullable
+	default:
+		return "", nullable
+	}
+}
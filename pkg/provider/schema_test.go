@@ -0,0 +1,140 @@
+package provider
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func decodeSchema(t *testing.T, s string) map[string]interface{} {
+	t.Helper()
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		t.Fatalf("invalid schema json: %v", err)
+	}
+	return m
+}
+
+const testInvoiceSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "properties": {
+    "customer": {"$ref": "#/$defs/party"},
+    "total": {"type": "number", "minimum": 0, "description": "grand total"},
+    "note": {"type": ["string", "null"], "maxLength": 200},
+    "lines": {"type": "array", "uniqueItems": true, "items": {"type": "object", "properties": {"sku": {"type": "string", "pattern": "^[A-Z]+$"}}, "required": ["sku"], "additionalProperties": false}}
+  },
+  "required": ["customer", "total", "note", "lines"],
+  "additionalProperties": false,
+  "$defs": {
+    "party": {"type": "object", "properties": {"name": {"type": "string"}}, "required": ["name"], "additionalProperties": false}
+  }
+}`
+
+func TestUnsupportedSchemaKeywords(t *testing.T) {
+	schema := decodeSchema(t, testInvoiceSchema)
+
+	gotOpenAI := (&OpenAIProvider{}).UnsupportedSchemaKeywords(schema)
+	if want := []string{"maxLength", "uniqueItems"}; !reflect.DeepEqual(gotOpenAI, want) {
+		t.Fatalf("openai unsupported = %v, want %v", gotOpenAI, want)
+	}
+	gotCompat := (&OpenAICompatProvider{}).UnsupportedSchemaKeywords(schema)
+	if !reflect.DeepEqual(gotCompat, gotOpenAI) {
+		t.Fatalf("openai-compat unsupported = %v, want %v", gotCompat, gotOpenAI)
+	}
+	gotGemini := (&GeminiProvider{}).UnsupportedSchemaKeywords(schema)
+	if want := []string{"additionalProperties", "uniqueItems"}; !reflect.DeepEqual(gotGemini, want) {
+		t.Fatalf("gemini unsupported = %v, want %v", gotGemini, want)
+	}
+}
+
+func TestOpenAIProvider_BuildAPIPayload_FullSchema(t *testing.T) {
+	p := &OpenAIProvider{}
+	tests := []struct {
+		name       string
+		schema     string
+		wantStrict bool
+	}{
+		{
+			name:       "strict compatible",
+			schema:     `{"type":"object","properties":{"a":{"type":"string","pattern":"^x"}},"required":["a"],"additionalProperties":false}`,
+			wantStrict: true,
+		},
+		{
+			name:       "missing additionalProperties",
+			schema:     `{"type":"object","properties":{"a":{"type":"string"}},"required":["a"]}`,
+			wantStrict: false,
+		},
+		{
+			name:       "optional property",
+			schema:     `{"type":"object","properties":{"a":{"type":"string"}},"additionalProperties":false}`,
+			wantStrict: false,
+		},
+		{
+			name:       "unsupported keyword",
+			schema:     `{"type":"object","properties":{"a":{"type":"string","minLength":1}},"required":["a"],"additionalProperties":false}`,
+			wantStrict: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema := decodeSchema(t, tt.schema)
+			payload, err := p.BuildAPIPayload(Options{Model: "gpt-5-nano", Message: "Hi", Schema: schema})
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			format := payload["text"].(map[string]interface{})["format"].(map[string]interface{})
+			if format["strict"] != tt.wantStrict {
+				t.Fatalf("strict = %v, want %v", format["strict"], tt.wantStrict)
+			}
+			if !reflect.DeepEqual(format["schema"], schema) {
+				t.Fatalf("schema should be passed through unchanged: %v", format["schema"])
+			}
+		})
+	}
+}
+
+func TestGeminiProvider_BuildAPIPayload_FullSchema(t *testing.T) {
+	p := &GeminiProvider{}
+	payload, err := p.BuildAPIPayload(Options{Model: "gemini-2.0-flash", Message: "Hi", Schema: decodeSchema(t, testInvoiceSchema)})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	got := payload["generationConfig"].(map[string]interface{})["responseSchema"]
+	want := decodeSchema(t, `{
+	  "type": "OBJECT",
+	  "properties": {
+	    "customer": {"type": "OBJECT", "properties": {"name": {"type": "STRING"}}, "required": ["name"]},
+	    "total": {"type": "NUMBER", "minimum": 0, "description": "grand total"},
+	    "note": {"type": "STRING", "nullable": true, "maxLength": 200},
+	    "lines": {"type": "ARRAY", "items": {"type": "OBJECT", "properties": {"sku": {"type": "STRING", "pattern": "^[A-Z]+$"}}, "required": ["sku"]}}
+	  },
+	  "required": ["customer", "total", "note", "lines"]
+	}`)
+	if !reflect.DeepEqual(got, want) {
+		gb, _ := json.Marshal(got)
+		t.Fatalf("responseSchema mismatch:\ngot=%s", gb)
+	}
+}
+
+func TestGeminiProvider_BuildAPIPayload_RecursiveSchemaRef(t *testing.T) {
+	p := &GeminiProvider{}
+	schema := decodeSchema(t, `{"type":"object","properties":{"root":{"$ref":"#/$defs/node"}},"$defs":{"node":{"type":"object","properties":{"child":{"$ref":"#/$defs/node"}}}}}`)
+	if _, err := p.BuildAPIPayload(Options{Model: "gemini-2.0-flash", Message: "Hi", Schema: schema}); err == nil {
+		t.Fatalf("expected error for recursive $ref")
+	}
+}
+
+func TestAnthropicProvider_BuildAPIPayload_FullSchemaHint(t *testing.T) {
+	p := &AnthropicProvider{}
+	schema := decodeSchema(t, `{"type":"object","properties":{"name":{"type":"string","description":"full name"}},"required":["name"]}`)
+	payload, err := p.BuildAPIPayload(Options{Model: "claude-3-5-haiku-latest", MaxTokens: 1024, Message: "Hi", Instructions: "sys", Schema: schema, Tools: []Tool{{Name: "ping"}}})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	sys, _ := payload["system"].(string)
+	if !strings.HasPrefix(sys, "sys\n\n") || !strings.Contains(sys, `"description":"full name"`) {
+		t.Fatalf("system hint should embed the schema: %q", sys)
+	}
+}
@@ -0,0 +1,58 @@
+package provider
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// maxSSELine bounds a single SSE line; generous enough for large JSON chunks.
+const maxSSELine = 4 * 1024 * 1024
+
+// readSSE parses a server-sent events stream and calls fn for every event
+// with its name (empty when the server sends none) and joined data lines.
+// Returning a non-nil error from fn stops reading and returns that error.
+func readSSE(r io.Reader, fn func(event, data string) error) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), maxSSELine)
+
+	var (
+		event string
+		data  []string
+	)
+	dispatch := func() error {
+		if len(data) == 0 {
+			event = ""
+			return nil
+		}
+		err := fn(event, strings.Join(data, "\n"))
+		event, data = "", nil
+		return err
+	}
+
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			if err := dispatch(); err != nil {
+				return err
+			}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			// comment / keep-alive
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	return dispatch()
+}
@@ -0,0 +1,137 @@
+package provider
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadSSE(t *testing.T) {
+	stream := strings.Join([]string{
+		": keep-alive",
+		"event: first",
+		"data: {\"a\":1}",
+		"",
+		"data: line1",
+		"data: line2",
+		"",
+		"",
+		"event: last",
+		"data:no-space",
+	}, "\n")
+
+	type ev struct{ event, data string }
+	var got []ev
+	err := readSSE(strings.NewReader(stream), func(event, data string) error {
+		got = append(got, ev{event, data})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	want := []ev{
+		{"first", `{"a":1}`},
+		{"", "line1\nline2"},
+		{"last", "no-space"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("events mismatch:\ngot=%v\nwant=%v", got, want)
+	}
+}
+
+func TestReadSSE_StopsOnCallbackError(t *testing.T) {
+	stop := errors.New("stop")
+	calls := 0
+	err := readSSE(strings.NewReader("data: 1\n\ndata: 2\n\n"), func(_, _ string) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Fatalf("expected stop after first event, got err=%v calls=%d", err, calls)
+	}
+}
+
+func TestStreamingProviders_ParseAPIStream(t *testing.T) {
+	tests := []struct {
+		name    string
+		p       StreamingProvider
+		body    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "openai responses events",
+			p:    &OpenAIProvider{},
+			body: "event: response.created\ndata: {\"type\":\"response.created\"}\n\n" +
+				"event: response.output_text.delta\ndata: {\"type\":\"response.output_text.delta\",\"delta\":\"Hel\"}\n\n" +
+				"event: response.output_text.delta\ndata: {\"type\":\"response.output_text.delta\",\"delta\":\"lo\"}\n\n" +
+				"event: response.completed\ndata: {\"type\":\"response.completed\"}\n\n",
+			want: "Hello",
+		},
+		{
+			name:    "openai failed response",
+			p:       &OpenAIProvider{},
+			body:    "data: {\"type\":\"response.failed\",\"response\":{\"error\":{\"message\":\"boom\"}}}\n\n",
+			wantErr: true,
+		},
+		{
+			name: "chat completions deltas",
+			p:    &OpenAICompatProvider{},
+			body: "data: {\"choices\":[{\"delta\":{\"role\":\"assistant\"}}]}\n\n" +
+				"data: {\"choices\":[{\"delta\":{\"content\":\"Hel\"}}]}\n\n" +
+				"data: {\"choices\":[{\"delta\":{\"content\":\"lo\"}}]}\n\n" +
+				"data: [DONE]\n\n",
+			want: "Hello",
+		},
+		{
+			name: "anthropic message stream",
+			p:    &AnthropicProvider{},
+			body: "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{}}\n\n" +
+				"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n" +
+				"event: ping\ndata: {\"type\":\"ping\"}\n\n" +
+				"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hel\"}}\n\n" +
+				"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"lo\"}}\n\n" +
+				"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n",
+			want: "Hello",
+		},
+		{
+			name: "anthropic forced output tool stream",
+			p:    &AnthropicProvider{},
+			body: "event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"tool_use\",\"name\":\"structured_output\",\"input\":{}}}\n\n" +
+				"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"input_json_delta\",\"partial_json\":\"{\\\"a\\\":\"}}\n\n" +
+				"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"input_json_delta\",\"partial_json\":\"1}\"}}\n\n",
+			want: `{"a":1}`,
+		},
+		{
+			name:    "anthropic overloaded error event",
+			p:       &AnthropicProvider{},
+			body:    "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n",
+			wantErr: true,
+		},
+		{
+			name: "gemini partial responses",
+			p:    &GeminiProvider{},
+			body: "data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"Hel\"}],\"role\":\"model\"}}]}\n\n" +
+				"data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"lo\"}],\"role\":\"model\"},\"finishReason\":\"STOP\"}]}\n\n",
+			want: "Hello",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deltas []string
+			got, err := tt.p.ParseAPIStream(strings.NewReader(tt.body), func(d string) {
+				deltas = append(deltas, d)
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err=%v, wantErr=%v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Text != tt.want || strings.Join(deltas, "") != tt.want {
+				t.Fatalf("got %q (deltas %q), want %q", got.Text, deltas, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// jsonSchema returns the tool's arguments as a standard JSON Schema object.
+func (t Tool) jsonSchema() map[string]interface{} {
+	if t.Schema != nil {
+		return t.Schema
+	}
+	return standardObjectSchema(t.Properties, t.Required)
+}
+
+// standardObjectSchema converts shorthand properties into a plain JSON
+// Schema object for APIs without a strict mode: only required keys are
+// listed and nullable types become ["type","null"] unions.
+func standardObjectSchema(properties map[string]interface{}, required []string) map[string]interface{} {
+	isRequired := requiredSet(properties, required)
+	req := make([]string, 0, len(properties))
+	props := make(map[string]interface{}, len(properties))
+	for k, v := range properties {
+		if isRequired[k] {
+			req = append(req, k)
+		}
+		if m, ok := v.(map[string]interface{}); ok {
+			props[k] = standardPropertySchema(m)
+		} else {
+			props[k] = v
+		}
+	}
+	sort.Strings(req)
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": props,
+		"required":   req,
+	}
+}
+
+func standardPropertySchema(m map[string]interface{}) map[string]interface{} {
+	t, _ := m["type"].(string)
+	out := make(map[string]interface{}, len(m))
+	switch strings.ToLower(t) {
+	case "object":
+		nested, _ := m["properties"].(map[string]interface{})
+		out = standardObjectSchema(nested, schemaRequired(m))
+	case "array":
+		for k, v := range m {
+			out[k] = v
+		}
+		if items, ok := m["items"].(map[string]interface{}); ok {
+			out["items"] = standardPropertySchema(items)
+		}
+	default:
+		for k, v := range m {
+			out[k] = v
+		}
+	}
+	delete(out, "nullable")
+	if nullable, _ := m["nullable"].(bool); nullable {
+		out["type"] = []interface{}{t, "null"}
+		if values := enumStrings(out["enum"]); len(values) > 0 {
+			enum := make([]interface{}, 0, len(values)+1)
+			for _, v := range values {
+				enum = append(enum, v)
+			}
+			out["enum"] = append(enum, nil)
+		}
+	}
+	return out
+}
+
+// toolChoiceMode classifies opts.ToolChoice, returning the mode and, for a
+// named tool, its name.
+func toolChoiceMode(choice string) (mode, name string) {
+	switch choice {
+	case "", ToolChoiceAuto:
+		return ToolChoiceAuto, ""
+	case ToolChoiceRequired, ToolChoiceNone:
+		return choice, ""
+	default:
+		return "", choice
+	}
+}
+
+// decodeToolArguments parses JSON-encoded call arguments. Empty arguments
+// decode to an empty object.
+func decodeToolArguments(name, raw string) (map[string]interface{}, error) {
+	args := map[string]interface{}{}
+	if strings.TrimSpace(raw) == "" {
+		return args, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &args); err != nil {
+		return nil, fmt.Errorf("invalid arguments for tool %s: %v", name, err)
+	}
+	return args, nil
+}
@@ -0,0 +1,259 @@
+package provider
+
+import (
+	"reflect"
+	"testing"
+)
+
+var testTools = []Tool{
+	{
+		Name:        "get_weather",
+		Description: "Look up the current weather",
+		Properties: map[string]interface{}{
+			"city": map[string]interface{}{"type": "string"},
+			"unit": map[string]interface{}{"type": "string", "enum": []string{"c", "f"}, "nullable": true},
+		},
+		Required: []string{"city"},
+	},
+	{Name: "ping", Properties: map[string]interface{}{}},
+}
+
+func TestStandardObjectSchema(t *testing.T) {
+	got := testTools[0].jsonSchema()
+	want := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"city": map[string]interface{}{"type": "string"},
+			"unit": map[string]interface{}{"type": []interface{}{"string", "null"}, "enum": []interface{}{"c", "f", nil}},
+		},
+		"required": []string{"city"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("schema mismatch:\ngot=%v\nwant=%v", got, want)
+	}
+}
+
+func TestOpenAIProvider_BuildAPIPayload_Tools(t *testing.T) {
+	p := &OpenAIProvider{}
+	payload, err := p.BuildAPIPayload(Options{Model: "gpt-5-nano", Message: "hi", Tools: testTools, ToolChoice: "get_weather"})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	tools := payload["tools"].([]map[string]interface{})
+	if len(tools) != 2 || tools[0]["type"] != "function" || tools[0]["name"] != "get_weather" || tools[0]["strict"] != true {
+		t.Fatalf("tools mismatch: %v", tools)
+	}
+	params := tools[0]["parameters"].(map[string]interface{})
+	if !reflect.DeepEqual(params["required"], []string{"city", "unit"}) || params["additionalProperties"] != false {
+		t.Fatalf("strict parameters mismatch: %v", params)
+	}
+	if _, ok := tools[1]["description"]; ok {
+		t.Fatalf("empty description should be omitted: %v", tools[1])
+	}
+	if !reflect.DeepEqual(payload["tool_choice"], map[string]interface{}{"type": "function", "name": "get_weather"}) {
+		t.Fatalf("tool_choice mismatch: %v", payload["tool_choice"])
+	}
+}
+
+func TestOpenAICompatProvider_BuildAPIPayload_Tools(t *testing.T) {
+	p := &OpenAICompatProvider{}
+	payload, err := p.BuildAPIPayload(Options{Model: "gpt-4o-mini", Message: "hi", Tools: testTools, ToolChoice: ToolChoiceRequired})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	tools := payload["tools"].([]map[string]interface{})
+	fn := tools[0]["function"].(map[string]interface{})
+	if tools[0]["type"] != "function" || fn["name"] != "get_weather" || fn["description"] != "Look up the current weather" {
+		t.Fatalf("tools mismatch: %v", tools)
+	}
+	if payload["tool_choice"] != "required" {
+		t.Fatalf("tool_choice mismatch: %v", payload["tool_choice"])
+	}
+}
+
+func TestAnthropicProvider_BuildAPIPayload_Tools(t *testing.T) {
+	p := &AnthropicProvider{}
+	for choice, want := range map[string]interface{}{
+		"":                 map[string]interface{}{"type": "auto"},
+		ToolChoiceRequired: map[string]interface{}{"type": "any"},
+		ToolChoiceNone:     map[string]interface{}{"type": "none"},
+		"ping":             map[string]interface{}{"type": "tool", "name": "ping"},
+	} {
+		payload, err := p.BuildAPIPayload(Options{Model: "claude-3-5-haiku-latest", MaxTokens: 1024, Message: "hi", Tools: testTools, ToolChoice: choice})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if !reflect.DeepEqual(payload["tool_choice"], want) {
+			t.Fatalf("tool_choice(%q) = %v, want %v", choice, payload["tool_choice"], want)
+		}
+		tools := payload["tools"].([]map[string]interface{})
+		if tools[0]["name"] != "get_weather" || tools[0]["input_schema"] == nil {
+			t.Fatalf("tools mismatch: %v", tools)
+		}
+	}
+}
+
+func TestGeminiProvider_BuildAPIPayload_Tools(t *testing.T) {
+	p := &GeminiProvider{}
+	payload, err := p.BuildAPIPayload(Options{
+		Model:      "gemini-2.0-flash",
+		Message:    "hi",
+		Properties: map[string]interface{}{"answer": map[string]interface{}{"type": "string"}},
+		Tools:      testTools,
+		ToolChoice: "get_weather",
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	decls := payload["tools"].([]map[string]interface{})[0]["functionDeclarations"].([]map[string]interface{})
+	if len(decls) != 2 || decls[0]["name"] != "get_weather" || decls[0]["parameters"] == nil {
+		t.Fatalf("declarations mismatch: %v", decls)
+	}
+	if _, ok := decls[1]["parameters"]; ok {
+		t.Fatalf("tools without arguments should omit parameters: %v", decls[1])
+	}
+	wantCfg := map[string]interface{}{"functionCallingConfig": map[string]interface{}{"mode": "ANY", "allowedFunctionNames": []string{"get_weather"}}}
+	if !reflect.DeepEqual(payload["toolConfig"], wantCfg) {
+		t.Fatalf("toolConfig mismatch: %v", payload["toolConfig"])
+	}
+	// JSON mode cannot be combined with function calling.
+	if _, ok := payload["generationConfig"]; ok {
+		t.Fatalf("generationConfig should not request JSON mode with tools: %v", payload["generationConfig"])
+	}
+	if payload["systemInstruction"] == nil {
+		t.Fatalf("expected the schema hint in systemInstruction")
+	}
+}
+
+func TestToolCallingProviders_ParseToolCalls(t *testing.T) {
+	want := []ToolCall{{ID: "call_1", Name: "get_weather", Arguments: map[string]interface{}{"city": "Paris"}}}
+	tests := []struct {
+		name string
+		p    ToolCallingProvider
+		body string
+		want []ToolCall
+	}{
+		{"openai", &OpenAIProvider{}, `{"output":[{"type":"reasoning"},{"type":"function_call","call_id":"call_1","name":"get_weather","arguments":"{\"city\":\"Paris\"}"}]}`, want},
+		{"openai text", &OpenAIProvider{}, `{"output":[{"type":"message","content":[{"type":"output_text","text":"hi"}]}]}`, nil},
+		{"openai-compat", &OpenAICompatProvider{}, `{"choices":[{"message":{"tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]}}]}`, want},
+		{"anthropic", &AnthropicProvider{}, `{"content":[{"type":"text","text":"Checking."},{"type":"tool_use","id":"call_1","name":"get_weather","input":{"city":"Paris"}}]}`, want},
+		{"gemini", &GeminiProvider{}, `{"candidates":[{"content":{"parts":[{"functionCall":{"id":"call_1","name":"get_weather","args":{"city":"Paris"}}}]}}]}`, want},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.p.ParseToolCalls([]byte(tt.body))
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("calls mismatch:\ngot=%v\nwant=%v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := (&OpenAIProvider{}).ParseToolCalls([]byte(`{"output":[{"type":"function_call","name":"x","arguments":"{bad"}]}`)); err == nil {
+		t.Fatalf("expected error for malformed arguments")
+	}
+}
+
+// toolHistory is a tool round trip: the user asks, the assistant calls two
+// tools, and both results follow.
+var toolHistory = []Turn{
+	{Role: RoleUser, Content: "weather in Paris and Rome?"},
+	{Role: RoleAssistant, ToolCalls: []ToolCall{
+		{ID: "c1", Name: "get_weather", Arguments: map[string]interface{}{"city": "Paris"}},
+		{ID: "c2", Name: "get_weather", Arguments: map[string]interface{}{"city": "Rome"}},
+	}},
+	{Role: RoleTool, ToolCallID: "c1", ToolName: "get_weather", Content: `{"temp":18}`},
+	{Role: RoleTool, ToolCallID: "c2", ToolName: "get_weather", Content: "sunny"},
+}
+
+func TestOpenAIProvider_BuildAPIPayload_ToolHistory(t *testing.T) {
+	p := &OpenAIProvider{}
+	payload, err := p.BuildAPIPayload(Options{Model: "gpt-5-nano", History: toolHistory, Tools: testTools})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	want := []map[string]interface{}{
+		{"role": RoleUser, "content": "weather in Paris and Rome?"},
+		{"type": "function_call", "call_id": "c1", "name": "get_weather", "arguments": `{"city":"Paris"}`},
+		{"type": "function_call", "call_id": "c2", "name": "get_weather", "arguments": `{"city":"Rome"}`},
+		{"type": "function_call_output", "call_id": "c1", "output": `{"temp":18}`},
+		{"type": "function_call_output", "call_id": "c2", "output": "sunny"},
+	}
+	if !reflect.DeepEqual(payload["input"], want) {
+		t.Fatalf("input mismatch:\ngot=%v\nwant=%v", payload["input"], want)
+	}
+}
+
+func TestOpenAICompatProvider_BuildAPIPayload_ToolHistory(t *testing.T) {
+	p := &OpenAICompatProvider{}
+	payload, err := p.BuildAPIPayload(Options{Model: "gpt-4o-mini", History: toolHistory, Message: "thanks", Tools: testTools})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	msgs := payload["messages"].([]map[string]interface{})
+	if len(msgs) != 5 {
+		t.Fatalf("expected 5 messages, got %d: %v", len(msgs), msgs)
+	}
+	calls := msgs[1]["tool_calls"].([]map[string]interface{})
+	if msgs[1]["content"] != nil || len(calls) != 2 || calls[1]["id"] != "c2" ||
+		!reflect.DeepEqual(calls[1]["function"], map[string]interface{}{"name": "get_weather", "arguments": `{"city":"Rome"}`}) {
+		t.Fatalf("assistant message mismatch: %v", msgs[1])
+	}
+	if !reflect.DeepEqual(msgs[2], map[string]interface{}{"role": "tool", "tool_call_id": "c1", "content": `{"temp":18}`}) {
+		t.Fatalf("tool message mismatch: %v", msgs[2])
+	}
+	if msgs[4]["role"] != "user" || msgs[4]["content"] != "thanks" {
+		t.Fatalf("user message mismatch: %v", msgs[4])
+	}
+}
+
+func TestAnthropicProvider_BuildAPIPayload_ToolHistory(t *testing.T) {
+	p := &AnthropicProvider{}
+	payload, err := p.BuildAPIPayload(Options{Model: "claude-sonnet-4-0", MaxTokens: 100, History: toolHistory, Message: "thanks", Tools: testTools})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	msgs := payload["messages"].([]map[string]interface{})
+	if len(msgs) != 3 {
+		t.Fatalf("expected alternating 3 messages, got %d: %v", len(msgs), msgs)
+	}
+	wantCall := []map[string]interface{}{
+		{"type": "tool_use", "id": "c1", "name": "get_weather", "input": map[string]interface{}{"city": "Paris"}},
+		{"type": "tool_use", "id": "c2", "name": "get_weather", "input": map[string]interface{}{"city": "Rome"}},
+	}
+	if msgs[1]["role"] != RoleAssistant || !reflect.DeepEqual(msgs[1]["content"], wantCall) {
+		t.Fatalf("assistant message mismatch: %v", msgs[1])
+	}
+	wantResults := []map[string]interface{}{
+		{"type": "tool_result", "tool_use_id": "c1", "content": `{"temp":18}`},
+		{"type": "tool_result", "tool_use_id": "c2", "content": "sunny"},
+		{"type": "text", "text": "thanks"},
+	}
+	if msgs[2]["role"] != RoleUser || !reflect.DeepEqual(msgs[2]["content"], wantResults) {
+		t.Fatalf("tool results mismatch: %v", msgs[2])
+	}
+}
+
+func TestGeminiProvider_BuildAPIPayload_ToolHistory(t *testing.T) {
+	p := &GeminiProvider{}
+	payload, err := p.BuildAPIPayload(Options{Model: "gemini-2.5-flash", History: toolHistory, Tools: testTools})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	contents := payload["contents"].([]map[string]interface{})
+	if len(contents) != 3 {
+		t.Fatalf("expected 3 contents, got %d: %v", len(contents), contents)
+	}
+	if contents[1]["role"] != "model" || len(contents[1]["parts"].([]map[string]interface{})) != 2 {
+		t.Fatalf("model content mismatch: %v", contents[1])
+	}
+	want := []map[string]interface{}{
+		{"functionResponse": map[string]interface{}{"name": "get_weather", "response": map[string]interface{}{"temp": float64(18)}}},
+		{"functionResponse": map[string]interface{}{"name": "get_weather", "response": map[string]interface{}{"output": "sunny"}}},
+	}
+	if contents[2]["role"] != "user" || !reflect.DeepEqual(contents[2]["parts"], want) {
+		t.Fatalf("function responses mismatch: %v", contents[2])
+	}
+}
@@ -0,0 +1,220 @@
+// Package retry resends HTTP requests that failed transiently: rate limits,
+// overloaded or unavailable servers, and connections that could not be made.
+// Delays grow exponentially with jitter unless the server says how long to
+// wait.
+package retry
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Policy configures retries.
+type Policy struct {
+	// MaxRetries is the number of retries after the first attempt.
+	MaxRetries int
+	// BaseDelay is the backoff before the first retry; it doubles on each
+	// retry up to MaxDelay.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff and the wait a server may ask for. When the
+	// server asks for longer, the failure is returned instead.
+	MaxDelay time.Duration
+	// RetryInterrupted also retries network failures that may have happened
+	// after the server received the request (reset connections, unexpected
+	// EOFs, read timeouts). The server may already have run, and billed,
+	// the request, so these are final unless set.
+	RetryInterrupted bool
+	// Logf, when set, reports each retry.
+	Logf func(format string, args ...interface{})
+}
+
+// Default returns a policy with 2 retries, a 1s base delay and a 60s cap.
+func Default() Policy {
+	return Policy{MaxRetries: 2, BaseDelay: time.Second, MaxDelay: time.Minute}
+}
+
+// Retryable reports whether a response status is worth retrying: request
+// timeouts, rate limits, server errors and Anthropic's 529 (overloaded).
+func Retryable(status int) bool {
+	switch status {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests,
+		http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout, 529:
+		return true
+	}
+	return false
+}
+
+// RetryableError reports whether a transport error is transient: timeouts,
+// refused or reset connections and unexpected EOFs. DNS lookups of unknown
+// hosts and canceled requests are not retried.
+func RetryableError(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return !dnsErr.IsNotFound
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// Unsent reports whether a transport error happened before the request was
+// sent: the connection was refused or could not be dialed, so resending it
+// cannot run the request twice.
+func Unsent(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// Do sends the request returned by newReq, building a fresh one for each
+// attempt, and retries transient failures. It returns the last response
+// (which may be a non-2xx one) or transport error, like http.Client.Do.
+func (p Policy) Do(client *http.Client, newReq func() (*http.Request, error)) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := newReq()
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+
+		var (
+			reason string
+			hint   time.Duration
+		)
+		switch {
+		case err != nil:
+			if !RetryableError(err) || !(p.RetryInterrupted || Unsent(err)) || req.Context().Err() != nil {
+				return nil, err
+			}
+			reason = err.Error()
+		case Retryable(resp.StatusCode):
+			reason = fmt.Sprintf("status %d", resp.StatusCode)
+			hint = Delay(resp, time.Now())
+		default:
+			return resp, nil
+		}
+		if attempt >= p.MaxRetries || hint > p.MaxDelay {
+			if hint > p.MaxDelay && p.Logf != nil {
+				p.Logf("Server asked to wait %s, longer than the %s retry limit", hint.Round(time.Second), p.MaxDelay)
+			}
+			return resp, err
+		}
+		if resp != nil {
+			// Drain so the connection can be reused.
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			_ = resp.Body.Close()
+		}
+
+		wait := hint
+		if wait == 0 {
+			wait = p.backoff(attempt)
+		}
+		if p.Logf != nil {
+			p.Logf("Request failed (%s); retrying in %s (retry %d/%d)", reason, wait.Round(time.Millisecond), attempt+1, p.MaxRetries)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// backoff returns the jittered delay before retry attempt+1: a random value
+// between half and all of BaseDelay*2^attempt, capped at MaxDelay.
+func (p Policy) backoff(attempt int) time.Duration {
+	d := p.BaseDelay << attempt
+	if d <= 0 || d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 1 {
+		return d
+	}
+	return d/2 + rand.N(d/2)
+}
+
+// retryDelayBody matches Gemini's RetryInfo detail, e.g. "retryDelay": "31s".
+var retryDelayBody = regexp.MustCompile(`"retryDelay"\s*:\s*"([0-9.]+s)"`)
+
+// Delay returns how long the server asked the client to wait before
+// retrying, or 0 when it did not say. It reads Retry-After (seconds or an
+// HTTP date), retry-after-ms, the reset times of exhausted OpenAI
+// (x-ratelimit-*) and Anthropic (anthropic-ratelimit-*) limits, and Gemini's
+// RetryInfo.retryDelay in the body. The body is restored for the caller.
+func Delay(resp *http.Response, now time.Time) time.Duration {
+	h := resp.Header
+	if v := h.Get("Retry-After-Ms"); v != "" {
+		if ms, err := strconv.ParseFloat(v, 64); err == nil && ms >= 0 {
+			return time.Duration(ms * float64(time.Millisecond))
+		}
+	}
+	if v := h.Get("Retry-After"); v != "" {
+		if secs, err := strconv.Atoi(strings.TrimSpace(v)); err == nil && secs >= 0 {
+			return time.Duration(secs) * time.Second
+		}
+		if t, err := http.ParseTime(v); err == nil {
+			return positive(t.Sub(now))
+		}
+	}
+
+	// Rate-limit headers: wait until the exhausted limits reset.
+	var wait time.Duration
+	for _, limit := range []string{"requests", "tokens"} {
+		if h.Get("X-Ratelimit-Remaining-"+limit) != "0" {
+			continue
+		}
+		if d, err := time.ParseDuration(h.Get("X-Ratelimit-Reset-" + limit)); err == nil && d > wait {
+			wait = d
+		}
+	}
+	for _, limit := range []string{"requests", "tokens", "input-tokens", "output-tokens"} {
+		if h.Get("Anthropic-Ratelimit-"+limit+"-Remaining") != "0" {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, h.Get("Anthropic-Ratelimit-"+limit+"-Reset")); err == nil && t.Sub(now) > wait {
+			wait = t.Sub(now)
+		}
+	}
+	if wait > 0 || resp.Body == nil {
+		return wait
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+	if err == nil {
+		if m := retryDelayBody.FindSubmatch(body); m != nil {
+			if d, err := time.ParseDuration(string(m[1])); err == nil {
+				return d
+			}
+		}
+	}
+	return 0
+}
+
+func positive(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
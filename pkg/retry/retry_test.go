@@ -0,0 +1,194 @@
+package retry
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// statusServer answers requests with the given statuses in order, then 200.
+func statusServer(t *testing.T, headers http.Header, statuses ...int) (*httptest.Server, *int) {
+	t.Helper()
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != "payload" {
+			t.Errorf("attempt %d sent body %q", calls+1, body)
+		}
+		status := http.StatusOK
+		if calls < len(statuses) {
+			status = statuses[calls]
+		}
+		calls++
+		for k, v := range headers {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, http.StatusText(status))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func newReq(url string) func() (*http.Request, error) {
+	return func() (*http.Request, error) {
+		return http.NewRequest(http.MethodPost, url, strings.NewReader("payload"))
+	}
+}
+
+func TestDo(t *testing.T) {
+	p := Policy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+	var logged []string
+	p.Logf = func(format string, args ...interface{}) { logged = append(logged, format) }
+
+	srv, calls := statusServer(t, nil, 503, 529, 429)
+	resp, err := p.Do(srv.Client(), newReq(srv.URL))
+	if err != nil || resp.StatusCode != 200 || *calls != 4 || len(logged) != 3 {
+		t.Fatalf("Do = %v, %v after %d calls, %d logs", resp, err, *calls, len(logged))
+	}
+	_ = resp.Body.Close()
+
+	srv, calls = statusServer(t, nil, 400)
+	resp, err = p.Do(srv.Client(), newReq(srv.URL))
+	if err != nil || resp.StatusCode != 400 || *calls != 1 {
+		t.Fatalf("fatal status should not be retried: %v, %v after %d calls", resp, err, *calls)
+	}
+	_ = resp.Body.Close()
+
+	srv, calls = statusServer(t, nil, 500, 500, 500, 500, 500)
+	resp, err = p.Do(srv.Client(), newReq(srv.URL))
+	if err != nil || resp.StatusCode != 500 || *calls != 4 {
+		t.Fatalf("expected the last 500 after 4 calls: %v, %v after %d calls", resp, err, *calls)
+	}
+	if body, _ := io.ReadAll(resp.Body); string(body) != "Internal Server Error" {
+		t.Fatalf("last response body should be readable: %q", body)
+	}
+	_ = resp.Body.Close()
+
+	srv, calls = statusServer(t, http.Header{"Retry-After": {"120"}}, 429)
+	resp, err = p.Do(srv.Client(), newReq(srv.URL))
+	if err != nil || resp.StatusCode != 429 || *calls != 1 {
+		t.Fatalf("a wait beyond MaxDelay should give up: %v, %v after %d calls", resp, err, *calls)
+	}
+	_ = resp.Body.Close()
+
+	srv.Close()
+	logged = nil
+	if _, err := p.Do(srv.Client(), newReq(srv.URL)); err == nil || len(logged) != 3 {
+		t.Fatalf("a refused connection should be retried: %v, %d logs", err, len(logged))
+	}
+}
+
+func TestDelay(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name    string
+		headers map[string]string
+		body    string
+		want    time.Duration
+	}{
+		{"none", nil, "", 0},
+		{"retry-after seconds", map[string]string{"Retry-After": "7"}, "", 7 * time.Second},
+		{"retry-after date", map[string]string{"Retry-After": now.Add(90 * time.Second).Format(http.TimeFormat)}, "", 90 * time.Second},
+		{"retry-after-ms", map[string]string{"Retry-After-Ms": "250", "Retry-After": "1"}, "", 250 * time.Millisecond},
+		{"openai exhausted limit", map[string]string{
+			"X-Ratelimit-Remaining-Requests": "10", "X-Ratelimit-Reset-Requests": "1m0s",
+			"X-Ratelimit-Remaining-Tokens": "0", "X-Ratelimit-Reset-Tokens": "6.5s",
+		}, "", 6500 * time.Millisecond},
+		{"anthropic exhausted limit", map[string]string{
+			"Anthropic-Ratelimit-Requests-Remaining": "0",
+			"Anthropic-Ratelimit-Requests-Reset":     now.Add(12 * time.Second).Format(time.RFC3339),
+		}, "", 12 * time.Second},
+		{"gemini retry info", nil, `{"error":{"details":[{"@type":"type.googleapis.com/google.rpc.RetryInfo","retryDelay": "31s"}]}}`, 31 * time.Second},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{Header: http.Header{}, Body: io.NopCloser(strings.NewReader(tt.body))}
+		for k, v := range tt.headers {
+			resp.Header.Set(k, v)
+		}
+		if got := Delay(resp, now); got != tt.want {
+			t.Errorf("%s: Delay = %v, want %v", tt.name, got, tt.want)
+		}
+		if body, _ := io.ReadAll(resp.Body); string(body) != tt.body {
+			t.Errorf("%s: body not restored: %q", tt.name, body)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := Policy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	for attempt, max := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		for i := 0; i < 20; i++ {
+			if d := p.backoff(attempt); d < max/2 || d > max {
+				t.Fatalf("backoff(%d) = %v, want within [%v, %v]", attempt, d, max/2, max)
+			}
+		}
+	}
+	if d := p.backoff(100); d < p.MaxDelay/2 || d > p.MaxDelay {
+		t.Fatalf("overflowing backoff should be capped: %v", d)
+	}
+}
+
+func TestDo_Interrupted(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		_, _ = io.ReadAll(r.Body)
+		if calls == 1 {
+			// Drop the connection after the request arrived.
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Errorf("hijack: %v", err)
+				return
+			}
+			_ = conn.Close()
+			return
+		}
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	p := Policy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+	if _, err := p.Do(srv.Client(), newReq(srv.URL)); err == nil || calls != 1 {
+		t.Fatalf("a request cut off after sending should not be resent: %v after %d calls", err, calls)
+	}
+
+	calls = 0
+	p.RetryInterrupted = true
+	resp, err := p.Do(srv.Client(), newReq(srv.URL))
+	if err != nil || resp.StatusCode != 200 || calls != 2 {
+		t.Fatalf("RetryInterrupted should resend: %v, %v after %d calls", resp, err, calls)
+	}
+	_ = resp.Body.Close()
+}
+
+func TestUnsent(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	reset := &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+	for err, want := range map[error]bool{
+		refused: true,
+		&url.Error{Op: "Post", URL: "http://x", Err: refused}: true,
+		reset:               false,
+		io.ErrUnexpectedEOF: false,
+		&url.Error{Op: "Post", URL: "http://x", Err: io.EOF}: false,
+	} {
+		if Unsent(err) != want {
+			t.Errorf("Unsent(%v) = %v", err, !want)
+		}
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	for status, want := range map[int]bool{429: true, 500: true, 502: true, 503: true, 529: true, 400: false, 401: false, 404: false} {
+		if Retryable(status) != want {
+			t.Errorf("Retryable(%d) = %v", status, !want)
+		}
+	}
+}
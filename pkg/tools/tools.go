@@ -0,0 +1,140 @@
+// Package tools loads tool (function) declarations for tool calling, either
+// from --tool specs or from a JSON tools file.
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"llmx/pkg/parser"
+	"llmx/pkg/provider"
+	"llmx/pkg/validator"
+)
+
+// namePattern is the tool name syntax accepted by every provider.
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Definition is a tool entry in a tools file.
+type Definition struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Format describes the arguments object with --format shorthand.
+	Format string `json:"format,omitempty"`
+	// Parameters is a JSON Schema for the arguments object. It replaces
+	// Format.
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// Tool converts the definition into a provider.Tool, parsing its arguments
+// description.
+func (d Definition) Tool() (provider.Tool, error) {
+	if !namePattern.MatchString(d.Name) {
+		return provider.Tool{}, fmt.Errorf("invalid tool name %q (use letters, digits, _ or -, up to 64 characters)", d.Name)
+	}
+	t := provider.Tool{Name: d.Name, Description: strings.TrimSpace(d.Description)}
+	if d.Parameters != nil {
+		if d.Format != "" {
+			return provider.Tool{}, fmt.Errorf("tool %s: use either format or parameters, not both", d.Name)
+		}
+		if typ, _ := d.Parameters["type"].(string); typ != "object" {
+			return provider.Tool{}, fmt.Errorf("tool %s: parameters must have \"type\": \"object\"", d.Name)
+		}
+		t.Schema = d.Parameters
+		t.Properties, _ = d.Parameters["properties"].(map[string]interface{})
+		t.Required = parser.RequiredKeys(d.Parameters)
+		return t, nil
+	}
+	schema, err := parser.ParseFormatSchema(d.Format)
+	if err != nil {
+		return provider.Tool{}, fmt.Errorf("tool %s: failed to parse format: %w", d.Name, err)
+	}
+	t.Properties = schema["properties"].(map[string]interface{})
+	t.Required = schema["required"].([]string)
+	return t, nil
+}
+
+// ParseSpec parses a --tool value of the form "name" (no arguments) or
+// "name=params", where params is --format shorthand, or a JSON Schema given
+// as @file or inline JSON.
+func ParseSpec(spec string) (Definition, error) {
+	name, params, _ := strings.Cut(spec, "=")
+	d := Definition{Name: strings.TrimSpace(name)}
+	params = strings.TrimSpace(params)
+	if strings.HasPrefix(params, "@") || strings.HasPrefix(params, "{") {
+		schema, err := parser.LoadSchema(params)
+		if err != nil {
+			return Definition{}, fmt.Errorf("tool %s: %w", d.Name, err)
+		}
+		d.Parameters = schema
+	} else {
+		d.Format = params
+	}
+	return d, nil
+}
+
+// LoadFile reads tool definitions from a JSON file holding an array of
+// definitions or an object with a "tools" array.
+func LoadFile(path string) ([]Definition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tools file: %w", err)
+	}
+	data = bytes.TrimSpace(data)
+	var defs []Definition
+	if bytes.HasPrefix(data, []byte("{")) {
+		var wrapper struct {
+			Tools []Definition `json:"tools"`
+		}
+		if err := json.Unmarshal(data, &wrapper); err != nil {
+			return nil, fmt.Errorf("invalid tools file: %w", err)
+		}
+		defs = wrapper.Tools
+	} else if err := json.Unmarshal(data, &defs); err != nil {
+		return nil, fmt.Errorf("invalid tools file: %w", err)
+	}
+	if len(defs) == 0 {
+		return nil, fmt.Errorf("tools file %s declares no tools", path)
+	}
+	return defs, nil
+}
+
+// Build converts definitions into provider tools, rejecting duplicate names.
+func Build(defs []Definition) ([]provider.Tool, error) {
+	out := make([]provider.Tool, 0, len(defs))
+	seen := map[string]bool{}
+	for _, d := range defs {
+		t, err := d.Tool()
+		if err != nil {
+			return nil, err
+		}
+		if seen[t.Name] {
+			return nil, fmt.Errorf("duplicate tool name %q", t.Name)
+		}
+		seen[t.Name] = true
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// ValidateCall checks a tool call against the declared tools: the tool must
+// exist and its arguments must match the tool's schema.
+func ValidateCall(tools []provider.Tool, call provider.ToolCall) error {
+	for _, t := range tools {
+		if t.Name != call.Name {
+			continue
+		}
+		schema := t.Schema
+		if schema == nil {
+			schema = validator.ObjectSchema(t.Properties, t.Required)
+		}
+		if verrs := validator.Validate(schema, call.Arguments); len(verrs) > 0 {
+			return verrs
+		}
+		return nil
+	}
+	return fmt.Errorf("model called undeclared tool %q", call.Name)
+}
@@ -0,0 +1,113 @@
+package tools
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"llmx/pkg/provider"
+	"llmx/pkg/validator"
+)
+
+func TestParseSpec(t *testing.T) {
+	d, err := ParseSpec("get_weather=city:string,unit?:enum(c|f)")
+	if err != nil || d.Name != "get_weather" || d.Format != "city:string,unit?:enum(c|f)" {
+		t.Fatalf("ParseSpec(shorthand) = %+v, %v", d, err)
+	}
+	tool, err := d.Tool()
+	if err != nil || !reflect.DeepEqual(tool.Required, []string{"city"}) || len(tool.Properties) != 2 {
+		t.Fatalf("Tool() = %+v, %v", tool, err)
+	}
+
+	d, err = ParseSpec(`lookup={"type":"object","properties":{"sku":{"type":"string"}},"required":["sku"]}`)
+	if err != nil || d.Parameters == nil {
+		t.Fatalf("ParseSpec(inline schema) = %+v, %v", d, err)
+	}
+
+	d, err = ParseSpec("ping")
+	if err != nil {
+		t.Fatalf("ParseSpec(no params) error: %v", err)
+	}
+	if tool, err := d.Tool(); err != nil || len(tool.Properties) != 0 {
+		t.Fatalf("Tool() = %+v, %v", tool, err)
+	}
+
+	for _, spec := range []string{"=a:string", "bad name=a", "x=a:{", `x={"type":"string"}`} {
+		d, err := ParseSpec(spec)
+		if err == nil {
+			_, err = d.Tool()
+		}
+		if err == nil {
+			t.Errorf("ParseSpec(%q): expected error", spec)
+		}
+	}
+}
+
+func TestLoadFileAndBuild(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tools.json")
+	data := `{"tools":[
+  {"name":"get_weather","description":"Weather by city","format":"city:string"},
+  {"name":"lookup","parameters":{"type":"object","properties":{"sku":{"type":"string","pattern":"^[A-Z]+$"}},"required":["sku"]}}
+]}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	defs, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile: %v", err)
+	}
+	tools, err := Build(defs)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if len(tools) != 2 || tools[0].Description != "Weather by city" || tools[1].Schema == nil {
+		t.Fatalf("tools mismatch: %+v", tools)
+	}
+
+	if _, err := Build(append(defs, defs[0])); err == nil {
+		t.Fatalf("expected duplicate name error")
+	}
+	bad := Definition{Name: "x", Format: "a", Parameters: map[string]interface{}{"type": "object"}}
+	if _, err := Build([]Definition{bad}); err == nil {
+		t.Fatalf("expected error for format and parameters together")
+	}
+
+	empty := filepath.Join(dir, "empty.json")
+	if err := os.WriteFile(empty, []byte("[]"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := LoadFile(empty); err == nil {
+		t.Fatalf("expected error for empty tools file")
+	}
+}
+
+func TestValidateCall(t *testing.T) {
+	tools, err := Build([]Definition{
+		{Name: "get_weather", Format: "city:string"},
+		{Name: "lookup", Parameters: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"sku": map[string]interface{}{"type": "string", "pattern": "^[A-Z]+$"}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	if err := ValidateCall(tools, provider.ToolCall{Name: "get_weather", Arguments: map[string]interface{}{"city": "Paris"}}); err != nil {
+		t.Fatalf("valid call rejected: %v", err)
+	}
+	var verrs validator.Errors
+	err = ValidateCall(tools, provider.ToolCall{Name: "get_weather", Arguments: map[string]interface{}{}})
+	if !errors.As(err, &verrs) {
+		t.Fatalf("expected validation errors for missing argument, got %v", err)
+	}
+	if err := ValidateCall(tools, provider.ToolCall{Name: "lookup", Arguments: map[string]interface{}{"sku": "abc"}}); !errors.As(err, &verrs) {
+		t.Fatalf("expected pattern violation, got %v", err)
+	}
+	if err := ValidateCall(tools, provider.ToolCall{Name: "nope"}); err == nil {
+		t.Fatalf("expected error for undeclared tool")
+	}
+}
@@ -0,0 +1,232 @@
+// Package transcript loads conversation transcripts (system, user and
+// assistant turns) from JSON or JSONL files.
+package transcript
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"llmx/pkg/provider"
+)
+
+// message is the on-disk shape of a single turn. Content may be a string or
+// a list of {"type":"text","text":"..."} parts.
+type message struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+// Load reads a transcript file. Accepted layouts:
+//   - a JSON array of {"role","content"} objects
+//   - a JSON object with a "messages" array
+//   - JSONL with one {"role","content"} object per line
+func Load(path string) ([]provider.Turn, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transcript: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse decodes transcript bytes in any layout accepted by Load.
+func Parse(data []byte) ([]provider.Turn, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, fmt.Errorf("transcript is empty")
+	}
+
+	var msgs []message
+	switch {
+	case trimmed[0] == '[':
+		if err := json.Unmarshal(trimmed, &msgs); err != nil {
+			return nil, fmt.Errorf("invalid transcript JSON: %w", err)
+		}
+	case trimmed[0] == '{' && isSingleJSONValue(trimmed):
+		var wrapper struct {
+			Messages []message `json:"messages"`
+		}
+		if err := json.Unmarshal(trimmed, &wrapper); err != nil {
+			return nil, fmt.Errorf("invalid transcript JSON: %w", err)
+		}
+		if wrapper.Messages == nil {
+			// A one-line JSONL transcript.
+			var m message
+			if err := json.Unmarshal(trimmed, &m); err != nil {
+				return nil, fmt.Errorf("invalid transcript JSON: %w", err)
+			}
+			msgs = []message{m}
+		} else {
+			msgs = wrapper.Messages
+		}
+	default:
+		sc := bufio.NewScanner(bytes.NewReader(trimmed))
+		sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		for line := 1; sc.Scan(); line++ {
+			text := strings.TrimSpace(sc.Text())
+			if text == "" {
+				continue
+			}
+			var m message
+			if err := json.Unmarshal([]byte(text), &m); err != nil {
+				return nil, fmt.Errorf("invalid transcript JSONL at line %d: %w", line, err)
+			}
+			msgs = append(msgs, m)
+		}
+		if err := sc.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read transcript: %w", err)
+		}
+	}
+
+	turns := make([]provider.Turn, 0, len(msgs))
+	for i, m := range msgs {
+		role, err := normalizeRole(m.Role)
+		if err != nil {
+			return nil, fmt.Errorf("message %d: %w", i+1, err)
+		}
+		content, err := decodeContent(m.Content)
+		if err != nil {
+			return nil, fmt.Errorf("message %d: %w", i+1, err)
+		}
+		turns = append(turns, provider.Turn{Role: role, Content: content})
+	}
+	if len(turns) == 0 {
+		return nil, fmt.Errorf("transcript has no messages")
+	}
+	return turns, nil
+}
+
+// normalizeRole maps common role aliases onto provider roles.
+func normalizeRole(role string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(role)) {
+	case "system", "developer":
+		return provider.RoleSystem, nil
+	case "user", "human":
+		return provider.RoleUser, nil
+	case "assistant", "model", "ai":
+		return provider.RoleAssistant, nil
+	case "":
+		return "", fmt.Errorf("missing role")
+	default:
+		return "", fmt.Errorf("unsupported role %q (use system, user or assistant)", role)
+	}
+}
+
+func decodeContent(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 {
+		return "", fmt.Errorf("missing content")
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s, nil
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return "", fmt.Errorf("content must be a string or a list of text parts")
+	}
+	texts := make([]string, 0, len(parts))
+	for _, p := range parts {
+		switch p.Type {
+		case "text", "input_text", "output_text", "":
+			texts = append(texts, p.Text)
+		default:
+			return "", fmt.Errorf("unsupported content part type %q", p.Type)
+		}
+	}
+	return strings.Join(texts, "\n"), nil
+}
+
+// isSingleJSONValue reports whether data holds exactly one JSON value, to tell
+// a wrapper object apart from JSONL.
+func isSingleJSONValue(data []byte) bool {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	var v json.RawMessage
+	if err := dec.Decode(&v); err != nil {
+		return false
+	}
+	return !dec.More()
+}
+
+// Session is a saved chat: its turns plus the settings that produced them.
+// Its JSON form is a {"messages":[...]} object, so a saved session can also
+// be passed to --messages.
+type Session struct {
+	Provider     string          `json:"provider,omitempty"`
+	Model        string          `json:"model,omitempty"`
+	Instructions string          `json:"instructions,omitempty"`
+	Format       string          `json:"format,omitempty"`
+	Messages     []provider.Turn `json:"messages"`
+}
+
+// LoadSession reads a session written by SaveSession. Plain transcripts in
+// any layout accepted by Load are also accepted and yield a session without
+// settings.
+func LoadSession(path string) (*Session, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session: %w", err)
+	}
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' && isSingleJSONValue(trimmed) {
+		var raw struct {
+			Provider     string            `json:"provider"`
+			Model        string            `json:"model"`
+			Instructions string            `json:"instructions"`
+			Format       string            `json:"format"`
+			Messages     []json.RawMessage `json:"messages"`
+		}
+		if err := json.Unmarshal(trimmed, &raw); err != nil {
+			return nil, fmt.Errorf("invalid session JSON: %w", err)
+		}
+		if raw.Messages != nil {
+			s := &Session{
+				Provider:     raw.Provider,
+				Model:        raw.Model,
+				Instructions: raw.Instructions,
+				Format:       raw.Format,
+				Messages:     []provider.Turn{},
+			}
+			// A session saved right after /reset has no messages yet.
+			if len(raw.Messages) > 0 {
+				if s.Messages, err = Parse(trimmed); err != nil {
+					return nil, err
+				}
+			}
+			return s, nil
+		}
+	}
+	turns, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return &Session{Messages: turns}, nil
+}
+
+// SaveSession writes s as indented JSON, creating parent directories as
+// needed. The file is private to the user since it may contain prompts.
+func SaveSession(path string, s *Session) error {
+	out := *s
+	if out.Messages == nil {
+		out.Messages = []provider.Turn{}
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return fmt.Errorf("failed to create session directory: %w", err)
+		}
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	return nil
+}
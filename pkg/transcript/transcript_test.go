@@ -0,0 +1,117 @@
+package transcript
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"llmx/pkg/provider"
+)
+
+func TestParse(t *testing.T) {
+	want := []provider.Turn{
+		{Role: provider.RoleSystem, Content: "be brief"},
+		{Role: provider.RoleUser, Content: "Hi"},
+		{Role: provider.RoleAssistant, Content: "Hello!"},
+	}
+	tests := []struct {
+		name    string
+		data    string
+		want    []provider.Turn
+		wantErr bool
+	}{
+		{
+			name: "json array",
+			data: `[{"role":"system","content":"be brief"},{"role":"user","content":"Hi"},{"role":"assistant","content":"Hello!"}]`,
+			want: want,
+		},
+		{
+			name: "messages wrapper with aliases and text parts",
+			data: `{"messages":[{"role":"developer","content":"be brief"},{"role":"user","content":[{"type":"text","text":"Hi"}]},{"role":"model","content":"Hello!"}]}`,
+			want: want,
+		},
+		{
+			name: "jsonl with blank lines",
+			data: "{\"role\":\"system\",\"content\":\"be brief\"}\n\n{\"role\":\"user\",\"content\":\"Hi\"}\n{\"role\":\"assistant\",\"content\":\"Hello!\"}\n",
+			want: want,
+		},
+		{
+			name: "single jsonl line",
+			data: `{"role":"user","content":"Hi"}`,
+			want: []provider.Turn{{Role: provider.RoleUser, Content: "Hi"}},
+		},
+		{name: "empty", data: "  ", wantErr: true},
+		{name: "empty array", data: "[]", wantErr: true},
+		{name: "unknown role", data: `[{"role":"tool","content":"x"}]`, wantErr: true},
+		{name: "missing content", data: `[{"role":"user"}]`, wantErr: true},
+		{name: "image part", data: `[{"role":"user","content":[{"type":"image_url"}]}]`, wantErr: true},
+		{name: "bad jsonl line", data: "{\"role\":\"user\",\"content\":\"Hi\"}\nnot json\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err=%v, wantErr=%v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("turns mismatch:\ngot=%v\nwant=%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chat.jsonl")
+	if err := os.WriteFile(path, []byte(`{"role":"user","content":"Hi"}`+"\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	turns, err := Load(path)
+	if err != nil || len(turns) != 1 {
+		t.Fatalf("Load() = %v, %v", turns, err)
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Fatalf("expected error for missing file")
+	}
+}
+
+func TestSaveLoadSession(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "s.json")
+	in := &Session{
+		Provider: "anthropic",
+		Model:    "claude-test",
+		Format:   "answer",
+		Messages: []provider.Turn{{Role: provider.RoleUser, Content: "Hi"}, {Role: provider.RoleAssistant, Content: "Hello"}},
+	}
+	if err := SaveSession(path, in); err != nil {
+		t.Fatalf("SaveSession: %v", err)
+	}
+	got, err := LoadSession(path)
+	if err != nil {
+		t.Fatalf("LoadSession: %v", err)
+	}
+	if !reflect.DeepEqual(got, in) {
+		t.Fatalf("round trip mismatch:\ngot=%+v\nwant=%+v", got, in)
+	}
+	// A saved session is also a valid --messages transcript.
+	if turns, err := Load(path); err != nil || len(turns) != 2 {
+		t.Fatalf("Load(session) = %v, %v", turns, err)
+	}
+
+	// Empty sessions round-trip too.
+	if err := SaveSession(path, &Session{Provider: "gemini"}); err != nil {
+		t.Fatalf("SaveSession: %v", err)
+	}
+	if got, err := LoadSession(path); err != nil || got.Provider != "gemini" || len(got.Messages) != 0 {
+		t.Fatalf("LoadSession(empty) = %+v, %v", got, err)
+	}
+
+	// Plain transcripts load without settings.
+	plain := filepath.Join(t.TempDir(), "t.jsonl")
+	if err := os.WriteFile(plain, []byte(`{"role":"user","content":"Hi"}`+"\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if got, err := LoadSession(plain); err != nil || got.Provider != "" || len(got.Messages) != 1 {
+		t.Fatalf("LoadSession(plain) = %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,504 @@
+// Package validator checks decoded JSON values against the JSON Schema
+// subset produced by the --format shorthand or loaded via --schema.
+package validator
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Error is a single schema violation located by a JSON pointer (RFC 6901).
+type Error struct {
+	Pointer string
+	Message string
+}
+
+func (e Error) Error() string {
+	p := e.Pointer
+	if p == "" {
+		p = "/"
+	}
+	return p + ": " + e.Message
+}
+
+// Errors is a list of violations. It implements error so callers can return
+// it directly; a nil or empty Errors means the value is valid.
+type Errors []Error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ObjectSchema wraps a properties map into a root object schema. A nil
+// required marks every key required. Unknown keys are rejected at every depth,
+// matching the strict behavior providers are asked for, unless a nested
+// object sets additionalProperties itself. Keys that are not required may
+// also be null at every depth, since strict providers send every key and
+// fill the optional ones with null.
+func ObjectSchema(properties map[string]interface{}, required []string) map[string]interface{} {
+	return optionalNullable(properties, required)
+}
+
+// optionalNullable returns the closed object schema of properties with the
+// non-required keys marked nullable, recursing into nested objects and array
+// items. properties is not modified.
+func optionalNullable(properties map[string]interface{}, required []string) map[string]interface{} {
+	if required == nil {
+		required = make([]string, 0, len(properties))
+		for k := range properties {
+			required = append(required, k)
+		}
+		sort.Strings(required)
+	}
+	isRequired := make(map[string]bool, len(required))
+	for _, k := range required {
+		isRequired[k] = true
+	}
+	props := make(map[string]interface{}, len(properties))
+	for k, v := range properties {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			props[k] = v
+			continue
+		}
+		m = nestedNullable(m)
+		if !isRequired[k] {
+			m = copySchema(m)
+			m["nullable"] = true
+		}
+		props[k] = m
+	}
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           props,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+// nestedNullable applies optionalNullable to m when it is an object with
+// properties, or to its items when it is an array.
+func nestedNullable(m map[string]interface{}) map[string]interface{} {
+	if nested, ok := m["properties"].(map[string]interface{}); ok {
+		obj := optionalNullable(nested, stringList(m["required"]))
+		out := copySchema(m)
+		out["properties"], out["required"] = obj["properties"], obj["required"]
+		if _, ok := out["additionalProperties"]; !ok {
+			out["additionalProperties"] = false
+		}
+		return out
+	}
+	if items, ok := m["items"].(map[string]interface{}); ok {
+		out := copySchema(m)
+		out["items"] = nestedNullable(items)
+		return out
+	}
+	return m
+}
+
+func copySchema(m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m)+1)
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+// Validate checks value against schema and returns every violation found,
+// ordered by pointer. Local $refs ("#/$defs/..." and "#/definitions/...") are
+// resolved against schema. Unknown keywords are ignored.
+func Validate(schema map[string]interface{}, value interface{}) Errors {
+	v := &validation{root: schema}
+	v.check(schema, value, "", 0)
+	sort.SliceStable(v.errs, func(i, j int) bool { return v.errs[i].Pointer < v.errs[j].Pointer })
+	return v.errs
+}
+
+// maxRefDepth bounds $ref expansion for recursive schemas.
+const maxRefDepth = 64
+
+type validation struct {
+	root map[string]interface{}
+	errs Errors
+}
+
+func (v *validation) fail(ptr, format string, args ...interface{}) {
+	v.errs = append(v.errs, Error{Pointer: ptr, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validation) check(schema map[string]interface{}, value interface{}, ptr string, depth int) {
+	if ref, ok := schema["$ref"].(string); ok {
+		if depth >= maxRefDepth {
+			v.fail(ptr, "schema reference %s nests too deeply", ref)
+			return
+		}
+		def, ok := v.resolve(ref)
+		if !ok {
+			v.fail(ptr, "unresolved schema reference %s", ref)
+			return
+		}
+		v.check(def, value, ptr, depth+1)
+		return
+	}
+
+	types, nullable := schemaTypes(schema)
+	if value == nil && nullable {
+		return
+	}
+	if len(types) > 0 && !matchesAnyType(types, value) {
+		v.fail(ptr, "expected %s, got %s", strings.Join(types, " or "), jsonType(value))
+		return
+	}
+
+	if enum, ok := schema["enum"]; ok && !inEnum(enum, value) {
+		v.fail(ptr, "value %s is not one of %s", encode(value), describeEnum(enum))
+	}
+	if c, ok := schema["const"]; ok && !jsonEqual(c, value) {
+		v.fail(ptr, "value %s must equal %s", encode(value), encode(c))
+	}
+
+	switch tv := value.(type) {
+	case map[string]interface{}:
+		v.checkObject(schema, tv, ptr, depth)
+	case []interface{}:
+		v.checkArray(schema, tv, ptr, depth)
+	case string:
+		v.checkString(schema, tv, ptr)
+	case float64:
+		v.checkNumber(schema, tv, ptr)
+	}
+
+	for _, sub := range schemaList(schema["allOf"]) {
+		v.check(sub, value, ptr, depth)
+	}
+	if anyOf := schemaList(schema["anyOf"]); len(anyOf) > 0 && v.countMatches(anyOf, value, ptr, depth) == 0 {
+		v.fail(ptr, "value does not match any allowed schema")
+	}
+	if oneOf := schemaList(schema["oneOf"]); len(oneOf) > 0 {
+		if n := v.countMatches(oneOf, value, ptr, depth); n != 1 {
+			v.fail(ptr, "value must match exactly one schema, matched %d", n)
+		}
+	}
+}
+
+func (v *validation) countMatches(schemas []map[string]interface{}, value interface{}, ptr string, depth int) int {
+	n := 0
+	for _, sub := range schemas {
+		trial := &validation{root: v.root}
+		trial.check(sub, value, ptr, depth)
+		if len(trial.errs) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func (v *validation) checkObject(schema map[string]interface{}, obj map[string]interface{}, ptr string, depth int) {
+	props, _ := schema["properties"].(map[string]interface{})
+	for _, k := range stringList(schema["required"]) {
+		if _, ok := obj[k]; !ok {
+			v.fail(ptr+"/"+escapePointer(k), "required property is missing")
+		}
+	}
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		childPtr := ptr + "/" + escapePointer(k)
+		if ps, ok := props[k].(map[string]interface{}); ok {
+			v.check(ps, obj[k], childPtr, depth)
+			continue
+		}
+		switch ap := schema["additionalProperties"].(type) {
+		case bool:
+			if !ap {
+				v.fail(childPtr, "unexpected property")
+			}
+		case map[string]interface{}:
+			v.check(ap, obj[k], childPtr, depth)
+		}
+	}
+
+	if n, ok := number(schema["minProperties"]); ok && float64(len(obj)) < n {
+		v.fail(ptr, "expected at least %v properties, got %d", n, len(obj))
+	}
+	if n, ok := number(schema["maxProperties"]); ok && float64(len(obj)) > n {
+		v.fail(ptr, "expected at most %v properties, got %d", n, len(obj))
+	}
+}
+
+func (v *validation) checkArray(schema map[string]interface{}, arr []interface{}, ptr string, depth int) {
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		for i, e := range arr {
+			v.check(items, e, fmt.Sprintf("%s/%d", ptr, i), depth)
+		}
+	}
+	if n, ok := number(schema["minItems"]); ok && float64(len(arr)) < n {
+		v.fail(ptr, "expected at least %v items, got %d", n, len(arr))
+	}
+	if n, ok := number(schema["maxItems"]); ok && float64(len(arr)) > n {
+		v.fail(ptr, "expected at most %v items, got %d", n, len(arr))
+	}
+	if unique, _ := schema["uniqueItems"].(bool); unique {
+		for i := range arr {
+			for j := i + 1; j < len(arr); j++ {
+				if jsonEqual(arr[i], arr[j]) {
+					v.fail(fmt.Sprintf("%s/%d", ptr, j), "duplicate of item %d", i)
+				}
+			}
+		}
+	}
+}
+
+func (v *validation) checkString(schema map[string]interface{}, s string, ptr string) {
+	length := float64(len([]rune(s)))
+	if n, ok := number(schema["minLength"]); ok && length < n {
+		v.fail(ptr, "expected at least %v characters, got %v", n, length)
+	}
+	if n, ok := number(schema["maxLength"]); ok && length > n {
+		v.fail(ptr, "expected at most %v characters, got %v", n, length)
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			v.fail(ptr, "invalid pattern %q in schema: %v", pattern, err)
+		} else if !re.MatchString(s) {
+			v.fail(ptr, "value %q does not match pattern %q", s, pattern)
+		}
+	}
+}
+
+func (v *validation) checkNumber(schema map[string]interface{}, f float64, ptr string) {
+	if n, ok := number(schema["minimum"]); ok && f < n {
+		v.fail(ptr, "value %v is less than minimum %v", f, n)
+	}
+	if n, ok := number(schema["maximum"]); ok && f > n {
+		v.fail(ptr, "value %v is greater than maximum %v", f, n)
+	}
+	if n, ok := number(schema["exclusiveMinimum"]); ok && f <= n {
+		v.fail(ptr, "value %v must be greater than %v", f, n)
+	}
+	if n, ok := number(schema["exclusiveMaximum"]); ok && f >= n {
+		v.fail(ptr, "value %v must be less than %v", f, n)
+	}
+	if n, ok := number(schema["multipleOf"]); ok && n > 0 {
+		if q := f / n; math.Abs(q-math.Round(q)) > 1e-9 {
+			v.fail(ptr, "value %v is not a multiple of %v", f, n)
+		}
+	}
+}
+
+func (v *validation) resolve(ref string) (map[string]interface{}, bool) {
+	for _, prefix := range []string{"#/$defs/", "#/definitions/"} {
+		if !strings.HasPrefix(ref, prefix) {
+			continue
+		}
+		defs, _ := v.root[strings.TrimSuffix(strings.TrimPrefix(prefix, "#/"), "/")].(map[string]interface{})
+		def, ok := defs[strings.TrimPrefix(ref, prefix)].(map[string]interface{})
+		return def, ok
+	}
+	if ref == "#" {
+		return v.root, true
+	}
+	return nil, false
+}
+
+// schemaTypes returns the non-null types a schema allows and whether null is
+// allowed, accepting both ["t","null"] unions and the nullable keyword.
+func schemaTypes(schema map[string]interface{}) ([]string, bool) {
+	nullable, _ := schema["nullable"].(bool)
+	var types []string
+	switch t := schema["type"].(type) {
+	case string:
+		types = []string{t}
+	case []string:
+		types = t
+	case []interface{}:
+		for _, e := range t {
+			if s, ok := e.(string); ok {
+				types = append(types, s)
+			}
+		}
+	}
+	out := types[:0:0]
+	for _, t := range types {
+		if t == "null" {
+			nullable = true
+			continue
+		}
+		out = append(out, t)
+	}
+	if len(out) == 0 && len(types) > 0 {
+		// A bare "null" type only admits null.
+		return []string{"null"}, true
+	}
+	return out, nullable
+}
+
+func matchesAnyType(types []string, value interface{}) bool {
+	for _, t := range types {
+		if matchesType(t, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesType(t string, value interface{}) bool {
+	switch strings.ToLower(t) {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		f, ok := value.(float64)
+		return ok && f == math.Trunc(f)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "null":
+		return value == nil
+	default:
+		// Unknown types are not enforced.
+		return true
+	}
+}
+
+func jsonType(value interface{}) string {
+	switch tv := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case float64:
+		if tv == math.Trunc(tv) {
+			return "integer"
+		}
+		return "number"
+	case bool:
+		return "boolean"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func inEnum(enum interface{}, value interface{}) bool {
+	switch vs := enum.(type) {
+	case []string:
+		s, ok := value.(string)
+		if !ok {
+			return false
+		}
+		for _, e := range vs {
+			if e == s {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, e := range vs {
+			if jsonEqual(e, value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func describeEnum(enum interface{}) string {
+	switch vs := enum.(type) {
+	case []string:
+		return strings.Join(vs, "|")
+	case []interface{}:
+		parts := make([]string, len(vs))
+		for i, e := range vs {
+			if s, ok := e.(string); ok {
+				parts[i] = s
+			} else {
+				parts[i] = encode(e)
+			}
+		}
+		return strings.Join(parts, "|")
+	default:
+		return encode(enum)
+	}
+}
+
+func schemaList(v interface{}) []map[string]interface{} {
+	list, _ := v.([]interface{})
+	out := make([]map[string]interface{}, 0, len(list))
+	for _, e := range list {
+		if m, ok := e.(map[string]interface{}); ok {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+func stringList(v interface{}) []string {
+	switch vs := v.(type) {
+	case []string:
+		return vs
+	case []interface{}:
+		out := make([]string, 0, len(vs))
+		for _, e := range vs {
+			if s, ok := e.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	default:
+		return 0, false
+	}
+}
+
+func jsonEqual(a, b interface{}) bool {
+	return encode(a) == encode(b)
+}
+
+func encode(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+func escapePointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,230 @@
+package validator
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func decode(t *testing.T, s string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	return v
+}
+
+func TestValidate_ShorthandProperties(t *testing.T) {
+	properties := map[string]interface{}{
+		"name": map[string]interface{}{"type": "string"},
+		"age":  map[string]interface{}{"type": "integer"},
+		"nick": map[string]interface{}{"type": "string"},
+		"note": map[string]interface{}{"type": "string", "nullable": true},
+		"sentiment": map[string]interface{}{
+			"type": "string",
+			"enum": []string{"positive", "negative"},
+		},
+		"tags": map[string]interface{}{
+			"type":  "array",
+			"items": map[string]interface{}{"type": "string"},
+		},
+		"user": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"id": map[string]interface{}{"type": "integer"},
+			},
+			"required": []string{"id"},
+		},
+	}
+	schema := ObjectSchema(properties, []string{"age", "name", "note", "sentiment", "tags", "user"})
+
+	tests := []struct {
+		name string
+		obj  string
+		want []string
+	}{
+		{
+			name: "valid",
+			obj:  `{"name":"a","age":3,"note":null,"sentiment":"positive","tags":["x"],"user":{"id":1}}`,
+		},
+		{
+			name: "optional key present",
+			obj:  `{"name":"a","age":3,"nick":"b","note":"n","sentiment":"negative","tags":[],"user":{"id":1}}`,
+		},
+		{
+			name: "wrong scalar types",
+			obj:  `{"name":1,"age":3.5,"note":null,"sentiment":"positive","tags":[],"user":{"id":1}}`,
+			want: []string{"/age: expected integer, got number", "/name: expected string, got integer"},
+		},
+		{
+			name: "missing required and unexpected key",
+			obj:  `{"name":"a","note":null,"sentiment":"positive","tags":[],"user":{"id":1},"extra":true}`,
+			want: []string{"/age: required property is missing", "/extra: unexpected property"},
+		},
+		{
+			name: "enum, array items and nested object",
+			obj:  `{"name":"a","age":1,"note":null,"sentiment":"meh","tags":["x",2],"user":{}}`,
+			want: []string{
+				`/sentiment: value "meh" is not one of positive|negative`,
+				"/tags/1: expected string, got integer",
+				"/user/id: required property is missing",
+			},
+		},
+		{
+			name: "null for non-nullable",
+			obj:  `{"name":null,"age":1,"note":null,"sentiment":"positive","tags":[],"user":{"id":1}}`,
+			want: []string{"/name: expected string, got null"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := Validate(schema, decode(t, tt.obj))
+			got := make([]string, 0, len(errs))
+			for _, e := range errs {
+				got = append(got, e.Error())
+			}
+			if len(tt.want) == 0 && len(got) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("errors mismatch:\ngot=%q\nwant=%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate_JSONSchemaKeywords(t *testing.T) {
+	schema := decode(t, `{
+	  "type": "object",
+	  "properties": {
+	    "customer": {"$ref": "#/$defs/party"},
+	    "total": {"type": "number", "minimum": 0},
+	    "code": {"type": "string", "pattern": "^[A-Z]{3}$", "maxLength": 3},
+	    "note": {"type": ["string", "null"]},
+	    "lines": {"type": "array", "minItems": 1, "items": {"type": "integer"}},
+	    "a/b": {"type": "boolean"}
+	  },
+	  "required": ["customer", "total"],
+	  "$defs": {
+	    "party": {"type": "object", "properties": {"name": {"type": "string", "minLength": 1}}, "required": ["name"]}
+	  }
+	}`).(map[string]interface{})
+
+	if errs := Validate(schema, decode(t, `{"customer":{"name":"Ann"},"total":3,"code":"ABC","note":null,"lines":[1],"extra":1}`)); len(errs) != 0 {
+		t.Fatalf("expected valid (additional properties allowed), got %v", errs)
+	}
+
+	errs := Validate(schema, decode(t, `{"customer":{"name":""},"total":-1,"code":"abcd","lines":[],"a/b":"x"}`))
+	got := make([]string, 0, len(errs))
+	for _, e := range errs {
+		got = append(got, e.Pointer)
+	}
+	want := []string{"/a~1b", "/code", "/code", "/customer/name", "/lines", "/total"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("pointers mismatch:\ngot=%v\nwant=%v\nerrs=%v", got, want, errs)
+	}
+}
+
+func TestObjectSchema_OptionalKeysNullable(t *testing.T) {
+	properties := map[string]interface{}{
+		"name": map[string]interface{}{"type": "string"},
+		"nick": map[string]interface{}{"type": "string"},
+		"user": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"id":    map[string]interface{}{"type": "integer"},
+				"email": map[string]interface{}{"type": "string"},
+			},
+			"required": []string{"id"},
+		},
+		"lines": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"sku":  map[string]interface{}{"type": "string"},
+					"note": map[string]interface{}{"type": "string"},
+				},
+				"required": []string{"sku"},
+			},
+		},
+	}
+	schema := ObjectSchema(properties, []string{"name", "user", "lines"})
+
+	// Strict providers send every key and null for the optional ones.
+	valid := `{"name":"a","nick":null,"user":{"id":1,"email":null},"lines":[{"sku":"x","note":null}]}`
+	if errs := Validate(schema, decode(t, valid)); len(errs) != 0 {
+		t.Fatalf("expected valid, got %v", errs)
+	}
+	errs := Validate(schema, decode(t, `{"name":null,"user":{"id":null},"lines":[{"sku":null}]}`))
+	want := []string{"/lines/0/sku: expected string, got null", "/name: expected string, got null", "/user/id: expected integer, got null"}
+	got := make([]string, 0, len(errs))
+	for _, e := range errs {
+		got = append(got, e.Error())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("errors mismatch:\ngot=%q\nwant=%q", got, want)
+	}
+	if _, ok := properties["nick"].(map[string]interface{})["nullable"]; ok {
+		t.Fatalf("properties were modified")
+	}
+}
+
+func TestObjectSchema_ClosedAtEveryDepth(t *testing.T) {
+	properties := map[string]interface{}{
+		"user": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"id": map[string]interface{}{"type": "integer"},
+			},
+		},
+		"lines": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"sku": map[string]interface{}{"type": "string"},
+				},
+			},
+		},
+		"meta": map[string]interface{}{
+			"type":                 "object",
+			"properties":           map[string]interface{}{},
+			"additionalProperties": true,
+		},
+	}
+	schema := ObjectSchema(properties, nil)
+	errs := Validate(schema, decode(t, `{"user":{"id":1,"x":1},"lines":[{"sku":"a","y":2}],"meta":{"z":3},"w":4}`))
+	want := []string{"/lines/0/y: unexpected property", "/user/x: unexpected property", "/w: unexpected property"}
+	got := make([]string, 0, len(errs))
+	for _, e := range errs {
+		got = append(got, e.Error())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("errors mismatch:\ngot=%q\nwant=%q", got, want)
+	}
+}
+
+func TestValidate_RootTypeMismatch(t *testing.T) {
+	errs := Validate(ObjectSchema(map[string]interface{}{}, nil), []interface{}{})
+	if len(errs) != 1 || errs[0].Error() != "/: expected object, got array" {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+}
+
+func TestValidate_AnyOfAndOneOf(t *testing.T) {
+	schema := decode(t, `{
+	  "type": "object",
+	  "properties": {
+	    "id": {"anyOf": [{"type": "string"}, {"type": "integer"}]},
+	    "kind": {"oneOf": [{"const": "a"}, {"const": "b"}]}
+	  }
+	}`).(map[string]interface{})
+	if errs := Validate(schema, decode(t, `{"id":1,"kind":"a"}`)); len(errs) != 0 {
+		t.Fatalf("expected valid, got %v", errs)
+	}
+	if errs := Validate(schema, decode(t, `{"id":true,"kind":"c"}`)); len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %v", errs)
+	}
+}